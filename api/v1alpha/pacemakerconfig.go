@@ -10,6 +10,12 @@ import (
 // +groupName=woehrl.net
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=pacemakerconfigs,scope=Cluster
+// +kubebuilder:deprecatedversion:warning="woehrl.net/v1alpha PacemakerConfig is deprecated, use woehrl.net/v1beta1"
+// +kubebuilder:printcolumn:name="Priority",type=integer,JSONPath=`.spec.priority`
+// +kubebuilder:printcolumn:name="Nodes",type=integer,JSONPath=`.status.effectiveNodeCount`
+// +kubebuilder:printcolumn:name="Shadowed",type=integer,JSONPath=`.status.shadowedNodeCount`
+// +kubebuilder:printcolumn:name="Valid",type=string,JSONPath=`.status.conditions[?(@.type=="Valid")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 type PacemakerConfig struct {
 	// TypeMeta is the metadata for the resource, like kind and apiversion
@@ -18,31 +24,108 @@ type PacemakerConfig struct {
 
 	// Spec is the custom resource spec
 	Spec PacemakerConfigSpec `json:"spec,omitempty"`
+
+	// Status is written by the status controller of the node daemons
+	Status PacemakerConfigStatus `json:"status,omitempty"`
 }
 
 type PacemakerConfigSpec struct {
 	NodeSelector   map[string]string  `json:"nodeSelector"`
 	ThrottleConfig NodeThrottleConfig `json:"throttleConfig"`
 	Priority       int                `json:"priority"`
+	// +kubebuilder:validation:Optional
+	// Overrides the settings of the CNI plugin on the selected nodes, unset values fall back to the CNI configuration
+	CniSettings CniSettings `json:"cniSettings,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Configures which pods are started without throttling
+	SkipPolicy SkipPolicy `json:"skipPolicy,omitempty"`
+}
+
+const (
+	// ConditionValid is true if the config could be parsed and all values are valid
+	ConditionValid = "Valid"
+
+	ReasonValid        = "Valid"
+	ReasonParseError   = "ParseError"
+	ReasonInvalidValue = "InvalidValue"
+)
+
+type PacemakerConfigStatus struct {
+	// +kubebuilder:validation:Optional
+	// The generation of the spec the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +kubebuilder:validation:Optional
+	// The number of nodes this config is effective on
+	EffectiveNodeCount int `json:"effectiveNodeCount"`
+	// +kubebuilder:validation:Optional
+	// The names of the nodes this config is effective on, truncated for large clusters
+	EffectiveNodes []string `json:"effectiveNodes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// The number of nodes this config matches, but a config with a higher priority is effective on
+	ShadowedNodeCount int `json:"shadowedNodeCount"`
+	// +kubebuilder:validation:Optional
+	// The names of the shadowed nodes, truncated for large clusters
+	ShadowedNodes []string `json:"shadowedNodes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	// Conditions of the config, e.g. Valid
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+type SkipPolicy struct {
+	// +kubebuilder:validation:Optional
+	// Skips pods in namespaces whose labels match any of the selectors
+	NamespaceSelectors []metav1.LabelSelector `json:"namespaceSelectors,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Skips pods whose labels match any of the selectors
+	PodSelectors []metav1.LabelSelector `json:"podSelectors,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:Enum=DaemonSet;Job;Static
+	// Skips pods owned by any of the kinds, "Static" matches static pods which are managed by the kubelet
+	OwnerKinds []string `json:"ownerKinds,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Skips pods with any of the PriorityClass names
+	PriorityClassNames []string `json:"priorityClassNames,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Skips pods which use the host network
+	HostNetwork bool `json:"hostNetwork,omitempty"`
+}
+
+type CniSettings struct {
+	// +kubebuilder:validation:Optional
+	// Sets the namespaces which are excluded from throttling
+	NamespaceExclusions []string `json:"namespaceExclusions,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=220
+	// Sets the maximum time in seconds the CNI plugin waits for a slot
+	MaxWaitTimeInSeconds *int32 `json:"maxWaitTimeInSeconds,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Sets whether pods start without throttling if the daemon is unreachable
+	SuccessOnConnectionTimeout *bool `json:"successOnConnectionTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Sets whether the CNI plugin skips throttling entirely
+	DisableThrottling *bool `json:"disableThrottling,omitempty"`
 }
 
 type NodeThrottleConfig struct {
 	// +kubebuilder:validation:Optional
 	// Configures a rate limiting strategy for concurrent pod starts
-	RateLimit RateLimitConfig `json:"rateLimit"`
+	RateLimit RateLimitConfig `json:"rateLimit,omitzero"`
 	// +kubebuilder:validation:Optional
 	// Configures a limitting strategy based on the maximum number of concurrent pod starts
-	MaxConcurrent MaxConcurrentConfig `json:"maxConcurrent"`
+	MaxConcurrent MaxConcurrentConfig `json:"maxConcurrent,omitzero"`
 	// +kubebuilder:validation:Optional
 	// Configures a limitting strategy based on the CPU load of the node
-	Cpu Cpu `json:"cpu"`
+	Cpu Cpu `json:"cpu,omitzero"`
 	// +kubebuilder:validation:Optional
 	// Configures a limitting strategy based on the IO load of the node
-	IO IO `json:"io"`
+	IO IO `json:"io,omitzero"`
 
 	// +kubebuilder:validation:Optional
 	// Configures a limitting strategy based on the load average of the node
-	LoadAvg LoadAvg `json:"loadAvg"`
+	LoadAvg LoadAvg `json:"loadAvg,omitzero"`
 }
 
 type RateLimitConfig struct {
@@ -61,7 +144,7 @@ type Cpu struct {
 	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
 	// +kubebuilder:validation:Optional
 	// Sets the increment by which the CPU load will be increased by a starting pod until the next measurement refresh
-	IncrementBy string `json:"incrementBy"`
+	IncrementBy string `json:"incrementBy,omitempty"`
 }
 
 type LoadAvg struct {
@@ -71,7 +154,7 @@ type LoadAvg struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
 	// Sets the increment by which the load average will be increased by a starting pod until the next measurement refresh
-	IncrementBy string `json:"incrementBy"`
+	IncrementBy string `json:"incrementBy,omitempty"`
 	// +kubebuilder:validation:Optional
 	// Sets whether the load average should be measured per CPU core or in total
 	PerCore bool `json:"perCore"`
@@ -84,7 +167,7 @@ type IO struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
 	// Sets the increment by which the IO load will be increased by a starting pod until the next measurement refresh
-	IncrementBy string `json:"incrementBy"`
+	IncrementBy string `json:"incrementBy,omitempty"`
 }
 
 type MaxConcurrentConfig struct {
@@ -0,0 +1,287 @@
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1alpha"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SpecAnnotation keeps the v1beta1 spec on a v1alpha object, if it can't be expressed in v1alpha,
+// e.g. matchExpressions or a custom order of the throttlers
+const SpecAnnotation = "woehrl.net/v1beta1-spec"
+
+// ConvertFromV1alpha converts a v1alpha config, the number strings of v1alpha are parsed as quantities
+func ConvertFromV1alpha(in *v1alpha.PacemakerConfig) (*PacemakerConfig, error) {
+	out := &PacemakerConfig{
+		TypeMeta:   metav1.TypeMeta{APIVersion: SchemeGroupVersion.String(), Kind: "PacemakerConfig"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Status:     convertStatusFromV1alpha(in.Status),
+	}
+
+	// prefer the spec which was kept by a previous conversion, unless the v1alpha spec was changed since then
+	if kept, ok := out.Annotations[SpecAnnotation]; ok {
+		delete(out.Annotations, SpecAnnotation)
+		var spec PacemakerConfigSpec
+		if err := json.Unmarshal([]byte(kept), &spec); err == nil {
+			if projected, _ := convertSpecToV1alpha(spec); reflect.DeepEqual(projected, in.Spec) {
+				out.Spec = spec
+				return out, nil
+			}
+		}
+	}
+
+	spec, err := convertSpecFromV1alpha(in.Spec)
+	if err != nil {
+		return nil, err
+	}
+	out.Spec = spec
+	return out, nil
+}
+
+// ConvertToV1alpha converts a config to v1alpha, the spec is kept in an annotation if v1alpha can't express it
+func ConvertToV1alpha(in *PacemakerConfig) (*v1alpha.PacemakerConfig, error) {
+	out := &v1alpha.PacemakerConfig{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "woehrl.net/v1alpha", Kind: "PacemakerConfig"},
+		ObjectMeta: *in.ObjectMeta.DeepCopy(),
+		Status:     convertStatusToV1alpha(in.Status),
+	}
+
+	spec, lossless := convertSpecToV1alpha(in.Spec)
+	out.Spec = spec
+	if !lossless {
+		kept, err := json.Marshal(in.Spec)
+		if err != nil {
+			return nil, err
+		}
+		if out.Annotations == nil {
+			out.Annotations = map[string]string{}
+		}
+		out.Annotations[SpecAnnotation] = string(kept)
+	}
+	return out, nil
+}
+
+func convertSpecFromV1alpha(in v1alpha.PacemakerConfigSpec) (PacemakerConfigSpec, error) {
+	out := PacemakerConfigSpec{
+		Priority: in.Priority,
+		CniSettings: CniSettings{
+			NamespaceExclusions:        in.CniSettings.NamespaceExclusions,
+			MaxWaitTimeInSeconds:       in.CniSettings.MaxWaitTimeInSeconds,
+			SuccessOnConnectionTimeout: in.CniSettings.SuccessOnConnectionTimeout,
+			DisableThrottling:          in.CniSettings.DisableThrottling,
+		},
+		SkipPolicy: SkipPolicy{
+			NamespaceSelectors: in.SkipPolicy.NamespaceSelectors,
+			PodSelectors:       in.SkipPolicy.PodSelectors,
+			OwnerKinds:         in.SkipPolicy.OwnerKinds,
+			PriorityClassNames: in.SkipPolicy.PriorityClassNames,
+			HostNetwork:        in.SkipPolicy.HostNetwork,
+		},
+	}
+	if len(in.NodeSelector) > 0 {
+		out.NodeSelector = &metav1.LabelSelector{MatchLabels: in.NodeSelector}
+	}
+
+	// v1alpha applies the throttlers in a fixed order and skips the ones which aren't configured completely
+	config := in.ThrottleConfig
+	if config.RateLimit.FillFactor != "" && config.RateLimit.Burst > 0 {
+		fillFactor, err := parseDuration("rateLimit.fillFactor", config.RateLimit.FillFactor)
+		if err != nil {
+			return out, err
+		}
+		out.Throttlers = append(out.Throttlers, Throttler{RateLimit: &RateLimit{
+			FillFactor: metav1.Duration{Duration: fillFactor},
+			Burst:      config.RateLimit.Burst,
+		}})
+	}
+	if config.MaxConcurrent.Value > 0 || config.MaxConcurrent.PerCore != "" {
+		perCore, err := parseOptionalQuantity("maxConcurrent.perCore", config.MaxConcurrent.PerCore)
+		if err != nil {
+			return out, err
+		}
+		out.Throttlers = append(out.Throttlers, Throttler{MaxConcurrent: &MaxConcurrent{
+			Value:   config.MaxConcurrent.Value,
+			PerCore: perCore,
+		}})
+	}
+	if config.LoadAvg.MaxLoad != "" {
+		maxLoad, incrementBy, err := parseLoad("loadAvg", config.LoadAvg.MaxLoad, config.LoadAvg.IncrementBy)
+		if err != nil {
+			return out, err
+		}
+		out.Throttlers = append(out.Throttlers, Throttler{LoadAvg: &LoadAvgLimit{
+			MaxLoad:     maxLoad,
+			IncrementBy: incrementBy,
+			PerCore:     config.LoadAvg.PerCore,
+		}})
+	}
+	if config.Cpu.MaxLoad != "" {
+		maxLoad, incrementBy, err := parseLoad("cpu", config.Cpu.MaxLoad, config.Cpu.IncrementBy)
+		if err != nil {
+			return out, err
+		}
+		out.Throttlers = append(out.Throttlers, Throttler{Cpu: &LoadLimit{MaxLoad: maxLoad, IncrementBy: incrementBy}})
+	}
+	if config.IO.MaxLoad != "" {
+		maxLoad, incrementBy, err := parseLoad("io", config.IO.MaxLoad, config.IO.IncrementBy)
+		if err != nil {
+			return out, err
+		}
+		out.Throttlers = append(out.Throttlers, Throttler{IO: &LoadLimit{MaxLoad: maxLoad, IncrementBy: incrementBy}})
+	}
+	return out, nil
+}
+
+// convertSpecToV1alpha returns false if the spec can't be expressed in v1alpha
+func convertSpecToV1alpha(in PacemakerConfigSpec) (v1alpha.PacemakerConfigSpec, bool) {
+	out := v1alpha.PacemakerConfigSpec{
+		Priority: in.Priority,
+		CniSettings: v1alpha.CniSettings{
+			NamespaceExclusions:        in.CniSettings.NamespaceExclusions,
+			MaxWaitTimeInSeconds:       in.CniSettings.MaxWaitTimeInSeconds,
+			SuccessOnConnectionTimeout: in.CniSettings.SuccessOnConnectionTimeout,
+			DisableThrottling:          in.CniSettings.DisableThrottling,
+		},
+		SkipPolicy: v1alpha.SkipPolicy{
+			NamespaceSelectors: in.SkipPolicy.NamespaceSelectors,
+			PodSelectors:       in.SkipPolicy.PodSelectors,
+			OwnerKinds:         in.SkipPolicy.OwnerKinds,
+			PriorityClassNames: in.SkipPolicy.PriorityClassNames,
+			HostNetwork:        in.SkipPolicy.HostNetwork,
+		},
+	}
+	// v1alpha configs always replace the lower ones and have no throttle groups, schedules or warmup
+	lossless := !in.Merge && len(in.ThrottleGroups) == 0 && len(in.Schedules) == 0 && in.Warmup == nil
+	if in.NodeSelector != nil {
+		out.NodeSelector = in.NodeSelector.MatchLabels
+		lossless = lossless && len(in.NodeSelector.MatchExpressions) == 0
+	}
+
+	config := &out.ThrottleConfig
+	for _, t := range in.Throttlers {
+		switch {
+		case t.RateLimit != nil && config.RateLimit == (v1alpha.RateLimitConfig{}):
+			config.RateLimit = v1alpha.RateLimitConfig{FillFactor: t.RateLimit.FillFactor.Duration.String(), Burst: t.RateLimit.Burst}
+		case t.MaxConcurrent != nil && config.MaxConcurrent == (v1alpha.MaxConcurrentConfig{}):
+			config.MaxConcurrent = v1alpha.MaxConcurrentConfig{Value: t.MaxConcurrent.Value, PerCore: formatOptionalQuantity(t.MaxConcurrent.PerCore)}
+		case t.LoadAvg != nil && config.LoadAvg == (v1alpha.LoadAvg{}):
+			config.LoadAvg = v1alpha.LoadAvg{MaxLoad: formatQuantity(t.LoadAvg.MaxLoad), IncrementBy: formatOptionalQuantity(t.LoadAvg.IncrementBy), PerCore: t.LoadAvg.PerCore}
+		case t.Cpu != nil && config.Cpu == (v1alpha.Cpu{}):
+			config.Cpu = v1alpha.Cpu{MaxLoad: formatQuantity(t.Cpu.MaxLoad), IncrementBy: formatOptionalQuantity(t.Cpu.IncrementBy)}
+		case t.IO != nil && config.IO == (v1alpha.IO{}):
+			config.IO = v1alpha.IO{MaxLoad: formatQuantity(t.IO.MaxLoad), IncrementBy: formatOptionalQuantity(t.IO.IncrementBy)}
+		default:
+			lossless = false // a second throttler of the same type
+		}
+	}
+
+	// v1alpha can only express the throttlers in its fixed order
+	if lossless {
+		roundTrip, err := convertSpecFromV1alpha(out)
+		lossless = err == nil && reflect.DeepEqual(throttlerTypes(roundTrip.Throttlers), throttlerTypes(in.Throttlers))
+	}
+	return out, lossless
+}
+
+func convertStatusFromV1alpha(in v1alpha.PacemakerConfigStatus) PacemakerConfigStatus {
+	return PacemakerConfigStatus{
+		ObservedGeneration: in.ObservedGeneration,
+		EffectiveNodeCount: in.EffectiveNodeCount,
+		EffectiveNodes:     in.EffectiveNodes,
+		ShadowedNodeCount:  in.ShadowedNodeCount,
+		ShadowedNodes:      in.ShadowedNodes,
+		Conditions:         in.Conditions,
+	}
+}
+
+func convertStatusToV1alpha(in PacemakerConfigStatus) v1alpha.PacemakerConfigStatus {
+	return v1alpha.PacemakerConfigStatus{
+		ObservedGeneration: in.ObservedGeneration,
+		EffectiveNodeCount: in.EffectiveNodeCount,
+		EffectiveNodes:     in.EffectiveNodes,
+		ShadowedNodeCount:  in.ShadowedNodeCount,
+		ShadowedNodes:      in.ShadowedNodes,
+		Conditions:         in.Conditions,
+	}
+}
+
+// Type returns the name of the throttler which is set, e.g. rateLimit
+func (t *Throttler) Type() string {
+	switch {
+	case t.RateLimit != nil:
+		return "rateLimit"
+	case t.MaxConcurrent != nil:
+		return "maxConcurrent"
+	case t.Cpu != nil:
+		return "cpu"
+	case t.IO != nil:
+		return "io"
+	case t.LoadAvg != nil:
+		return "loadAvg"
+	case t.Cel != nil:
+		return "cel"
+	case t.AnyOf != nil:
+		return "anyOf"
+	case t.AllOf != nil:
+		return "allOf"
+	}
+	return ""
+}
+
+func throttlerTypes(throttlers []Throttler) []string {
+	types := make([]string, 0, len(throttlers))
+	for _, t := range throttlers {
+		types = append(types, t.Type())
+	}
+	return types
+}
+
+func parseDuration(name string, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %q", name, value)
+	}
+	return d, nil
+}
+
+func parseLoad(name string, maxLoad string, incrementBy string) (resource.Quantity, *resource.Quantity, error) {
+	parsedMaxLoad, err := resource.ParseQuantity(maxLoad)
+	if err != nil {
+		return parsedMaxLoad, nil, fmt.Errorf("failed to parse %s.maxLoad: %q", name, maxLoad)
+	}
+	parsedIncrementBy, err := parseOptionalQuantity(name+".incrementBy", incrementBy)
+	return parsedMaxLoad, parsedIncrementBy, err
+}
+
+func parseOptionalQuantity(name string, value string) (*resource.Quantity, error) {
+	if value == "" {
+		return nil, nil
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %q", name, value)
+	}
+	return &q, nil
+}
+
+// formatQuantity returns the quantity as a decimal number, like v1alpha expects it, e.g. 0.5 instead of 500m
+func formatQuantity(q resource.Quantity) string {
+	return strconv.FormatFloat(q.AsApproximateFloat64(), 'f', -1, 64)
+}
+
+func formatOptionalQuantity(q *resource.Quantity) string {
+	if q == nil {
+		return ""
+	}
+	return formatQuantity(*q)
+}
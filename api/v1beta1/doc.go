@@ -0,0 +1,5 @@
+// Package v1beta1 contains the v1beta1 version of the PacemakerConfig API
+// +k8s:deepcopy-gen=package
+// +kubebuilder:object:generate=true
+// +groupName=woehrl.net
+package v1beta1
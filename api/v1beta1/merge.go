@@ -0,0 +1,200 @@
+package v1beta1
+
+import (
+	"slices"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// MergeSpec returns base with the fields which are set in overlay replaced, and the paths of the replaced fields.
+// A throttler of overlay is merged into the throttler of base with the same type and position among the throttlers
+// of this type, e.g. the first cpu throttler into the first cpu throttler. Other throttlers are appended.
+// A throttle group or schedule of overlay replaces the one of base with the same name, others are appended.
+func MergeSpec(base PacemakerConfigSpec, overlay PacemakerConfigSpec) (PacemakerConfigSpec, []string) {
+	merged := *base.DeepCopy()
+	merged.NodeSelector = overlay.NodeSelector.DeepCopy()
+	merged.Priority = overlay.Priority
+	merged.Merge = overlay.Merge
+
+	throttlers, overrides := mergeThrottlers(merged.Throttlers, overlay.Throttlers, field.NewPath("throttlers"))
+	merged.Throttlers = throttlers
+
+	// a group replaces the group of base with the same name as a whole, its selectors and throttlers belong together
+	groupsPath := field.NewPath("throttleGroups")
+	for _, group := range overlay.ThrottleGroups {
+		overrides = append(overrides, groupsPath.Key(group.Name).String())
+		i := slices.IndexFunc(merged.ThrottleGroups, func(g ThrottleGroup) bool { return g.Name == group.Name })
+		if i < 0 {
+			merged.ThrottleGroups = append(merged.ThrottleGroups, *group.DeepCopy())
+			continue
+		}
+		merged.ThrottleGroups[i] = *group.DeepCopy()
+	}
+
+	// a schedule replaces the schedule of base with the same name as a whole, like a group
+	schedulesPath := field.NewPath("schedules")
+	for _, s := range overlay.Schedules {
+		overrides = append(overrides, schedulesPath.Key(s.Name).String())
+		i := slices.IndexFunc(merged.Schedules, func(other Schedule) bool { return other.Name == s.Name })
+		if i < 0 {
+			merged.Schedules = append(merged.Schedules, *s.DeepCopy())
+			continue
+		}
+		merged.Schedules[i] = *s.DeepCopy()
+	}
+
+	if overlay.Warmup != nil {
+		merged.Warmup = overlay.Warmup.DeepCopy()
+		overrides = append(overrides, field.NewPath("warmup").String())
+	}
+
+	cni := &merged.CniSettings
+	overlayCni := overlay.CniSettings.DeepCopy()
+	cniPath := field.NewPath("cniSettings")
+	if overlayCni.NamespaceExclusions != nil {
+		cni.NamespaceExclusions = overlayCni.NamespaceExclusions
+		overrides = append(overrides, cniPath.Child("namespaceExclusions").String())
+	}
+	if overlayCni.MaxWaitTimeInSeconds != nil {
+		cni.MaxWaitTimeInSeconds = overlayCni.MaxWaitTimeInSeconds
+		overrides = append(overrides, cniPath.Child("maxWaitTimeInSeconds").String())
+	}
+	if overlayCni.SuccessOnConnectionTimeout != nil {
+		cni.SuccessOnConnectionTimeout = overlayCni.SuccessOnConnectionTimeout
+		overrides = append(overrides, cniPath.Child("successOnConnectionTimeout").String())
+	}
+	if overlayCni.DisableThrottling != nil {
+		cni.DisableThrottling = overlayCni.DisableThrottling
+		overrides = append(overrides, cniPath.Child("disableThrottling").String())
+	}
+
+	skip := &merged.SkipPolicy
+	overlaySkip := overlay.SkipPolicy.DeepCopy()
+	skipPath := field.NewPath("skipPolicy")
+	if overlaySkip.NamespaceSelectors != nil {
+		skip.NamespaceSelectors = overlaySkip.NamespaceSelectors
+		overrides = append(overrides, skipPath.Child("namespaceSelectors").String())
+	}
+	if overlaySkip.PodSelectors != nil {
+		skip.PodSelectors = overlaySkip.PodSelectors
+		overrides = append(overrides, skipPath.Child("podSelectors").String())
+	}
+	if overlaySkip.OwnerKinds != nil {
+		skip.OwnerKinds = overlaySkip.OwnerKinds
+		overrides = append(overrides, skipPath.Child("ownerKinds").String())
+	}
+	if overlaySkip.PriorityClassNames != nil {
+		skip.PriorityClassNames = overlaySkip.PriorityClassNames
+		overrides = append(overrides, skipPath.Child("priorityClassNames").String())
+	}
+	if overlaySkip.HostNetwork {
+		skip.HostNetwork = true
+		overrides = append(overrides, skipPath.Child("hostNetwork").String())
+	}
+	return merged, overrides
+}
+
+// mergeThrottlers merges the overlay into a copy of the throttlers and returns it with the paths of the replaced fields
+func mergeThrottlers(base []Throttler, overlay []Throttler, path *field.Path) ([]Throttler, []string) {
+	merged := make([]Throttler, 0, len(base)+len(overlay))
+	for i := range base {
+		merged = append(merged, *base[i].DeepCopy())
+	}
+	overrides := []string{}
+	seen := map[string]int{}
+	for _, t := range overlay {
+		throttlerType := t.Type()
+		i := nthOfType(merged, throttlerType, seen[throttlerType])
+		seen[throttlerType]++
+		if i < 0 {
+			merged = append(merged, *t.DeepCopy())
+			overrides = append(overrides, path.Index(len(merged)-1).Child(throttlerType).String())
+			continue
+		}
+		overrides = append(overrides, mergeThrottler(&merged[i], &t, path.Index(i))...)
+	}
+	return merged, overrides
+}
+
+// nthOfType returns the index of the n-th throttler of the type, or -1 if there are fewer
+func nthOfType(throttlers []Throttler, throttlerType string, n int) int {
+	for i := range throttlers {
+		if throttlers[i].Type() != throttlerType {
+			continue
+		}
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return -1
+}
+
+func mergeThrottler(target *Throttler, overlay *Throttler, path *field.Path) []string {
+	overrides := []string{}
+	switch {
+	case overlay.RateLimit != nil:
+		if overlay.RateLimit.FillFactor.Duration != 0 {
+			target.RateLimit.FillFactor = overlay.RateLimit.FillFactor
+			overrides = append(overrides, path.Child("rateLimit", "fillFactor").String())
+		}
+		if overlay.RateLimit.Burst != 0 {
+			target.RateLimit.Burst = overlay.RateLimit.Burst
+			overrides = append(overrides, path.Child("rateLimit", "burst").String())
+		}
+	case overlay.MaxConcurrent != nil:
+		// value and perCore are alternatives, setting one of them replaces the other
+		if overlay.MaxConcurrent.Value != 0 {
+			target.MaxConcurrent.Value = overlay.MaxConcurrent.Value
+			target.MaxConcurrent.PerCore = nil
+			overrides = append(overrides, path.Child("maxConcurrent", "value").String())
+		}
+		if overlay.MaxConcurrent.PerCore != nil {
+			perCore := overlay.MaxConcurrent.PerCore.DeepCopy()
+			target.MaxConcurrent.PerCore = &perCore
+			target.MaxConcurrent.Value = overlay.MaxConcurrent.Value
+			overrides = append(overrides, path.Child("maxConcurrent", "perCore").String())
+		}
+	case overlay.Cpu != nil:
+		overrides = mergeLoadLimit(target.Cpu, overlay.Cpu, path.Child("cpu"))
+	case overlay.IO != nil:
+		overrides = mergeLoadLimit(target.IO, overlay.IO, path.Child("io"))
+	case overlay.Cel != nil:
+		target.Cel.Expression = overlay.Cel.Expression
+		overrides = append(overrides, path.Child("cel", "expression").String())
+	case overlay.AnyOf != nil:
+		// the nested throttlers can't be matched reliably, so a composition is replaced as a whole
+		target.AnyOf = overlay.DeepCopy().AnyOf
+		overrides = append(overrides, path.Child("anyOf").String())
+	case overlay.AllOf != nil:
+		target.AllOf = overlay.DeepCopy().AllOf
+		overrides = append(overrides, path.Child("allOf").String())
+	case overlay.LoadAvg != nil:
+		if !overlay.LoadAvg.MaxLoad.IsZero() {
+			target.LoadAvg.MaxLoad = overlay.LoadAvg.MaxLoad.DeepCopy()
+			// the limit is only meaningful together with the unit it is measured in
+			target.LoadAvg.PerCore = overlay.LoadAvg.PerCore
+			overrides = append(overrides, path.Child("loadAvg", "maxLoad").String(), path.Child("loadAvg", "perCore").String())
+		}
+		if overlay.LoadAvg.IncrementBy != nil {
+			incrementBy := overlay.LoadAvg.IncrementBy.DeepCopy()
+			target.LoadAvg.IncrementBy = &incrementBy
+			overrides = append(overrides, path.Child("loadAvg", "incrementBy").String())
+		}
+	}
+	return overrides
+}
+
+func mergeLoadLimit(target *LoadLimit, overlay *LoadLimit, path *field.Path) []string {
+	overrides := []string{}
+	if !overlay.MaxLoad.IsZero() {
+		target.MaxLoad = overlay.MaxLoad.DeepCopy()
+		overrides = append(overrides, path.Child("maxLoad").String())
+	}
+	if overlay.IncrementBy != nil {
+		incrementBy := overlay.IncrementBy.DeepCopy()
+		target.IncrementBy = &incrementBy
+		overrides = append(overrides, path.Child("incrementBy").String())
+	}
+	return overrides
+}
@@ -0,0 +1,333 @@
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=pacemakerconfigs,scope=Cluster
+// +kubebuilder:printcolumn:name="Priority",type=integer,JSONPath=`.spec.priority`
+// +kubebuilder:printcolumn:name="Nodes",type=integer,JSONPath=`.status.effectiveNodeCount`
+// +kubebuilder:printcolumn:name="Shadowed",type=integer,JSONPath=`.status.shadowedNodeCount`
+// +kubebuilder:printcolumn:name="Valid",type=string,JSONPath=`.status.conditions[?(@.type=="Valid")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+type PacemakerConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PacemakerConfigSpec `json:"spec,omitempty"`
+
+	// Status is written by the status controller of the node daemons
+	Status PacemakerConfigStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:object:root=true
+
+type PacemakerConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PacemakerConfig `json:"items"`
+}
+
+type PacemakerConfigSpec struct {
+	// +kubebuilder:validation:Optional
+	// Selects the nodes the config applies to, an empty selector selects all nodes
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	// +kubebuilder:validation:Optional
+	// The config with the highest priority which selects a node is effective on it
+	Priority int `json:"priority"`
+	// +kubebuilder:validation:Optional
+	// Merges the config onto the matching config with the next lower priority instead of replacing it,
+	// the fields which are set in this config override the ones of the lower config
+	Merge bool `json:"merge,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	// The throttlers a pod passes before it starts, in this order
+	Throttlers []Throttler `json:"throttlers,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// Routes the pods through the throttlers of the first group which matches them,
+	// pods which match no group pass the throttlers of the config
+	ThrottleGroups []ThrottleGroup `json:"throttleGroups,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// Overrides the throttlers while a schedule is active, e.g. during a nightly batch window.
+	// The throttlers of all active schedules are merged onto the throttlers in the order of the list
+	Schedules []Schedule `json:"schedules,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Starts with stricter limits after the node became ready or booted and ramps them up to the configured ones
+	Warmup *Warmup `json:"warmup,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Overrides the settings of the CNI plugin on the selected nodes, unset values fall back to the CNI configuration
+	CniSettings CniSettings `json:"cniSettings,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Configures which pods are started without throttling
+	SkipPolicy SkipPolicy `json:"skipPolicy,omitempty"`
+}
+
+// Throttler is a stage of the pipeline, exactly one of the fields has to be set
+// +kubebuilder:validation:MinProperties=1
+// +kubebuilder:validation:MaxProperties=1
+type Throttler struct {
+	// +kubebuilder:validation:Optional
+	// Limits the rate of pod starts
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Limits the number of concurrent pod starts
+	MaxConcurrent *MaxConcurrent `json:"maxConcurrent,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Limits the pod starts by the CPU load of the node in percent
+	Cpu *LoadLimit `json:"cpu,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Limits the pod starts by the IO wait of the node in percent
+	IO *LoadLimit `json:"io,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Limits the pod starts by the 1 minute load average of the node
+	LoadAvg *LoadAvgLimit `json:"loadAvg,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Limits the pod starts by a CEL expression, the pod starts once it returns true
+	Cel *CelCondition `json:"cel,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Type=object
+	// +kubebuilder:validation:items:MinProperties=1
+	// +kubebuilder:validation:items:MaxProperties=1
+	// +kubebuilder:validation:items:XPreserveUnknownFields
+	// +listType=atomic
+	// Admits the pod as soon as one of the throttlers admits it, e.g. a loadAvg limit or a single concurrent pod.
+	// The nested throttlers are validated by the webhook and the daemon, the schema can't describe recursive types.
+	AnyOf []Throttler `json:"anyOf,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:items:Type=object
+	// +kubebuilder:validation:items:MinProperties=1
+	// +kubebuilder:validation:items:MaxProperties=1
+	// +kubebuilder:validation:items:XPreserveUnknownFields
+	// +listType=atomic
+	// Admits the pod once all of the throttlers admitted it, in this order, e.g. as a branch of anyOf
+	AllOf []Throttler `json:"allOf,omitempty"`
+}
+
+// ThrottleGroup is a throttler pipeline for a subset of the pods, e.g. the pods of JVM based services.
+// A pod matches the group if it matches all of the selectors and the expression which are set.
+type ThrottleGroup struct {
+	// +kubebuilder:validation:MinLength=1
+	// The name of the group, it is shown in the metrics and by pacemakerctl
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// Selects the pods by the labels of their namespace
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Selects the pods by their labels
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Selects the pods by a CEL expression on the pod, e.g. `pod.spec.containers.exists(c, c.image.contains("java"))`
+	Expression string `json:"expression,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	// The throttlers the pods of the group pass before they start, in this order
+	Throttlers []Throttler `json:"throttlers,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Passes the pods of the group also through the throttlers of the config afterwards,
+	// so the group is additionally limited by the limits all pods share
+	InheritThrottlers bool `json:"inheritThrottlers,omitempty"`
+}
+
+type CelCondition struct {
+	// +kubebuilder:validation:MinLength=1
+	// The expression decides if the next pod in line may start, e.g. `active < 2 || loadAvg < 0.8 * double(cpus)`.
+	// It can use active, cpus, cpu, io, loadAvg, psi, now, pod, requests and priority
+	Expression string `json:"expression"`
+}
+
+// Schedule is a recurring period of time, either the times of a cron expression with a duration
+// or a time range on weekdays, optionally restricted to a range of dates
+type Schedule struct {
+	// +kubebuilder:validation:MinLength=1
+	// The name of the schedule, it is logged when the schedule becomes active
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// Starts the schedule at the times of a cron expression, e.g. `0 22 * * 1-5`, requires duration
+	Cron string `json:"cron,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	// How long the schedule stays active after each time of the cron expression, e.g. `8h`
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+	// +listType=set
+	// The weekdays on which the time range starts, every day if empty
+	Weekdays []string `json:"weekdays,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	// The start of the time range, e.g. `08:00`
+	StartTime string `json:"startTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	// The end of the time range, e.g. `18:00`, a time range which ends before it starts spans midnight
+	EndTime string `json:"endTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Format=date
+	// The first day on which the schedule is active, e.g. `2026-12-24`
+	StartDate string `json:"startDate,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Format=date
+	// The last day on which the schedule is active
+	EndDate string `json:"endDate,omitempty"`
+	// +kubebuilder:validation:Optional
+	// The IANA timezone of the times and dates, e.g. `Europe/Berlin`, defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	// The throttlers which are merged onto the throttlers of the config while the schedule is active
+	Throttlers []Throttler `json:"throttlers,omitempty"`
+}
+
+const (
+	WarmupLinear        = "Linear"
+	WarmupStepwise      = "Stepwise"
+	WarmupFromNodeReady = "NodeReady"
+	WarmupFromBoot      = "Boot"
+)
+
+// Warmup scales the limits of the throttlers by a fraction which grows to 1 over the duration,
+// the concurrency and the rate of pod starts as well as the maximum cpu, io and load average
+type Warmup struct {
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	// How long the limits ramp up to the configured values, e.g. `10m`
+	Duration metav1.Duration `json:"duration"`
+	// The fraction of the limits at the start of the ramp, as a quantity, e.g. `250m` or `0.25`
+	InitialFraction resource.Quantity `json:"initialFraction"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Linear;Stepwise
+	// +kubebuilder:default=Linear
+	// Linear raises the limits continuously, Stepwise in steps
+	Mode string `json:"mode,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// The number of steps of a stepwise ramp, defaults to 4
+	Steps int `json:"steps,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=NodeReady;Boot
+	// +kubebuilder:default=NodeReady
+	// The ramp starts when the node became ready or when it booted
+	From string `json:"from,omitempty"`
+}
+
+type RateLimit struct {
+	// +kubebuilder:validation:Type=string
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	// Sets the time in which one pod start is refilled, e.g. "100ms" for 10 pods per second
+	FillFactor metav1.Duration `json:"fillFactor"`
+	// +kubebuilder:validation:Minimum=1
+	// Sets the maximum number of pods which can start at once
+	Burst int `json:"burst"`
+}
+
+type MaxConcurrent struct {
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Optional
+	// Sets the maximum number of concurrent pod starts in total. Has precedence over perCore
+	Value int `json:"value,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Sets the maximum number of concurrent pod starts per CPU core, e.g. 500m
+	PerCore *resource.Quantity `json:"perCore,omitempty"`
+}
+
+type LoadLimit struct {
+	// Sets the load in percent which should not be exceeded
+	MaxLoad resource.Quantity `json:"maxLoad"`
+	// +kubebuilder:validation:Optional
+	// Sets the increment by which the load is increased by a starting pod until the next measurement
+	IncrementBy *resource.Quantity `json:"incrementBy,omitempty"`
+}
+
+type LoadAvgLimit struct {
+	// Sets the load average which should not be exceeded
+	MaxLoad resource.Quantity `json:"maxLoad"`
+	// +kubebuilder:validation:Optional
+	// Sets the increment by which the load average is increased by a starting pod until the next measurement
+	IncrementBy *resource.Quantity `json:"incrementBy,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Sets whether the load average is measured per CPU core or in total
+	PerCore bool `json:"perCore,omitempty"`
+}
+
+type SkipPolicy struct {
+	// +kubebuilder:validation:Optional
+	// Skips pods in namespaces whose labels match any of the selectors
+	NamespaceSelectors []metav1.LabelSelector `json:"namespaceSelectors,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Skips pods whose labels match any of the selectors
+	PodSelectors []metav1.LabelSelector `json:"podSelectors,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:items:Enum=DaemonSet;Job;Static
+	// Skips pods owned by any of the kinds, "Static" matches static pods which are managed by the kubelet
+	OwnerKinds []string `json:"ownerKinds,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Skips pods with any of the PriorityClass names
+	PriorityClassNames []string `json:"priorityClassNames,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Skips pods which use the host network
+	HostNetwork bool `json:"hostNetwork,omitempty"`
+}
+
+type CniSettings struct {
+	// +kubebuilder:validation:Optional
+	// Sets the namespaces which are excluded from throttling
+	NamespaceExclusions []string `json:"namespaceExclusions,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=220
+	// Sets the maximum time in seconds the CNI plugin waits for a slot
+	MaxWaitTimeInSeconds *int32 `json:"maxWaitTimeInSeconds,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Sets whether pods start without throttling if the daemon is unreachable
+	SuccessOnConnectionTimeout *bool `json:"successOnConnectionTimeout,omitempty"`
+	// +kubebuilder:validation:Optional
+	// Sets whether the CNI plugin skips throttling entirely
+	DisableThrottling *bool `json:"disableThrottling,omitempty"`
+}
+
+const (
+	// ConditionValid is true if all values of the config are valid
+	ConditionValid = "Valid"
+
+	ReasonValid        = "Valid"
+	ReasonInvalidValue = "InvalidValue"
+)
+
+type PacemakerConfigStatus struct {
+	// +kubebuilder:validation:Optional
+	// The generation of the spec the status was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +kubebuilder:validation:Optional
+	// The number of nodes this config is effective on
+	EffectiveNodeCount int `json:"effectiveNodeCount"`
+	// +kubebuilder:validation:Optional
+	// The names of the nodes this config is effective on, truncated for large clusters
+	EffectiveNodes []string `json:"effectiveNodes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// The number of nodes this config matches, but a config with a higher priority is effective on
+	ShadowedNodeCount int `json:"shadowedNodeCount"`
+	// +kubebuilder:validation:Optional
+	// The names of the shadowed nodes, truncated for large clusters
+	ShadowedNodes []string `json:"shadowedNodes,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	// Conditions of the config, e.g. Valid
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
@@ -0,0 +1,85 @@
+package v1beta1
+
+import (
+	"fmt"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/schedule"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Window returns the periods of time in which the schedule is active
+func (s *Schedule) Window() (schedule.Window, error) {
+	location := time.UTC
+	if s.Timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", s.Timezone)
+		}
+	}
+
+	var window schedule.Window
+	var err error
+	switch {
+	case s.Cron != "" && (s.StartTime != "" || s.EndTime != "" || len(s.Weekdays) > 0):
+		return nil, fmt.Errorf("either cron or startTime, endTime and weekdays can be set")
+	case s.Cron != "":
+		if s.Duration == nil {
+			return nil, fmt.Errorf("a cron schedule requires a duration")
+		}
+		window, err = schedule.NewCronWindow(s.Cron, s.Duration.Duration, location)
+	case s.StartTime != "" && s.EndTime != "":
+		weekdays := make([]time.Weekday, 0, len(s.Weekdays))
+		for _, name := range s.Weekdays {
+			weekday, ok := parseWeekday(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown weekday %q", name)
+			}
+			weekdays = append(weekdays, weekday)
+		}
+		window, err = schedule.NewTimeRangeWindow(weekdays, s.StartTime, s.EndTime, location)
+	default:
+		return nil, fmt.Errorf("either cron and duration or startTime and endTime are required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.StartDate == "" && s.EndDate == "" {
+		return window, nil
+	}
+	return schedule.WithinDates(window, s.StartDate, s.EndDate, location)
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if weekday.String() == name {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+// ApplySchedules returns the spec with the throttlers of the schedules which are active at now merged onto its throttlers,
+// the names of the active schedules and the time at which the active schedules may change next, zero if they never change.
+// Invalid schedules are ignored, they are rejected by Validate.
+func ApplySchedules(spec PacemakerConfigSpec, now time.Time) (PacemakerConfigSpec, []string, time.Time) {
+	applied := *spec.DeepCopy()
+	active := []string{}
+	next := time.Time{}
+	for _, s := range spec.Schedules {
+		window, err := s.Window()
+		if err != nil {
+			continue
+		}
+		if transition := window.NextTransition(now); !transition.IsZero() && (next.IsZero() || transition.Before(next)) {
+			next = transition
+		}
+		if !window.Active(now) {
+			continue
+		}
+		active = append(active, s.Name)
+		applied.Throttlers, _ = mergeThrottlers(applied.Throttlers, s.Throttlers, field.NewPath("throttlers"))
+	}
+	return applied, active, next
+}
@@ -0,0 +1,205 @@
+package v1beta1
+
+import (
+	"fmt"
+
+	"woehrl01/pod-pacemaker/pkg/expression"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// the cpu and io load are measured in percent
+var maxPercent = resource.MustParse("100")
+
+// Validate checks the values which the CRD schema can't express, e.g. that a percentage doesn't exceed 100
+func (s *PacemakerConfigSpec) Validate() field.ErrorList {
+	path := field.NewPath("spec")
+	errs := field.ErrorList{}
+
+	if s.NodeSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(s.NodeSelector); err != nil {
+			errs = append(errs, field.Invalid(path.Child("nodeSelector"), s.NodeSelector, err.Error()))
+		}
+	}
+	for i, t := range s.Throttlers {
+		errs = append(errs, t.Validate(path.Child("throttlers").Index(i))...)
+	}
+	names := map[string]bool{}
+	for i, group := range s.ThrottleGroups {
+		groupPath := path.Child("throttleGroups").Index(i)
+		if names[group.Name] {
+			errs = append(errs, field.Duplicate(groupPath.Child("name"), group.Name))
+		}
+		names[group.Name] = true
+		errs = append(errs, group.Validate(groupPath)...)
+	}
+	scheduleNames := map[string]bool{}
+	for i, schedule := range s.Schedules {
+		schedulePath := path.Child("schedules").Index(i)
+		if scheduleNames[schedule.Name] {
+			errs = append(errs, field.Duplicate(schedulePath.Child("name"), schedule.Name))
+		}
+		scheduleNames[schedule.Name] = true
+		errs = append(errs, schedule.Validate(schedulePath)...)
+	}
+	if s.Warmup != nil {
+		errs = append(errs, s.Warmup.Validate(path.Child("warmup"))...)
+	}
+	return errs
+}
+
+var oneFraction = resource.MustParse("1")
+
+func (w *Warmup) Validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if w.Duration.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("duration"), w.Duration.Duration.String(), "must be a positive duration, e.g. 10m"))
+	}
+	errs = append(errs, validatePositive(path.Child("initialFraction"), w.InitialFraction)...)
+	if w.InitialFraction.Cmp(oneFraction) > 0 {
+		errs = append(errs, field.Invalid(path.Child("initialFraction"), w.InitialFraction.String(), "must not exceed 1"))
+	}
+	if w.Mode != "" && w.Mode != WarmupLinear && w.Mode != WarmupStepwise {
+		errs = append(errs, field.NotSupported(path.Child("mode"), w.Mode, []string{WarmupLinear, WarmupStepwise}))
+	}
+	if w.Steps < 0 {
+		errs = append(errs, field.Invalid(path.Child("steps"), w.Steps, "must be at least 1"))
+	}
+	if w.From != "" && w.From != WarmupFromNodeReady && w.From != WarmupFromBoot {
+		errs = append(errs, field.NotSupported(path.Child("from"), w.From, []string{WarmupFromNodeReady, WarmupFromBoot}))
+	}
+	return errs
+}
+
+func (s *Schedule) Validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if s.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), "the name identifies the schedule"))
+	}
+	if s.Duration != nil && s.Duration.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("duration"), s.Duration.Duration.String(), "must be a positive duration, e.g. 8h"))
+	}
+	if _, err := s.Window(); err != nil {
+		errs = append(errs, field.Invalid(path, s.Name, err.Error()))
+	}
+	for i, t := range s.Throttlers {
+		errs = append(errs, t.Validate(path.Child("throttlers").Index(i))...)
+	}
+	return errs
+}
+
+func (g *ThrottleGroup) Validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if g.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), "the name identifies the group"))
+	}
+	if g.NamespaceSelector == nil && g.PodSelector == nil && g.Expression == "" {
+		errs = append(errs, field.Required(path, "one of namespaceSelector, podSelector or expression is required, a group without them would match all pods"))
+	}
+	for name, selector := range map[string]*metav1.LabelSelector{"namespaceSelector": g.NamespaceSelector, "podSelector": g.PodSelector} {
+		if selector == nil {
+			continue
+		}
+		if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+			errs = append(errs, field.Invalid(path.Child(name), selector, err.Error()))
+		}
+	}
+	if g.Expression != "" {
+		if _, err := expression.CompilePodExpression(g.Expression); err != nil {
+			errs = append(errs, field.Invalid(path.Child("expression"), g.Expression, err.Error()))
+		}
+	}
+	for i, t := range g.Throttlers {
+		errs = append(errs, t.Validate(path.Child("throttlers").Index(i))...)
+	}
+	return errs
+}
+
+func (t *Throttler) Validate(path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	set := 0
+	if t.RateLimit != nil {
+		set++
+		if t.RateLimit.FillFactor.Duration <= 0 {
+			errs = append(errs, field.Invalid(path.Child("rateLimit", "fillFactor"), t.RateLimit.FillFactor.Duration.String(), "must be a positive duration, e.g. 1s"))
+		}
+		if t.RateLimit.Burst < 1 {
+			errs = append(errs, field.Invalid(path.Child("rateLimit", "burst"), t.RateLimit.Burst, "must be at least 1"))
+		}
+	}
+	if t.MaxConcurrent != nil {
+		set++
+		if t.MaxConcurrent.Value < 0 {
+			errs = append(errs, field.Invalid(path.Child("maxConcurrent", "value"), t.MaxConcurrent.Value, "must be at least 1"))
+		}
+		if t.MaxConcurrent.PerCore != nil {
+			errs = append(errs, validatePositive(path.Child("maxConcurrent", "perCore"), *t.MaxConcurrent.PerCore)...)
+		} else if t.MaxConcurrent.Value == 0 {
+			errs = append(errs, field.Required(path.Child("maxConcurrent"), "either value or perCore is required"))
+		}
+	}
+	if t.Cpu != nil {
+		set++
+		errs = append(errs, validateLoad(path.Child("cpu"), t.Cpu.MaxLoad, t.Cpu.IncrementBy, &maxPercent)...)
+	}
+	if t.IO != nil {
+		set++
+		errs = append(errs, validateLoad(path.Child("io"), t.IO.MaxLoad, t.IO.IncrementBy, &maxPercent)...)
+	}
+	if t.LoadAvg != nil {
+		set++
+		errs = append(errs, validateLoad(path.Child("loadAvg"), t.LoadAvg.MaxLoad, t.LoadAvg.IncrementBy, nil)...)
+	}
+	if t.Cel != nil {
+		set++
+		if _, err := expression.CompileConditionExpression(t.Cel.Expression); err != nil {
+			errs = append(errs, field.Invalid(path.Child("cel", "expression"), t.Cel.Expression, err.Error()))
+		}
+	}
+	if t.AnyOf != nil {
+		set++
+		errs = append(errs, validateComposition(path.Child("anyOf"), t.AnyOf)...)
+	}
+	if t.AllOf != nil {
+		set++
+		errs = append(errs, validateComposition(path.Child("allOf"), t.AllOf)...)
+	}
+
+	if set != 1 {
+		errs = append(errs, field.Invalid(path, set, "exactly one of rateLimit, maxConcurrent, cpu, io, loadAvg, cel, anyOf or allOf must be set"))
+	}
+	return errs
+}
+
+func validateComposition(path *field.Path, throttlers []Throttler) field.ErrorList {
+	if len(throttlers) == 0 {
+		return field.ErrorList{field.Required(path, "at least one throttler is required")}
+	}
+	errs := field.ErrorList{}
+	for i, t := range throttlers {
+		errs = append(errs, t.Validate(path.Index(i))...)
+	}
+	return errs
+}
+
+// validateLoad checks the limit of a load based throttler, upperBound is optional
+func validateLoad(path *field.Path, maxLoad resource.Quantity, incrementBy *resource.Quantity, upperBound *resource.Quantity) field.ErrorList {
+	errs := validatePositive(path.Child("maxLoad"), maxLoad)
+	if upperBound != nil && maxLoad.Cmp(*upperBound) > 0 {
+		errs = append(errs, field.Invalid(path.Child("maxLoad"), maxLoad.String(), fmt.Sprintf("is a percentage and must not exceed %s", upperBound)))
+	}
+	if incrementBy != nil && incrementBy.Sign() < 0 {
+		errs = append(errs, field.Invalid(path.Child("incrementBy"), incrementBy.String(), "must not be negative"))
+	}
+	return errs
+}
+
+func validatePositive(path *field.Path, value resource.Quantity) field.ErrorList {
+	if value.Sign() <= 0 {
+		return field.ErrorList{field.Invalid(path, value.String(), "must be a positive number")}
+	}
+	return nil
+}
@@ -0,0 +1,456 @@
+//go:build !ignore_autogenerated
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CelCondition) DeepCopyInto(out *CelCondition) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CelCondition.
+func (in *CelCondition) DeepCopy() *CelCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(CelCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CniSettings) DeepCopyInto(out *CniSettings) {
+	*out = *in
+	if in.NamespaceExclusions != nil {
+		in, out := &in.NamespaceExclusions, &out.NamespaceExclusions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxWaitTimeInSeconds != nil {
+		in, out := &in.MaxWaitTimeInSeconds, &out.MaxWaitTimeInSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SuccessOnConnectionTimeout != nil {
+		in, out := &in.SuccessOnConnectionTimeout, &out.SuccessOnConnectionTimeout
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DisableThrottling != nil {
+		in, out := &in.DisableThrottling, &out.DisableThrottling
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CniSettings.
+func (in *CniSettings) DeepCopy() *CniSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(CniSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadAvgLimit) DeepCopyInto(out *LoadAvgLimit) {
+	*out = *in
+	out.MaxLoad = in.MaxLoad.DeepCopy()
+	if in.IncrementBy != nil {
+		in, out := &in.IncrementBy, &out.IncrementBy
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadAvgLimit.
+func (in *LoadAvgLimit) DeepCopy() *LoadAvgLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadAvgLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadLimit) DeepCopyInto(out *LoadLimit) {
+	*out = *in
+	out.MaxLoad = in.MaxLoad.DeepCopy()
+	if in.IncrementBy != nil {
+		in, out := &in.IncrementBy, &out.IncrementBy
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadLimit.
+func (in *LoadLimit) DeepCopy() *LoadLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaxConcurrent) DeepCopyInto(out *MaxConcurrent) {
+	*out = *in
+	if in.PerCore != nil {
+		in, out := &in.PerCore, &out.PerCore
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaxConcurrent.
+func (in *MaxConcurrent) DeepCopy() *MaxConcurrent {
+	if in == nil {
+		return nil
+	}
+	out := new(MaxConcurrent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PacemakerConfig) DeepCopyInto(out *PacemakerConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacemakerConfig.
+func (in *PacemakerConfig) DeepCopy() *PacemakerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PacemakerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PacemakerConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PacemakerConfigList) DeepCopyInto(out *PacemakerConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PacemakerConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacemakerConfigList.
+func (in *PacemakerConfigList) DeepCopy() *PacemakerConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(PacemakerConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PacemakerConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PacemakerConfigSpec) DeepCopyInto(out *PacemakerConfigSpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Throttlers != nil {
+		in, out := &in.Throttlers, &out.Throttlers
+		*out = make([]Throttler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ThrottleGroups != nil {
+		in, out := &in.ThrottleGroups, &out.ThrottleGroups
+		*out = make([]ThrottleGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Schedules != nil {
+		in, out := &in.Schedules, &out.Schedules
+		*out = make([]Schedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Warmup != nil {
+		in, out := &in.Warmup, &out.Warmup
+		*out = new(Warmup)
+		(*in).DeepCopyInto(*out)
+	}
+	in.CniSettings.DeepCopyInto(&out.CniSettings)
+	in.SkipPolicy.DeepCopyInto(&out.SkipPolicy)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacemakerConfigSpec.
+func (in *PacemakerConfigSpec) DeepCopy() *PacemakerConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PacemakerConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PacemakerConfigStatus) DeepCopyInto(out *PacemakerConfigStatus) {
+	*out = *in
+	if in.EffectiveNodes != nil {
+		in, out := &in.EffectiveNodes, &out.EffectiveNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ShadowedNodes != nil {
+		in, out := &in.ShadowedNodes, &out.ShadowedNodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PacemakerConfigStatus.
+func (in *PacemakerConfigStatus) DeepCopy() *PacemakerConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PacemakerConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+	out.FillFactor = in.FillFactor
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Schedule) DeepCopyInto(out *Schedule) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Weekdays != nil {
+		in, out := &in.Weekdays, &out.Weekdays
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Throttlers != nil {
+		in, out := &in.Throttlers, &out.Throttlers
+		*out = make([]Throttler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Schedule.
+func (in *Schedule) DeepCopy() *Schedule {
+	if in == nil {
+		return nil
+	}
+	out := new(Schedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SkipPolicy) DeepCopyInto(out *SkipPolicy) {
+	*out = *in
+	if in.NamespaceSelectors != nil {
+		in, out := &in.NamespaceSelectors, &out.NamespaceSelectors
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PodSelectors != nil {
+		in, out := &in.PodSelectors, &out.PodSelectors
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OwnerKinds != nil {
+		in, out := &in.OwnerKinds, &out.OwnerKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PriorityClassNames != nil {
+		in, out := &in.PriorityClassNames, &out.PriorityClassNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkipPolicy.
+func (in *SkipPolicy) DeepCopy() *SkipPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SkipPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ThrottleGroup) DeepCopyInto(out *ThrottleGroup) {
+	*out = *in
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodSelector != nil {
+		in, out := &in.PodSelector, &out.PodSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Throttlers != nil {
+		in, out := &in.Throttlers, &out.Throttlers
+		*out = make([]Throttler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThrottleGroup.
+func (in *ThrottleGroup) DeepCopy() *ThrottleGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(ThrottleGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Throttler) DeepCopyInto(out *Throttler) {
+	*out = *in
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		**out = **in
+	}
+	if in.MaxConcurrent != nil {
+		in, out := &in.MaxConcurrent, &out.MaxConcurrent
+		*out = new(MaxConcurrent)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cpu != nil {
+		in, out := &in.Cpu, &out.Cpu
+		*out = new(LoadLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IO != nil {
+		in, out := &in.IO, &out.IO
+		*out = new(LoadLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LoadAvg != nil {
+		in, out := &in.LoadAvg, &out.LoadAvg
+		*out = new(LoadAvgLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cel != nil {
+		in, out := &in.Cel, &out.Cel
+		*out = new(CelCondition)
+		**out = **in
+	}
+	if in.AnyOf != nil {
+		in, out := &in.AnyOf, &out.AnyOf
+		*out = make([]Throttler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllOf != nil {
+		in, out := &in.AllOf, &out.AllOf
+		*out = make([]Throttler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Throttler.
+func (in *Throttler) DeepCopy() *Throttler {
+	if in == nil {
+		return nil
+	}
+	out := new(Throttler)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Warmup) DeepCopyInto(out *Warmup) {
+	*out = *in
+	out.Duration = in.Duration
+	out.InitialFraction = in.InitialFraction.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Warmup.
+func (in *Warmup) DeepCopy() *Warmup {
+	if in == nil {
+		return nil
+	}
+	out := new(Warmup)
+	in.DeepCopyInto(out)
+	return out
+}
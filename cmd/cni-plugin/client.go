@@ -3,17 +3,28 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protojson"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	pb "woehrl01/pod-pacemaker/proto"
 )
 
+const (
+	settingsTimeout = 2 * time.Second
+	// settingsRefreshInterval is the age of the cached settings after which they are fetched from the daemon again
+	settingsRefreshInterval = 30 * time.Second
+	settingsCacheFile       = "settings.json"
+)
+
 func WaitForSlot(ctx context.Context, slotName string, config *PluginConf) error {
 	conn, err := WaitUntilConnected(ctx, config.DaemonSocketPath)
 	if err != nil {
@@ -33,12 +44,120 @@ func WaitForSlot(ctx context.Context, slotName string, config *PluginConf) error
 	}
 
 	if !r.Success {
-		return fmt.Errorf("failed to acquire slot: %s", r.Message)
+		return fmt.Errorf("failed to acquire slot: %s (%s)", r.Message, formatBreakdown(r.Breakdown))
+	}
+
+	logrus.Debugf("Acquired slot %s (%s)", slotName, formatBreakdown(r.Breakdown))
+	return nil
+}
+
+// formatBreakdown describes the time spent in each throttler, e.g. "rateLimit=0s loadAvg=1m12s"
+func formatBreakdown(stages []*pb.StageWait) string {
+	if len(stages) == 0 {
+		return "no throttler"
+	}
+	parts := make([]string, 0, len(stages))
+	for _, stage := range stages {
+		parts = append(parts, fmt.Sprintf("%s=%s", stage.Throttler, stage.Duration.AsDuration().Round(time.Millisecond)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ApplyDaemonSettings overrides the settings of the CNI configuration with the ones served by the daemon.
+// The settings are cached in a file next to the socket and only fetched again once the cache is older than
+// settingsRefreshInterval, the cached settings also apply while the daemon is unreachable.
+func ApplyDaemonSettings(ctx context.Context, config *PluginConf) error {
+	path := settingsCachePath(config.DaemonSocketPath)
+	settings, fetchedAt, err := readCachedSettings(path)
+	if err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("Failed to read cached settings %s: %v", path, err)
+	}
+	if settings == nil || time.Since(fetchedAt) > settingsRefreshInterval {
+		fetched, err := fetchDaemonSettings(ctx, config.DaemonSocketPath)
+		if err != nil && settings == nil {
+			return err
+		}
+		if err != nil {
+			logrus.Warnf("Failed to get settings from daemon, using the cached settings of %s: %v", fetchedAt.Format(time.RFC3339), err)
+		} else {
+			settings = fetched
+			if err := writeCachedSettings(path, settings); err != nil {
+				logrus.Warnf("Failed to cache settings in %s: %v", path, err)
+			}
+		}
 	}
 
+	if settings.NamespaceExclusions != nil {
+		config.NamespaceExclusions = settings.NamespaceExclusions.Namespaces
+	}
+	if settings.MaxWaitTimeInSeconds != nil {
+		config.MaxWaitTimeInSeconds = *settings.MaxWaitTimeInSeconds
+	}
+	if settings.SuccessOnConnectionTimeout != nil {
+		config.SuccessOnConnectionTimeout = *settings.SuccessOnConnectionTimeout
+	}
+	if settings.DisableThrottling != nil {
+		config.DisableThrottling = *settings.DisableThrottling
+	}
 	return nil
 }
 
+func fetchDaemonSettings(ctx context.Context, socketPath string) (*pb.SettingsResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, settingsTimeout)
+	defer cancel()
+
+	conn, err := WaitUntilConnected(ctx, socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return pb.NewPodLimiterClient(conn).GetSettings(ctx, &pb.SettingsRequest{})
+}
+
+// settingsCachePath returns the file of the cached settings, which lives next to the daemon socket
+func settingsCachePath(daemonSocketPath string) string {
+	return filepath.Join(filepath.Dir(daemonSocketPath), settingsCacheFile)
+}
+
+// readCachedSettings returns the cached settings and when they were fetched
+func readCachedSettings(path string) (*pb.SettingsResponse, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	settings := &pb.SettingsResponse{}
+	if err := protojson.Unmarshal(content, settings); err != nil {
+		return nil, time.Time{}, err
+	}
+	return settings, info.ModTime(), nil
+}
+
+// writeCachedSettings replaces the cached settings at once, other plugin processes may read them at the same time
+func writeCachedSettings(path string, settings *pb.SettingsResponse) error {
+	content, err := protojson.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 func isConnectionError(err error) bool {
 	errorMsg := err.Error()
 	return strings.Contains(errorMsg, "connection refused") ||
@@ -56,6 +175,7 @@ func WaitUntilConnected(ctx context.Context, socketPath string) (*grpc.ClientCon
 	err := wait.PollUntilContextCancel(ctx, time.Second, true, func(ctx context.Context) (bool, error) {
 		c, err := grpc.DialContext(ctx, server,
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 		)
 		if err != nil {
 			connErr = err
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "woehrl01/pod-pacemaker/proto"
+
+	"google.golang.org/grpc"
+)
+
+// settingsServer serves the settings like the daemon
+type settingsServer struct {
+	pb.UnimplementedPodLimiterServer
+	settings *pb.SettingsResponse
+}
+
+func (s *settingsServer) GetSettings(ctx context.Context, in *pb.SettingsRequest) (*pb.SettingsResponse, error) {
+	return s.settings, nil
+}
+
+// startDaemon serves the settings on the socket until stop is called
+func startDaemon(t *testing.T, socket string, settings *pb.SettingsResponse) (stop func()) {
+	t.Helper()
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterPodLimiterServer(s, &settingsServer{settings: settings})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return s.Stop
+}
+
+func waitTime(seconds int32) *pb.SettingsResponse {
+	return &pb.SettingsResponse{MaxWaitTimeInSeconds: &seconds}
+}
+
+func TestApplyDaemonSettingsCachesTheSettings(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "pod-pacemaker.sock")
+	stop := startDaemon(t, socket, waitTime(60))
+
+	config := &PluginConf{DaemonSocketPath: socket, MaxWaitTimeInSeconds: 10}
+	if err := ApplyDaemonSettings(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxWaitTimeInSeconds != 60 {
+		t.Errorf("MaxWaitTimeInSeconds = %d, want 60 of the daemon", config.MaxWaitTimeInSeconds)
+	}
+
+	// the daemon restarts, the settings it served last still apply
+	stop()
+	config = &PluginConf{DaemonSocketPath: socket, MaxWaitTimeInSeconds: 10}
+	if err := ApplyDaemonSettings(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxWaitTimeInSeconds != 60 {
+		t.Errorf("MaxWaitTimeInSeconds = %d without the daemon, want 60 of the cache", config.MaxWaitTimeInSeconds)
+	}
+
+	// an outdated cache is used as well, as long as the daemon is unreachable
+	outdated := time.Now().Add(-2 * settingsRefreshInterval)
+	if err := os.Chtimes(settingsCachePath(socket), outdated, outdated); err != nil {
+		t.Fatal(err)
+	}
+	config = &PluginConf{DaemonSocketPath: socket, MaxWaitTimeInSeconds: 10}
+	if err := ApplyDaemonSettings(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxWaitTimeInSeconds != 60 {
+		t.Errorf("MaxWaitTimeInSeconds = %d with an outdated cache, want 60", config.MaxWaitTimeInSeconds)
+	}
+}
+
+func TestApplyDaemonSettingsRefreshesAnOutdatedCache(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "pod-pacemaker.sock")
+	startDaemon(t, socket, waitTime(120))
+	if err := writeCachedSettings(settingsCachePath(socket), waitTime(60)); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &PluginConf{DaemonSocketPath: socket}
+	if err := ApplyDaemonSettings(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxWaitTimeInSeconds != 60 {
+		t.Errorf("MaxWaitTimeInSeconds = %d, want 60 of the recent cache without asking the daemon", config.MaxWaitTimeInSeconds)
+	}
+
+	outdated := time.Now().Add(-2 * settingsRefreshInterval)
+	if err := os.Chtimes(settingsCachePath(socket), outdated, outdated); err != nil {
+		t.Fatal(err)
+	}
+	if err := ApplyDaemonSettings(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxWaitTimeInSeconds != 120 {
+		t.Errorf("MaxWaitTimeInSeconds = %d, want 120 of the daemon", config.MaxWaitTimeInSeconds)
+	}
+}
+
+func TestApplyDaemonSettingsWithoutDaemonAndCache(t *testing.T) {
+	config := &PluginConf{DaemonSocketPath: filepath.Join(t.TempDir(), "pod-pacemaker.sock"), MaxWaitTimeInSeconds: 10}
+	if err := ApplyDaemonSettings(context.Background(), config); err == nil {
+		t.Errorf("got no error without the daemon and the cache")
+	}
+	if config.MaxWaitTimeInSeconds != 10 {
+		t.Errorf("MaxWaitTimeInSeconds = %d, want 10 of the CNI configuration", config.MaxWaitTimeInSeconds)
+	}
+}
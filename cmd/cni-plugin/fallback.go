@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/fallback"
+
+	"github.com/sirupsen/logrus"
+)
+
+type LocalFallbackConf struct {
+	MaxConcurrent     int   `json:"maxConcurrent"`
+	HoldTimeInSeconds int32 `json:"holdTimeInSeconds"`
+}
+
+// newLocalFallback returns the file based semaphore which is used if the daemon is unreachable, or nil if disabled
+func newLocalFallback(conf *PluginConf) *fallback.Semaphore {
+	if conf.LocalFallback == nil || conf.LocalFallback.MaxConcurrent <= 0 {
+		return nil
+	}
+	holdTime := time.Duration(conf.LocalFallback.HoldTimeInSeconds) * time.Second
+	if holdTime <= 0 {
+		holdTime = time.Minute
+	}
+	return fallback.NewSemaphore(fallback.Dir(conf.DaemonSocketPath), conf.LocalFallback.MaxConcurrent, holdTime)
+}
+
+// tryLocalFallback tries to acquire a slot without the daemon
+func tryLocalFallback(semaphore *fallback.Semaphore, slotName string) bool {
+	if semaphore == nil {
+		return false
+	}
+	acquired, err := semaphore.TryAcquire(slotName)
+	if err != nil {
+		logrus.Errorf("Failed to use local fallback for slot %s: %v", slotName, err)
+		return false
+	}
+	return acquired
+}
+
+// releaseLocalFallback removes the slot or the waiter registration of the slot
+func releaseLocalFallback(semaphore *fallback.Semaphore, slotName string) {
+	if semaphore == nil {
+		return
+	}
+	if err := semaphore.Release(slotName); err != nil {
+		logrus.Warnf("Failed to release local fallback slot %s: %v", slotName, err)
+	}
+}
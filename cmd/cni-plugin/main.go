@@ -15,6 +15,11 @@ import (
 	"github.com/containernetworking/cni/pkg/version"
 	bv "github.com/containernetworking/plugins/pkg/utils/buildversion"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"woehrl01/pod-pacemaker/pkg/tracing"
 )
 
 type PluginConf struct {
@@ -29,6 +34,16 @@ type PluginConf struct {
 	NamespaceExclusions        []string `json:"namespaceExclusions"`
 	SuccessOnConnectionTimeout bool     `json:"successOnConnectionTimeout"`
 	DisableThrottling          bool     `json:"disableThrottling"`
+
+	LocalFallback *LocalFallbackConf `json:"localFallback"`
+	Tracing       *TracingConf       `json:"tracing"`
+}
+
+type TracingConf struct {
+	Exporter    string  `json:"exporter"`
+	Endpoint    string  `json:"endpoint"`
+	Insecure    bool    `json:"insecure"`
+	SampleRatio float64 `json:"sampleRatio"`
 }
 
 type K8sArgs struct {
@@ -98,9 +113,31 @@ func cmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
+	shutdownTracing := setupTracing(conf)
+	defer shutdownTracing()
+
+	traceCtx, span := tracing.Tracer().Start(context.Background(), "cni add", trace.WithAttributes(
+		attribute.String("container.id", args.ContainerID),
+	))
+	defer span.End()
+
+	err = waitForStartup(traceCtx, args, conf)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return types.PrintResult(result, conf.CNIVersion)
+}
+
+// waitForStartup blocks until the pod may start, it returns an error if it must not start yet
+func waitForStartup(traceCtx context.Context, args *skel.CmdArgs, conf *PluginConf) error {
+	if err := ApplyDaemonSettings(traceCtx, conf); err != nil {
+		logrus.Warnf("Failed to get settings from daemon, using the CNI configuration: %v", err)
+	}
+
 	if conf.DisableThrottling {
 		logrus.Infof("Throttling disabled")
-		return types.PrintResult(result, conf.CNIVersion)
+		return nil
 	}
 
 	var k8sArgs K8sArgs
@@ -111,34 +148,67 @@ func cmdAdd(args *skel.CmdArgs) error {
 
 	if shouldSkipThrotteling(conf, &k8sArgs) {
 		logrus.Infof("Skipping throttling for %s/%s", string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
-		return types.PrintResult(result, conf.CNIVersion)
+		return nil
 	}
 
 	slotName := fmt.Sprintf("%s/%s", string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
 	logrus.Infof("Waiting for slot %s", slotName)
+	trace.SpanFromContext(traceCtx).SetAttributes(
+		attribute.String("k8s.namespace.name", string(k8sArgs.K8S_POD_NAMESPACE)),
+		attribute.String("k8s.pod.name", string(k8sArgs.K8S_POD_NAME)),
+	)
 
-	ctx, totalRequestCancel := context.WithTimeout(context.Background(), time.Second*time.Duration(conf.MaxWaitTimeInSeconds))
+	ctx, totalRequestCancel := context.WithTimeout(traceCtx, time.Second*time.Duration(conf.MaxWaitTimeInSeconds))
 	defer totalRequestCancel()
 
+	localFallback := newLocalFallback(conf)
+
 	for {
 		if err := WaitForSlot(ctx, slotName, conf); err != nil {
 			if ctx.Err() == nil && isConnectionError(err) {
+				if tryLocalFallback(localFallback, slotName) {
+					logrus.Warnf("Failed to connect to daemon, acquired local fallback slot %s", slotName)
+					return nil
+				}
 				logrus.Warnf("Failed to connect to daemon, retrying: %v", err)
 				// random backoff
 				backoff := time.Duration(rand.Intn(5)) * time.Second
 				time.Sleep(backoff)
 				continue
 			}
+			releaseLocalFallback(localFallback, slotName)
 			logrus.Errorf("Failed to acquire slot %s: %v", slotName, err)
 			return err
 		}
 		break
 	}
+	releaseLocalFallback(localFallback, slotName) // we might have been waiting for a local slot before the daemon was reachable
 	logrus.Infof("Acquired slot %s", slotName)
-	return types.PrintResult(result, conf.CNIVersion)
+	return nil
 }
 
 func cmdDel(args *skel.CmdArgs) error {
+	conf, err := parseConfig(args.StdinData)
+	if err != nil {
+		return nil // nothing to clean up
+	}
+
+	localFallback := newLocalFallback(conf)
+	if localFallback == nil {
+		return nil
+	}
+
+	var k8sArgs K8sArgs
+	if err := types.LoadArgs(args.Args, &k8sArgs); err != nil {
+		return nil
+	}
+
+	if err := setupLogging(); err != nil {
+		return err
+	}
+
+	slotName := fmt.Sprintf("%s/%s", string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME))
+	releaseLocalFallback(localFallback, slotName)
 	return nil
 }
 
@@ -159,8 +229,11 @@ func callChain(conf *PluginConf) (*current.Result, error) {
 }
 
 func shouldSkipThrotteling(conf *PluginConf, k8sArgs *K8sArgs) bool {
-	if v, ok := conf.RuntimeConfig.PodAnnotations["pod-pacemaker/skip"]; ok {
-		return v == "true"
+	// not every runtime passes the pod annotations, the daemon checks the annotation as well
+	if conf.RuntimeConfig != nil {
+		if v, ok := conf.RuntimeConfig.PodAnnotations["pod-pacemaker/skip"]; ok {
+			return v == "true"
+		}
 	}
 
 	if slices.Contains(conf.NamespaceExclusions, string(k8sArgs.K8S_POD_NAMESPACE)) {
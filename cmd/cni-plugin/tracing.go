@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"woehrl01/pod-pacemaker/pkg/tracing"
+)
+
+// the plugin exits right after the request, so the spans are flushed synchronously within this time
+const tracingFlushTimeout = 2 * time.Second
+
+// setupTracing installs the exporter of the CNI configuration and returns a func which flushes the spans
+func setupTracing(conf *PluginConf) func() {
+	if conf.Tracing == nil {
+		return func() {}
+	}
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    conf.Tracing.Exporter,
+		Endpoint:    conf.Tracing.Endpoint,
+		Insecure:    conf.Tracing.Insecure,
+		SampleRatio: conf.Tracing.SampleRatio,
+		ServiceName: "pod-pacemaker-cni-plugin",
+	})
+	if err != nil {
+		logrus.Warnf("Failed to setup tracing, continuing without: %v", err)
+		return func() {}
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			logrus.Warnf("Failed to flush traces: %v", err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/coordinator"
+	pb "woehrl01/pod-pacemaker/proto"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const coordinatorLeaseName = "pod-pacemaker-coordinator"
+
+// followerConnectionAge closes the connections to a replica which isn't the leader, so the daemons which the service
+// routed to it before its readiness changed connect again
+const followerConnectionAge = 5 * time.Second
+
+var (
+	port             = flag.Int("port", 9100, "The port of the gRPC server the daemons connect to")
+	metricsPort      = flag.Int("metrics-port", 9000, "The port for the metrics and health endpoints")
+	budgetsFile      = flag.String("budgets", "/etc/pod-pacemaker/budgets.yaml", "The file with the budgets")
+	leaseTTL         = flag.Duration("lease-ttl", time.Minute, "How long a lease is kept without a renewal of its daemon")
+	recoveryPeriod   = flag.Duration("recovery-period", 15*time.Second, "How long a new leader waits for the renewals of the daemons before it grants new leases")
+	leaderElectionNs = flag.String("leader-election-namespace", os.Getenv("POD_NAMESPACE"), "The namespace of the lease of the leader election, defaults to POD_NAMESPACE")
+	debugLogging     = flag.Bool("debug-logging", false, "Enable debug logging")
+)
+
+func main() {
+	flag.Parse()
+	if *debugLogging {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	budgets, err := coordinator.LoadConfig(*budgetsFile)
+	if err != nil {
+		log.Fatalf("Failed to load the budgets: %v", err)
+	}
+	if *leaderElectionNs == "" {
+		log.Fatal("No --leader-election-namespace set")
+	}
+	identity, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("Failed to get the hostname: %v", err)
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		log.Fatalf("Failed to get kubernetes config: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("Failed to create kubernetes client: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	server := &coordinatorServer{}
+	// roleChanged is signalled when this replica becomes or stops being the leader
+	roleChanged := make(chan struct{}, 1)
+	signalRoleChange := func() {
+		select {
+		case roleChanged <- struct{}{}:
+		default:
+		}
+	}
+	go startMetricsServer(server, ctx.Done())
+	go runLeaderElection(ctx, clientset, identity, func(ctx context.Context) {
+		c := coordinator.New(ctx, budgets, *leaseTTL, *recoveryPeriod)
+		server.leader.Store(c)
+		signalRoleChange()
+		log.Infof("Granting leases of %d budgets", len(budgets.Budgets))
+		c.Run(ctx)
+		server.leader.Store(nil)
+		signalRoleChange()
+	})
+
+	for ctx.Err() == nil {
+		serve(ctx, server, roleChanged)
+	}
+}
+
+// serve serves the daemons until the context is done or the role of this replica changes.
+// The server is stopped gracefully on a change, which closes the connections with a GOAWAY, so the daemons connect
+// again through the service instead of staying with a replica which isn't the leader anymore.
+func serve(ctx context.Context, server *coordinatorServer, roleChanged <-chan struct{}) {
+	var opts []grpc.ServerOption
+	if server.leader.Load() == nil {
+		opts = append(opts, grpc.KeepaliveParams(keepalive.ServerParameters{
+			MaxConnectionAge:      followerConnectionAge,
+			MaxConnectionAgeGrace: followerConnectionAge,
+		}))
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", *port))
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterCoordinatorServer(s, server)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-roleChanged:
+		}
+		// the waiters of the leases were aborted with the leadership, so no request blocks the stop
+		s.GracefulStop()
+	}()
+
+	log.Printf("server listening at %v", lis.Addr())
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+}
+
+// runLeaderElection takes part in the leader election until the context is done, lead runs while this replica is the leader
+func runLeaderElection(ctx context.Context, clientset kubernetes.Interface, identity string, lead func(ctx context.Context)) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: coordinatorLeaseName, Namespace: *leaderElectionNs},
+		Client:     clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: lead,
+				OnStoppedLeading: func() {
+					log.Infof("Stopped granting leases")
+				},
+			},
+		})
+	}
+}
+
+func startMetricsServer(server *coordinatorServer, stopper <-chan struct{}) {
+	mux := http.NewServeMux()
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", *metricsPort),
+		Handler: mux,
+	}
+
+	prometheus.MustRegister(&budgetCollector{usage: server.usage})
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	// only the leader is ready, so the service routes the daemons to it
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if server.leader.Load() == nil {
+			http.Error(w, "not the leader", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/debug/budgets", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(server.usage()); err != nil {
+			log.Warnf("Failed to encode response: %v", err)
+		}
+	})
+
+	go func() {
+		<-stopper
+		srv.Shutdown(context.Background())
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Failed to serve the metrics: %v", err)
+	}
+}
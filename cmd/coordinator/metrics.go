@@ -0,0 +1,54 @@
+package main
+
+import (
+	"woehrl01/pod-pacemaker/pkg/coordinator"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	leasesGranted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pod_pacemaker_coordinator_leases_granted",
+		Help: "Leases granted to the daemons",
+	})
+
+	// the metrics are summed up per budget, the keys of a budget are unbounded, e.g. one per owner
+	budgetLabels        = []string{"budget"}
+	budgetActiveDesc    = prometheus.NewDesc("pod_pacemaker_coordinator_active_leases", "Leases which count against the budget, summed up over its keys", budgetLabels, nil)
+	budgetWaitersDesc   = prometheus.NewDesc("pod_pacemaker_coordinator_waiters", "Pods which are waiting for the budget, summed up over its keys", budgetLabels, nil)
+	budgetExhaustedDesc = prometheus.NewDesc("pod_pacemaker_coordinator_exhausted_keys", "Keys of the budget whose leases reached the limit", budgetLabels, nil)
+)
+
+// budgetCollector reads the usage of the budgets at scrape time
+type budgetCollector struct {
+	usage func() []coordinator.Usage
+}
+
+func (c *budgetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- budgetActiveDesc
+	ch <- budgetWaitersDesc
+	ch <- budgetExhaustedDesc
+}
+
+func (c *budgetCollector) Collect(ch chan<- prometheus.Metric) {
+	type total struct{ active, waiters, exhausted int }
+	totals := map[string]*total{}
+	for _, usage := range c.usage() {
+		t, ok := totals[usage.Budget]
+		if !ok {
+			t = &total{}
+			totals[usage.Budget] = t
+		}
+		t.active += usage.Active
+		t.waiters += usage.Waiters
+		if usage.Active >= usage.Limit {
+			t.exhausted++
+		}
+	}
+	for budget, t := range totals {
+		ch <- prometheus.MustNewConstMetric(budgetActiveDesc, prometheus.GaugeValue, float64(t.active), budget)
+		ch <- prometheus.MustNewConstMetric(budgetWaitersDesc, prometheus.GaugeValue, float64(t.waiters), budget)
+		ch <- prometheus.MustNewConstMetric(budgetExhaustedDesc, prometheus.GaugeValue, float64(t.exhausted), budget)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"woehrl01/pod-pacemaker/pkg/coordinator"
+	pb "woehrl01/pod-pacemaker/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// coordinatorServer serves the leases while this replica is the leader, the other replicas answer with Unavailable
+type coordinatorServer struct {
+	pb.UnimplementedCoordinatorServer
+	leader atomic.Pointer[coordinator.Coordinator]
+}
+
+var errNotLeader = status.Error(codes.Unavailable, "not the leader of the coordinators")
+
+func (s *coordinatorServer) AcquireLease(ctx context.Context, in *pb.AcquireLeaseRequest) (*pb.AcquireLeaseResponse, error) {
+	c := s.leader.Load()
+	if c == nil {
+		return nil, errNotLeader
+	}
+	if in.GetLease().GetNode() == "" || in.GetLease().GetSlotName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "the lease needs a node and a slot name")
+	}
+	budgets, err := c.Acquire(ctx, in.GetLease())
+	if errors.Is(err, coordinator.ErrNotLeader) {
+		return nil, errNotLeader
+	}
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
+	}
+	if s.leader.Load() != c {
+		// the leadership was lost while waiting, the lease is unknown to the next leader
+		return nil, errNotLeader
+	}
+	leasesGranted.Inc()
+	return &pb.AcquireLeaseResponse{Budgets: budgets}, nil
+}
+
+func (s *coordinatorServer) ReleaseLease(ctx context.Context, in *pb.ReleaseLeaseRequest) (*pb.ReleaseLeaseResponse, error) {
+	c := s.leader.Load()
+	if c == nil {
+		return nil, errNotLeader
+	}
+	return &pb.ReleaseLeaseResponse{Released: c.Release(in.GetNode(), in.GetSlotName())}, nil
+}
+
+func (s *coordinatorServer) RenewLeases(ctx context.Context, in *pb.RenewLeasesRequest) (*pb.RenewLeasesResponse, error) {
+	c := s.leader.Load()
+	if c == nil {
+		return nil, errNotLeader
+	}
+	c.Renew(in.GetNode(), in.GetLeases())
+	return &pb.RenewLeasesResponse{}, nil
+}
+
+// usage returns the usage of the budgets, empty if this replica isn't the leader
+func (s *coordinatorServer) usage() []coordinator.Usage {
+	c := s.leader.Load()
+	if c == nil {
+		return []coordinator.Usage{}
+	}
+	return c.Usage()
+}
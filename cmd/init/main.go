@@ -26,6 +26,12 @@ var (
 	namespaceExclusions        = flag.StringSlice("namespace-exclusions", []string{"kube-system"}, "Namespaces to exclude from the CNI configuration")
 	successOnConnectionTimeout = flag.Bool("success-on-connection-timeout", true, "If true, there is no concurrency enforcement if the connection to the daemon times out")
 	disableThrottling          = flag.Bool("disable-throttle", false, "If true, the CNI plugin will not enforce throttling")
+	localFallbackMax           = flag.Int("local-fallback-max-concurrent", 0, "The maximum number of concurrent pod starts the CNI plugin enforces by itself if the daemon is unreachable (0 to disable)")
+	localFallbackHoldTime      = flag.Int32("local-fallback-hold-time-in-seconds", 60, "How long a pod start counts against the local fallback limit")
+	tracingExporter            = flag.String("tracing-exporter", "none", "The exporter for traces of the CNI plugin, either none or otlp")
+	tracingEndpoint            = flag.String("tracing-endpoint", "", "The host:port of the OTLP gRPC receiver, reachable from the host network")
+	tracingInsecure            = flag.Bool("tracing-insecure", false, "Disable TLS for the OTLP exporter")
+	tracingSampleRatio         = flag.Float64("tracing-sample-ratio", 1.0, "The fraction of CNI ADD requests which are traced")
 )
 
 func main() {
@@ -129,6 +135,22 @@ func generateCNIConfig(filePath string) error {
 		},
 	}
 
+	if *localFallbackMax > 0 {
+		config.Plugins[0].LocalFallback = &CniLocalFallback{
+			MaxConcurrent:     *localFallbackMax,
+			HoldTimeInSeconds: *localFallbackHoldTime,
+		}
+	}
+
+	if *tracingExporter != "none" {
+		config.Plugins[0].Tracing = &CniTracing{
+			Exporter:    *tracingExporter,
+			Endpoint:    *tracingEndpoint,
+			Insecure:    *tracingInsecure,
+			SampleRatio: *tracingSampleRatio,
+		}
+	}
+
 	configContent, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return err
@@ -153,6 +175,20 @@ type CniPlugin struct {
 	NamespaceExclusions        []string              `json:"namespaceExclusions"`
 	SuccessOnConnectionTimeout bool                  `json:"successOnConnectionTimeout"`
 	DisableThrottling          bool                  `json:"disableThrottling"`
+	LocalFallback              *CniLocalFallback     `json:"localFallback,omitempty"`
+	Tracing                    *CniTracing           `json:"tracing,omitempty"`
+}
+
+type CniLocalFallback struct {
+	MaxConcurrent     int   `json:"maxConcurrent"`
+	HoldTimeInSeconds int32 `json:"holdTimeInSeconds"`
+}
+
+type CniTracing struct {
+	Exporter    string  `json:"exporter"`
+	Endpoint    string  `json:"endpoint"`
+	Insecure    bool    `json:"insecure"`
+	SampleRatio float64 `json:"sampleRatio"`
 }
 
 type CniConfigCapabilities struct {
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pb "woehrl01/pod-pacemaker/proto"
+)
+
+// flightRecorderExportBatchSize is the number of entries per message of the export
+const flightRecorderExportBatchSize = 500
+
+// adminService exposes the state of the daemon for node-level inspection, e.g. by pacemakerctl
+type adminService struct {
+	pb.UnimplementedAdminServer
+	limiter *podLimitService
+}
+
+var _ pb.AdminServer = &adminService{}
+
+func NewAdminServer(limiter *podLimitService) *adminService {
+	return &adminService{
+		limiter: limiter,
+	}
+}
+
+func (a *adminService) ListSlots(ctx context.Context, in *pb.ListSlotsRequest) (*pb.ListSlotsResponse, error) {
+	response := &pb.ListSlotsResponse{}
+
+	lister, ok := a.limiter.throttler.(throttler.SlotLister)
+	if !ok {
+		for _, slot := range a.limiter.throttler.ActiveSlots() {
+			response.Slots = append(response.Slots, &pb.Slot{SlotName: slot})
+		}
+		return response, nil
+	}
+
+	slots := lister.Slots()
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].AcquiredAt.Before(slots[j].AcquiredAt)
+	})
+	for _, slot := range slots {
+		response.Slots = append(response.Slots, &pb.Slot{
+			SlotName:   slot.SlotId,
+			AcquiredAt: timestamppb.New(slot.AcquiredAt),
+			Throttler:  slot.Throttler,
+		})
+	}
+	return response, nil
+}
+
+func (a *adminService) ListWaiters(ctx context.Context, in *pb.ListWaitersRequest) (*pb.ListWaitersResponse, error) {
+	response := &pb.ListWaitersResponse{
+		AdmissionPaused: a.limiter.admission.IsPaused(),
+	}
+
+	now := time.Now()
+	for i, t := range a.limiter.tickets.Waiting() {
+		response.Waiters = append(response.Waiters, &pb.Waiter{
+			SlotName:  t.slotId,
+			Position:  int32(i + 1),
+			Elapsed:   durationpb.New(t.Waited(now)),
+			Attempts:  int32(t.attempts),
+			BlockedBy: a.limiter.blockedBy(t.slotId),
+		})
+	}
+	return response, nil
+}
+
+func (a *adminService) GetConfig(ctx context.Context, in *pb.GetConfigRequest) (*pb.GetConfigResponse, error) {
+	response := &pb.GetConfigResponse{}
+	if config := a.limiter.configs.CurrentConfig(); config != nil {
+		response.EffectiveConfig = config.Name
+	}
+	for _, evaluation := range a.limiter.configs.ConfigEvaluations() {
+		response.Evaluations = append(response.Evaluations, &pb.ConfigEvaluation{
+			Name:      evaluation.Name,
+			Priority:  int32(evaluation.Priority),
+			Effective: evaluation.Effective,
+			Reason:    evaluation.Reason,
+			Overrides: evaluation.Overrides,
+		})
+	}
+	return response, nil
+}
+
+func (a *adminService) ReleaseSlot(ctx context.Context, in *pb.ReleaseSlotRequest) (*pb.ReleaseSlotResponse, error) {
+	if !slices.Contains(a.limiter.throttler.ActiveSlots(), in.GetSlotName()) {
+		return &pb.ReleaseSlotResponse{Released: false}, nil
+	}
+	log.WithField("slot", in.GetSlotName()).Warn("Force releasing slot")
+	releaseSlot(ctx, a.limiter.throttler, in.GetSlotName(), releaseForced)
+	return &pb.ReleaseSlotResponse{Released: true}, nil
+}
+
+func (a *adminService) SetAdmission(ctx context.Context, in *pb.SetAdmissionRequest) (*pb.SetAdmissionResponse, error) {
+	log.Warnf("Setting admission paused to %v", in.GetPaused())
+	a.limiter.admission.SetPaused(in.GetPaused())
+	return &pb.SetAdmissionResponse{Paused: a.limiter.admission.IsPaused()}, nil
+}
+
+func (a *adminService) DescribeThrottlers(ctx context.Context, in *pb.DescribeThrottlersRequest) (*pb.DescribeThrottlersResponse, error) {
+	return &pb.DescribeThrottlersResponse{Snapshot: toProtoSnapshot(a.limiter.throttler.Describe())}, nil
+}
+
+// ExportFlightRecorder streams the entries in batches, the whole recorder may exceed the message size limit of gRPC
+func (a *adminService) ExportFlightRecorder(in *pb.ExportFlightRecorderRequest, stream pb.Admin_ExportFlightRecorderServer) error {
+	entries := flightrecorder.Default.Entries()
+	for len(entries) > 0 {
+		batch := entries[:min(len(entries), flightRecorderExportBatchSize)]
+		entries = entries[len(batch):]
+
+		response := &pb.ExportFlightRecorderResponse{Entries: make([]string, 0, len(batch))}
+		for _, entry := range batch {
+			line, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			response.Entries = append(response.Entries, string(line))
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toProtoSnapshot(snapshot throttler.Snapshot) *pb.ThrottlerSnapshot {
+	result := &pb.ThrottlerSnapshot{
+		Type:             snapshot.Type,
+		Description:      snapshot.Description,
+		Group:            snapshot.Group,
+		Limit:            snapshot.Limit,
+		Usage:            snapshot.Usage,
+		Load:             snapshot.Load,
+		PendingIncrement: snapshot.PendingIncrement,
+		TokensAvailable:  snapshot.TokensAvailable,
+		ActiveSlots:      int32(snapshot.ActiveSlots),
+		Waiters:          int32(snapshot.Waiters),
+		Explanation:      snapshot.Explain(),
+	}
+	for _, child := range snapshot.Children {
+		result.Children = append(result.Children, toProtoSnapshot(child))
+	}
+	return result
+}
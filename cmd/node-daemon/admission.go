@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// AdmissionGate holds back all wait requests while admission is paused
+type AdmissionGate struct {
+	paused  bool
+	resumed chan struct{}
+	mux     *sync.Mutex
+}
+
+func NewAdmissionGate() *AdmissionGate {
+	return &AdmissionGate{
+		resumed: make(chan struct{}),
+		mux:     &sync.Mutex{},
+	}
+}
+
+func (g *AdmissionGate) SetPaused(paused bool) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.paused == paused {
+		return
+	}
+	g.paused = paused
+	if !paused {
+		close(g.resumed) // wake up all waiting requests
+		g.resumed = make(chan struct{})
+	}
+}
+
+func (g *AdmissionGate) IsPaused() bool {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	return g.paused
+}
+
+// Wait blocks until admission is resumed or the context is cancelled
+func (g *AdmissionGate) Wait(ctx context.Context) error {
+	for {
+		g.mux.Lock()
+		paused := g.paused
+		resumed := g.resumed
+		g.mux.Unlock()
+
+		if !paused {
+			return nil
+		}
+
+		select {
+		case <-resumed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
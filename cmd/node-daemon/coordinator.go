@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/throttler"
+	pb "woehrl01/pod-pacemaker/proto"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// coordinatorReleaseTimeout bounds the release of a lease, a lease which isn't released is dropped by the next renewal
+const coordinatorReleaseTimeout = 5 * time.Second
+
+// a lease is requested again if the coordinator is unavailable, e.g. the connection still points to a replica which
+// isn't the leader anymore and is closed by it, before the pod is admitted without a lease
+const (
+	coordinatorAcquireAttempts = 3
+	coordinatorRetryBackoff    = time.Second
+)
+
+// coordinatorThrottler leases each pod from the cluster-wide coordinator after the throttlers of the node admitted it.
+// If the coordinator is unreachable, the pod is admitted by the throttlers of the node alone.
+type coordinatorThrottler struct {
+	client   pb.CoordinatorClient
+	target   string
+	nodeName string
+	nodes    corelisters.NodeLister
+	waiters  atomic.Int32
+
+	mu sync.Mutex
+	// leases are all admitted pods, also the ones admitted without the coordinator, so it learns about them once it is reachable again
+	leases map[string]*pb.Lease
+}
+
+var _ throttler.Throttler = &coordinatorThrottler{}
+var _ throttler.SlotRegistrar = &coordinatorThrottler{}
+
+// startCoordinatorClient connects to the coordinator and renews the leases of the node until the stopper is closed
+func startCoordinatorClient(target string, nodeName string, nodes corelisters.NodeLister, renewInterval time.Duration, stopper <-chan struct{}) *coordinatorThrottler {
+	conn, err := grpc.NewClient(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		log.Fatalf("Failed to create the client of the coordinator %s: %v", target, err)
+	}
+
+	t := &coordinatorThrottler{
+		client:   pb.NewCoordinatorClient(conn),
+		target:   target,
+		nodeName: nodeName,
+		nodes:    nodes,
+		leases:   map[string]*pb.Lease{},
+	}
+	go func() {
+		defer conn.Close()
+		ticker := time.NewTicker(renewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.renew(renewInterval)
+			case <-stopper:
+				return
+			}
+		}
+	}()
+	log.Infof("Leasing pods from the coordinator %s", target)
+	return t
+}
+
+func (t *coordinatorThrottler) AquireSlot(ctx context.Context, slotId string, data throttler.Data) error {
+	t.waiters.Add(1)
+	defer t.waiters.Add(-1)
+
+	lease := t.newLease(slotId, data.Pod)
+	response, err := t.acquireLease(ctx, lease)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.WithField("slot", slotId).Warnf("Coordinator is unreachable, admitting the pod without a lease: %v", err)
+		coordinatorFallbackCounter.Inc()
+	} else {
+		log.WithField("slot", slotId).Debugf("Leased pod from the budgets %v", response.GetBudgets())
+	}
+
+	t.mu.Lock()
+	t.leases[slotId] = lease
+	t.mu.Unlock()
+	return nil
+}
+
+// acquireLease requests the lease and retries while the coordinator answers with Unavailable
+func (t *coordinatorThrottler) acquireLease(ctx context.Context, lease *pb.Lease) (*pb.AcquireLeaseResponse, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := t.client.AcquireLease(ctx, &pb.AcquireLeaseRequest{Lease: lease})
+		if err == nil || status.Code(err) != codes.Unavailable || attempt == coordinatorAcquireAttempts {
+			return response, err
+		}
+		log.WithField("slot", lease.SlotName).Debugf("Coordinator is unavailable, retrying: %v", err)
+		select {
+		case <-time.After(coordinatorRetryBackoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// RegisterSlot keeps a lease of a pod which was admitted without the daemon, the coordinator adopts it with the next renewal
+func (t *coordinatorThrottler) RegisterSlot(slotId string, data throttler.Data, _ time.Time) {
+	lease := t.newLease(slotId, data.Pod)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.leases[slotId]; !ok {
+		t.leases[slotId] = lease
+	}
+}
+
+func (t *coordinatorThrottler) ReleaseSlot(ctx context.Context, slotId string) {
+	t.mu.Lock()
+	_, ok := t.leases[slotId]
+	delete(t.leases, slotId)
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// the release must not block the pod events while the coordinator is unreachable
+	go func() {
+		releaseCtx, cancel := context.WithTimeout(context.Background(), coordinatorReleaseTimeout)
+		defer cancel()
+		if _, err := t.client.ReleaseLease(releaseCtx, &pb.ReleaseLeaseRequest{Node: t.nodeName, SlotName: slotId}); err != nil {
+			log.WithField("slot", slotId).Debugf("Failed to release the lease, it is dropped by the next renewal: %v", err)
+		}
+	}()
+}
+
+// renew sends all leases of the node, so the coordinator drops the leases which weren't released and adopts the unknown ones
+func (t *coordinatorThrottler) renew(timeout time.Duration) {
+	t.mu.Lock()
+	leases := make([]*pb.Lease, 0, len(t.leases))
+	for _, lease := range t.leases {
+		leases = append(leases, lease)
+	}
+	t.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := t.client.RenewLeases(ctx, &pb.RenewLeasesRequest{Node: t.nodeName, Leases: leases}); err != nil {
+		log.Debugf("Failed to renew the leases at the coordinator: %v", err)
+	}
+}
+
+// newLease describes the pod by the attributes the budgets of the coordinator are keyed by
+func (t *coordinatorThrottler) newLease(slotId string, pod *v1.Pod) *pb.Lease {
+	lease := &pb.Lease{SlotName: slotId, Node: t.nodeName}
+	if node, err := t.nodes.Get(t.nodeName); err == nil {
+		lease.Zone = node.Labels[v1.LabelTopologyZone]
+	}
+	if pod == nil {
+		return lease
+	}
+	lease.Namespace = pod.Namespace
+	lease.Labels = pod.Labels
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		lease.Owner = owner.Kind + "/" + owner.Name
+	}
+	return lease
+}
+
+func (t *coordinatorThrottler) ActiveSlots() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slots := make([]string, 0, len(t.leases))
+	for slot := range t.leases {
+		slots = append(slots, slot)
+	}
+	return slots
+}
+
+func (t *coordinatorThrottler) String() string {
+	return fmt.Sprintf("CoordinatorThrottler(%s)", t.target)
+}
+
+func (t *coordinatorThrottler) Describe() throttler.Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return throttler.Snapshot{
+		Type:        throttler.TypeCoordinator,
+		Description: t.String(),
+		ActiveSlots: len(t.leases),
+		Waiters:     int(t.waiters.Load()),
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	pb "woehrl01/pod-pacemaker/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// scriptedCoordinator answers the lease requests with the errors in order and grants the lease afterwards
+type scriptedCoordinator struct {
+	pb.CoordinatorClient
+	errors   []error
+	requests int
+}
+
+func (c *scriptedCoordinator) AcquireLease(ctx context.Context, in *pb.AcquireLeaseRequest, opts ...grpc.CallOption) (*pb.AcquireLeaseResponse, error) {
+	c.requests++
+	if len(c.errors) > 0 {
+		err := c.errors[0]
+		c.errors = c.errors[1:]
+		return nil, err
+	}
+	return &pb.AcquireLeaseResponse{Budgets: []string{"global"}}, nil
+}
+
+func TestAquireSlotRetriesUnavailableCoordinator(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "not the leader of the coordinators")
+	tests := []struct {
+		name         string
+		errors       []error
+		wantRequests int
+		wantFallback bool
+	}{
+		{name: "leader answers", wantRequests: 1},
+		{name: "deposed leader answers first", errors: []error{unavailable}, wantRequests: 2},
+		{name: "unavailable on every attempt", errors: []error{unavailable, unavailable, unavailable}, wantRequests: coordinatorAcquireAttempts, wantFallback: true},
+		{name: "other errors aren't retried", errors: []error{status.Error(codes.Internal, "broken")}, wantRequests: 1, wantFallback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scriptedCoordinator{errors: tt.errors}
+			c := &coordinatorThrottler{
+				client:   client,
+				nodeName: "node",
+				nodes:    corelisters.NewNodeLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+				leases:   map[string]*pb.Lease{},
+			}
+			_, err := c.acquireLease(context.Background(), c.newLease("default/web", nil))
+			if client.requests != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", client.requests, tt.wantRequests)
+			}
+			if gotFallback := err != nil; gotFallback != tt.wantFallback {
+				t.Errorf("acquireLease() = %v, want fallback %v", err, tt.wantFallback)
+			}
+		})
+	}
+}
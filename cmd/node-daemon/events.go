@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	waitTimeAnnotation  = "pod-pacemaker/wait-time"
+	blockedByAnnotation = "pod-pacemaker/blocked-by"
+
+	// a pod has to wait at least this long until it gets events
+	eventWaitThreshold = 5 * time.Second
+	// the waiting event is repeated in this interval, the event correlator aggregates them
+	eventRepeatInterval = 30 * time.Second
+)
+
+// PodNotifier tells users about the time their pods spent waiting, via Events and optionally annotations
+type PodNotifier struct {
+	recorder    record.EventRecorder
+	clientset   kubernetes.Interface
+	annotate    bool
+	broadcaster record.EventBroadcaster
+}
+
+func NewPodNotifier(clientset kubernetes.Interface, nodeName string, annotate bool) *PodNotifier {
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(record.CorrelatorOptions{
+		QPS:       1.0 / 10, // refill one event per pod and reason every 10 seconds
+		BurstSize: 10,
+	})
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "pod-pacemaker", Host: nodeName})
+
+	return &PodNotifier{
+		recorder:    recorder,
+		clientset:   clientset,
+		annotate:    annotate,
+		broadcaster: broadcaster,
+	}
+}
+
+func (n *PodNotifier) Shutdown() {
+	n.broadcaster.Shutdown()
+}
+
+// WaitStatus describes why a pod is still waiting
+type WaitStatus struct {
+	Position  int
+	BlockedBy string
+}
+
+// WaitReporter emits events while a single pod is waiting for its slot
+type WaitReporter struct {
+	notifier      *PodNotifier
+	pod           *v1.Pod
+	status        func() WaitStatus
+	lastBlockedBy string
+	mu            sync.Mutex
+	done          chan struct{}
+	stopped       sync.Once
+}
+
+// StartWait begins reporting on the pod until Acquired or Stop is called
+func (n *PodNotifier) StartWait(pod *v1.Pod, status func() WaitStatus) *WaitReporter {
+	r := &WaitReporter{
+		notifier: n,
+		pod:      pod,
+		status:   status,
+		done:     make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *WaitReporter) run() {
+	timer := time.NewTimer(eventWaitThreshold)
+	defer timer.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-timer.C:
+			status := r.status()
+			r.mu.Lock()
+			if status.BlockedBy != "" {
+				r.lastBlockedBy = status.BlockedBy
+			}
+			r.mu.Unlock()
+			r.notifier.recorder.Eventf(r.pod, v1.EventTypeNormal, "WaitingForSlot",
+				"Waiting for startup slot (position %d, blocked by %s)", status.Position, status.BlockedBy)
+			timer.Reset(eventRepeatInterval)
+		}
+	}
+}
+
+// Stop ends the reporting without a slot
+func (r *WaitReporter) Stop() {
+	r.stopped.Do(func() { close(r.done) })
+}
+
+// Acquired ends the reporting and tells the user how long the pod had to wait in all attempts
+func (r *WaitReporter) Acquired(waited time.Duration) {
+	r.Stop()
+
+	if waited < eventWaitThreshold {
+		return // not worth mentioning
+	}
+
+	r.notifier.recorder.Eventf(r.pod, v1.EventTypeNormal, "SlotAcquired", "Startup slot acquired after %s", waited.Round(time.Second))
+
+	if r.notifier.annotate {
+		r.mu.Lock()
+		blockedBy := r.lastBlockedBy
+		r.mu.Unlock()
+		go r.notifier.annotateWaitTime(r.pod, waited, blockedBy)
+	}
+}
+
+func (n *PodNotifier) annotateWaitTime(pod *v1.Pod, waited time.Duration, blockedBy string) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				waitTimeAnnotation:  waited.Round(time.Second).String(),
+				blockedByAnnotation: blockedBy,
+			},
+		},
+	})
+	if err != nil {
+		log.Warnf("Failed to build annotation patch: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := n.clientset.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		log.Warnf("Failed to annotate pod %s: %v", buildSlotName(pod), err)
+	}
+}
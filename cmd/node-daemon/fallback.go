@@ -0,0 +1,57 @@
+package main
+
+import (
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/fallback"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fallbackAdoptInterval is the interval in which the local fallback locks are scanned again,
+// the CNI plugin also falls back while the daemon is running, e.g. if a request times out
+const fallbackAdoptInterval = 30 * time.Second
+
+// adoptFallbackLocksPeriodically adopts the local fallback locks right away and then in intervals until the stopper is closed
+func adoptFallbackLocksPeriodically(service *podLimitService, socket string, stopper <-chan struct{}) {
+	ticker := time.NewTicker(fallbackAdoptInterval)
+	defer ticker.Stop()
+	for {
+		adoptFallbackLocks(service, socket)
+		select {
+		case <-ticker.C:
+		case <-stopper:
+			return
+		}
+	}
+}
+
+// adoptFallbackLocks takes over the slots which the CNI plugin acquired by itself while the daemon was unreachable.
+// Pods which are still starting are counted as active slots of their chain right away, without waiting behind other pods,
+// because they are already starting. All local locks are cleared afterwards.
+func adoptFallbackLocks(service *podLimitService, socket string) {
+	registrar, ok := service.throttler.(throttler.SlotRegistrar)
+	if !ok {
+		return
+	}
+	semaphore := fallback.NewSemaphore(fallback.Dir(socket), 0, 0)
+	locks, err := semaphore.List()
+	if err != nil {
+		log.Warnf("Failed to list local fallback locks: %v", err)
+		return
+	}
+
+	for _, lock := range locks {
+		pod, err := service.podAccessor.GetPodByKey(lock.Slot)
+		if err == nil && pod != nil && !allContainersStarted(pod) {
+			group := service.groups.Route(pod, service.configs.ThrottleGroups())
+			registrar.RegisterSlot(lock.Slot, throttler.Data{Pod: pod, Group: group}, lock.Since)
+			log.WithField("slot", lock.Slot).Info("Adopted local fallback slot")
+		}
+
+		if err := semaphore.Release(lock.Slot); err != nil {
+			log.WithField("slot", lock.Slot).Warnf("Failed to clear local fallback lock: %v", err)
+		}
+	}
+}
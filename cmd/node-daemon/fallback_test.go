@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/fallback"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAdoptFallbackLocksCountsThePodsAtOnce(t *testing.T) {
+	concurrency, err := throttler.NewDynamicConcurrencyThrottler(1, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	starting := testPod("default", "starting")
+	started := false
+	starting.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "app", Started: &started}}
+	service := newTestService(t, []throttler.Throttler{concurrency}, starting, testPod("default", "running"))
+
+	// the limit is already used by a pod which the daemon admitted itself
+	if err := concurrency.AquireSlot(context.Background(), "default/admitted", throttler.Data{}); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "pod-limiter.sock")
+	semaphore := fallback.NewSemaphore(fallback.Dir(socket), 2, time.Minute)
+	for _, slot := range []string{"default/starting", "default/running"} {
+		if acquired, err := semaphore.TryAcquire(slot); err != nil || !acquired {
+			t.Fatalf("TryAcquire(%s) = %v, %v", slot, acquired, err)
+		}
+	}
+
+	adoptFallbackLocks(service, socket)
+
+	active := concurrency.ActiveSlots()
+	if !slices.Contains(active, "default/starting") {
+		t.Errorf("the starting pod isn't an active slot beyond the limit: %v", active)
+	}
+	if slices.Contains(active, "default/running") {
+		t.Errorf("the pod whose containers started is an active slot: %v", active)
+	}
+	locks, err := semaphore.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("the locks weren't cleared: %v", locks)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dumpFlightRecorderOnSignal writes the flight recorder to a file in dir whenever the daemon receives SIGUSR1
+func dumpFlightRecorderOnSignal(dir string, stopper <-chan struct{}) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+	defer signal.Stop(c)
+
+	for {
+		select {
+		case <-c:
+			path, err := dumpFlightRecorder(dir)
+			if err != nil {
+				log.Errorf("Failed to dump flight recorder: %v", err)
+				continue
+			}
+			log.Infof("Dumped flight recorder to %s", path)
+		case <-stopper:
+			return
+		}
+	}
+}
+
+func dumpFlightRecorder(dir string) (string, error) {
+	path := filepath.Join(dir, fmt.Sprintf("flightrecorder-%s.jsonl", time.Now().UTC().Format("20060102T150405Z")))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if err := flightrecorder.Default.WriteJSONLines(f); err != nil {
+		return "", err
+	}
+	return path, f.Close()
+}
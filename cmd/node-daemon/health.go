@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/throttler"
+)
+
+const (
+	// a load monitor which didn't take a sample for this long is considered dead
+	monitorStaleAfter = 1 * time.Minute
+	// the interval in which the readiness is checked before the startup taint is removed
+	readinessPollInterval = 1 * time.Second
+)
+
+type HealthCheck func() error
+
+// HealthChecks collects the liveness and readiness checks of the daemon
+type HealthChecks struct {
+	mu        sync.Mutex
+	liveness  map[string]HealthCheck
+	readiness map[string]HealthCheck
+}
+
+func NewHealthChecks() *HealthChecks {
+	return &HealthChecks{
+		liveness:  make(map[string]HealthCheck),
+		readiness: make(map[string]HealthCheck),
+	}
+}
+
+// AddLivenessCheck adds a check which fails /healthz, the check is also part of the readiness
+func (h *HealthChecks) AddLivenessCheck(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness[name] = check
+}
+
+// AddReadinessCheck adds a check which fails /readyz
+func (h *HealthChecks) AddReadinessCheck(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness[name] = check
+}
+
+func (h *HealthChecks) Healthy() error {
+	return h.run(false)
+}
+
+func (h *HealthChecks) Ready() error {
+	return h.run(true)
+}
+
+func (h *HealthChecks) run(includeReadiness bool) error {
+	h.mu.Lock()
+	checks := make(map[string]HealthCheck, len(h.liveness)+len(h.readiness))
+	for name, check := range h.liveness {
+		checks[name] = check
+	}
+	if includeReadiness {
+		for name, check := range h.readiness {
+			checks[name] = check
+		}
+	}
+	h.mu.Unlock()
+
+	failures := []string{}
+	for name, check := range checks {
+		if err := check(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// WaitUntilReady blocks until all checks pass or the context is done
+func (h *HealthChecks) WaitUntilReady(ctx context.Context) error {
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.Ready(); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("daemon did not become ready: %w", h.Ready())
+		case <-ticker.C:
+		}
+	}
+}
+
+func healthHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := check(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// socketListening checks that the gRPC server accepts connections on the socket
+func socketListening(socket string) HealthCheck {
+	return func() error {
+		conn, err := net.DialTimeout("unix", socket, time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// monitorsAlive checks that all load monitors are still sampling
+func monitorsAlive() error {
+	if stale := throttler.StaleMonitors(monitorStaleAfter); len(stale) > 0 {
+		return fmt.Errorf("no sample within %s from %s", monitorStaleAfter, strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// informerSynced turns the HasSynced func of an informer into a check
+func informerSynced(hasSynced func() bool) HealthCheck {
+	return func() error {
+		if !hasSynced() {
+			return fmt.Errorf("not synced")
+		}
+		return nil
+	}
+}
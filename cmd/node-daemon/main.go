@@ -2,17 +2,25 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
 	"time"
 
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned"
+	"woehrl01/pod-pacemaker/pkg/generated/informers/externalversions"
 	"woehrl01/pod-pacemaker/pkg/podaccessor"
 	"woehrl01/pod-pacemaker/pkg/throttler"
+	"woehrl01/pod-pacemaker/pkg/tracing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	flag "github.com/spf13/pflag"
 
@@ -20,13 +28,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/dynamic/dynamicinformer"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -39,6 +46,21 @@ var (
 	metricsPort           = flag.Int("metrics-port", 9000, "The port for the metrics server")
 	metricsEnabled        = flag.Bool("metrics-enabled", true, "Enable the metrics server")
 	trackInflightRequests = flag.Bool("track-inflight-requests", false, "Track inflight requests")
+	annotatePods          = flag.Bool("annotate-pods", false, "Annotate pods with their wait time and the throttler which blocked them")
+	retryGracePeriod      = flag.Duration("retry-grace-period", 2*time.Minute, "How long the queue position of a cancelled wait request is kept for a retry of the same pod")
+	metricsNamespaceLabel = flag.Bool("metrics-namespace-label", false, "Record the wait duration by namespace, the cardinality grows with the number of namespaces")
+	tracingExporter       = flag.String("tracing-exporter", tracing.ExporterNone, "The exporter for traces, either none or otlp")
+	tracingEndpoint       = flag.String("tracing-endpoint", "", "The host:port of the OTLP gRPC receiver, defaults to OTEL_EXPORTER_OTLP_ENDPOINT")
+	tracingInsecure       = flag.Bool("tracing-insecure", false, "Disable TLS for the OTLP exporter")
+	tracingSampleRatio    = flag.Float64("tracing-sample-ratio", 1.0, "The fraction of traces which are sampled if the CNI plugin didn't decide already")
+	flightRecorderSize    = flag.Int("flight-recorder-size", flightrecorder.DefaultSize, "The number of recent decisions, pod events, load samples and config changes which are kept in memory")
+	flightRecorderDumpDir = flag.String("flight-recorder-dump-dir", os.TempDir(), "The directory the flight recorder is written to on SIGUSR1")
+	enablePprof           = flag.Bool("enable-pprof", false, "Serve the pprof endpoints under /debug/pprof/ on the metrics port")
+	statusControllerOn    = flag.Bool("status-controller", true, "Take part in the leader election of the controller which writes the status of the PacemakerConfigs")
+	leaderElectionNs      = flag.String("leader-election-namespace", os.Getenv("POD_NAMESPACE"), "The namespace of the lease of the status controller, defaults to POD_NAMESPACE")
+	coordinatorAddress    = flag.String("coordinator-address", "", "The host:port of the coordinator of the cluster-wide budgets, empty disables it")
+	coordinatorRenewal    = flag.Duration("coordinator-renew-interval", 10*time.Second, "How often the leases of the node are renewed at the coordinator")
+	readinessTimeout      = flag.Duration("readiness-timeout", 5*time.Minute, "How long to wait for the daemon to become ready before giving up on removing the startup taint")
 )
 
 func main() {
@@ -60,25 +82,48 @@ func main() {
 		log.Fatal("NODE_NAME environment variable not set")
 	}
 
+	flightrecorder.Default.Resize(*flightRecorderSize)
+
 	ctx := context.Background()
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		Exporter:    *tracingExporter,
+		Endpoint:    *tracingEndpoint,
+		Insecure:    *tracingInsecure,
+		SampleRatio: *tracingSampleRatio,
+		ServiceName: "pod-pacemaker-node-daemon",
+	})
+	if err != nil {
+		log.Fatalf("Failed to setup tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	dynamicThrottlers := throttler.NewDynamicThrottler()
 
 	throttler := throttler.NewAllThrottler(dynamicThrottlers)
 
-	podAccessor := startPodHandler(ctx, clientset, throttler, nodeName, ctx.Done())
-	startConfigHandler(config, dynamicThrottlers, nodeName, ctx.Done())
-	removeStartupTaint(clientset, nodeName)
+	health := NewHealthChecks()
+	health.AddLivenessCheck("grpc", socketListening(*daemonSocket))
+	health.AddLivenessCheck("monitors", monitorsAlive)
+
+	notifier := NewPodNotifier(clientset, nodeName, *annotatePods)
+	defer notifier.Shutdown()
+	podAccessor := startPodHandler(ctx, clientset, throttler, nodeName, health, ctx.Done())
+	configurator := startConfigHandler(config, dynamicThrottlers, notifier.recorder, nodeName, health, ctx.Done())
+	namespaceLister := startNamespaceHandler(clientset, health, ctx.Done())
+	if *coordinatorAddress != "" {
+		dynamicThrottlers.SetFinal(startCoordinatorClient(*coordinatorAddress, nodeName, configurator.nodes, *coordinatorRenewal, ctx.Done()))
+	}
 
 	wg := sync.WaitGroup{}
 	if *metricsEnabled {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			startPrometheusMetricsServer(ctx.Done())
+			startPrometheusMetricsServer(throttler, configurator, health, ctx.Done())
 		}()
 	}
 
@@ -87,12 +132,31 @@ func main() {
 		defer wg.Done()
 		startGrpcServer(throttler, Options{
 			Socket:                *daemonSocket,
-			SkipDaemonSets:        *skipDaemonSets,
 			TrackInflightRequests: *trackInflightRequests,
-		}, podAccessor, ctx.Done())
-		wg.Done()
+			RetryGracePeriod:      *retryGracePeriod,
+			NamespaceWaitMetrics:  *metricsNamespaceLabel,
+		}, podAccessor, configurator, NewSkipPolicyEvaluator(namespaceLister, *skipDaemonSets), NewThrottleGroupRouter(namespaceLister), notifier, ctx.Done())
 	}()
 
+	// only remove the taint once pods can actually be admitted, otherwise the CNI plugin fails the sandbox creation
+	readyCtx, readyCancel := context.WithTimeout(ctx, *readinessTimeout)
+	if err := health.WaitUntilReady(readyCtx); err != nil {
+		log.Fatalf("Failed to remove startup taint: %v", err)
+	}
+	readyCancel()
+	removeStartupTaint(clientset, nodeName)
+
+	go dumpFlightRecorderOnSignal(*flightRecorderDumpDir, ctx.Done())
+
+	if *statusControllerOn {
+		if *leaderElectionNs == "" {
+			log.Warnf("Status controller disabled, no --leader-election-namespace set")
+		} else {
+			controller := newStatusController(configurator.configs, versioned.NewForConfigOrDie(config), clientset, notifier.recorder)
+			go controller.Run(ctx, *leaderElectionNs, nodeName)
+		}
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	defer func() {
@@ -112,7 +176,7 @@ func main() {
 	wg.Wait()
 }
 
-func startPodHandler(ctx context.Context, clientset *kubernetes.Clientset, throttler throttler.Throttler, nodeName string, stopper <-chan struct{}) podaccessor.PodAccessor {
+func startPodHandler(ctx context.Context, clientset *kubernetes.Clientset, throttler throttler.Throttler, nodeName string, health *HealthChecks, stopper <-chan struct{}) podaccessor.PodAccessor {
 	factory := informers.NewSharedInformerFactoryWithOptions(clientset, time.Second*30, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
 		options.FieldSelector = fields.OneTermEqualSelector("spec.nodeName", nodeName).String()
 	}))
@@ -145,6 +209,7 @@ func startPodHandler(ctx context.Context, clientset *kubernetes.Clientset, throt
 	if !cache.WaitForCacheSync(stopper, informer.HasSynced) {
 		log.Fatal("Failed to sync")
 	}
+	health.AddReadinessCheck("pods", informerSynced(informer.HasSynced))
 
 	go func() {
 		ticker := time.NewTicker(10 * time.Second)
@@ -162,27 +227,63 @@ func startPodHandler(ctx context.Context, clientset *kubernetes.Clientset, throt
 	return podaccessor.NewLocalPodsAccessor(informer.GetIndexer())
 }
 
-func startConfigHandler(config *rest.Config, dynamicThrottlers throttler.DynamicThrottler, nodeName string, stopper <-chan struct{}) {
-	gvr := schema.GroupVersionResource{
-		Group:    "woehrl.net",
-		Version:  "v1alpha",
-		Resource: "pacemakerconfigs",
+func startNamespaceHandler(clientset *kubernetes.Clientset, health *HealthChecks, stopper <-chan struct{}) corelisters.NamespaceLister {
+	factory := informers.NewSharedInformerFactory(clientset, 0 /*no resync*/)
+	namespaceInformer := factory.Core().V1().Namespaces()
+	informer := namespaceInformer.Informer()
+
+	go informer.Run(stopper)
+
+	if !cache.WaitForCacheSync(stopper, informer.HasSynced) {
+		log.Fatal("Failed to sync namespaces")
 	}
+	health.AddReadinessCheck("namespaces", informerSynced(informer.HasSynced))
 
-	dynClient := dynamic.NewForConfigOrDie(config)
+	return namespaceInformer.Lister()
+}
 
-	dynamicInformerFactory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynClient, 0 /*no resync*/, metav1.NamespaceAll, nil)
-	informers := dynamicInformerFactory.ForResource(gvr).Informer()
+func startConfigHandler(config *rest.Config, dynamicThrottlers throttler.DynamicThrottler, recorder record.EventRecorder, nodeName string, health *HealthChecks, stopper <-chan struct{}) *throttlerConfigurator {
+	configFactory := externalversions.NewSharedInformerFactory(versioned.NewForConfigOrDie(config), 0 /*no resync*/)
+	configs := configFactory.Woehrl().V1beta1().PacemakerConfigs()
+	configInformer := configs.Informer()
 
 	clientset := kubernetes.NewForConfigOrDie(config)
 
-	handler := NewThrottlerConfigurator(informers, clientset, nodeName, dynamicThrottlers)
+	// only the own node is watched, its labels select the config
+	nodeFactory := informers.NewSharedInformerFactoryWithOptions(clientset, 0 /*no resync*/, informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+		options.FieldSelector = fields.OneTermEqualSelector("metadata.name", nodeName).String()
+	}))
+	nodes := nodeFactory.Core().V1().Nodes()
+	nodeInformer := nodes.Informer()
+
+	handler := NewThrottlerConfigurator(configs, nodes.Lister(), recorder, nodeName, dynamicThrottlers, throttler.RealClock)
 
-	informers.AddEventHandler(cache.ResourceEventHandlerFuncs{
+	nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			handler.NodeUpdated(obj.(*v1.Node))
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			handler.NodeUpdated(newObj.(*v1.Node))
+		},
+	})
+
+	go nodeInformer.Run(stopper)
+
+	// the configs are selected by the labels of the node, so it has to be known before the first config event
+	if !cache.WaitForCacheSync(stopper, nodeInformer.HasSynced) {
+		panic("Failed to sync")
+	}
+	health.AddReadinessCheck("node", informerSynced(nodeInformer.HasSynced))
+
+	configInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
 			handler.Updatethrottlers()
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
+			// the status is written by the status controller and doesn't change the generation
+			if oldObj.(*v1beta1.PacemakerConfig).Generation == newObj.(*v1beta1.PacemakerConfig).Generation {
+				return
+			}
 			handler.Updatethrottlers()
 		},
 		DeleteFunc: func(obj interface{}) {
@@ -190,12 +291,26 @@ func startConfigHandler(config *rest.Config, dynamicThrottlers throttler.Dynamic
 		},
 	})
 
-	go informers.Run(stopper)
+	go configInformer.Run(stopper)
 
 	//wait for the initial synchronization of the local cache
-	if !cache.WaitForCacheSync(stopper, informers.HasSynced) {
+	if !cache.WaitForCacheSync(stopper, configInformer.HasSynced) {
 		panic("Failed to sync")
 	}
+	health.AddReadinessCheck("configs", informerSynced(configInformer.HasSynced))
+
+	// without any config there is no event which evaluates the configs
+	if !handler.Evaluated() {
+		handler.Updatethrottlers()
+	}
+	health.AddReadinessCheck("config-evaluated", func() error {
+		if !handler.Evaluated() {
+			return fmt.Errorf("configs not evaluated yet")
+		}
+		return nil
+	})
+
+	return handler
 }
 
 func removeStartupTaint(clientset *kubernetes.Clientset, nodeName string) {
@@ -229,12 +344,45 @@ func removeStartupTaint(clientset *kubernetes.Clientset, nodeName string) {
 	}
 }
 
-func startPrometheusMetricsServer(stopper <-chan struct{}) {
+func startPrometheusMetricsServer(t throttler.Throttler, configs ConfigProvider, health *HealthChecks, stopper <-chan struct{}) {
+	mux := http.NewServeMux()
 	srv := &http.Server{
-		Addr: fmt.Sprintf(":%d", *metricsPort),
+		Addr:    fmt.Sprintf(":%d", *metricsPort),
+		Handler: mux,
 	}
 
-	http.Handle("/metrics", promhttp.Handler())
+	prometheus.MustRegister(newThrottlerCollector(t))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", healthHandler(health.Healthy))
+	mux.HandleFunc("/readyz", healthHandler(health.Ready))
+	mux.HandleFunc("/debug/throttlers", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, t.Describe())
+	})
+	mux.HandleFunc("/debug/slots", func(w http.ResponseWriter, r *http.Request) {
+		if lister, ok := t.(throttler.SlotLister); ok {
+			writeJSON(w, lister.Slots())
+			return
+		}
+		writeJSON(w, t.ActiveSlots())
+	})
+	mux.HandleFunc("/debug/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, effectiveConfig(configs))
+	})
+
+	mux.HandleFunc("/debug/flightrecorder", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/jsonl")
+		if err := flightrecorder.Default.WriteJSONLines(w); err != nil {
+			log.Warnf("Failed to write flight recorder: %v", err)
+		}
+	})
+
+	if *enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
 
 	go func() {
 		<-stopper
@@ -243,3 +391,27 @@ func startPrometheusMetricsServer(stopper <-chan struct{}) {
 
 	log.Fatal(srv.ListenAndServe(), nil)
 }
+
+// debugConfig is the effective config of the node, with the spec after merging
+type debugConfig struct {
+	Name        string                       `json:"name,omitempty"`
+	Spec        *v1beta1.PacemakerConfigSpec `json:"spec,omitempty"`
+	Schedules   []string                     `json:"activeSchedules,omitempty"`
+	Evaluations []ConfigEvaluation           `json:"evaluations"`
+}
+
+func effectiveConfig(configs ConfigProvider) debugConfig {
+	response := debugConfig{Evaluations: configs.ConfigEvaluations(), Schedules: configs.ActiveSchedules()}
+	if config := configs.CurrentConfig(); config != nil {
+		response.Name = config.Name
+		response.Spec = &config.Spec
+	}
+	return response
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warnf("Failed to encode response: %v", err)
+	}
+}
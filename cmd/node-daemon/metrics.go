@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	waitTimeHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pod_pacemaker_wait_duration_seconds",
+		Help:    "Duration of wait requests",
+		Buckets: prometheus.ExponentialBucketsRange(0.1, 60, 5),
+	})
+	namespaceWaitTimeHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pod_pacemaker_namespace_wait_duration_seconds",
+		Help:    "Duration of wait requests by namespace, only recorded if enabled by --metrics-namespace-label",
+		Buckets: prometheus.ExponentialBucketsRange(0.1, 60, 5),
+	}, []string{"namespace"})
+	podNotFoundCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pod_pacemaker_pod_not_found",
+		Help: "Pod not found",
+	})
+	waitFailedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pod_pacemaker_wait_failed",
+		Help: "Wait failed",
+	}, []string{"reason"})
+	waitRetriesHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pod_pacemaker_wait_retries",
+		Help:    "Number of retried wait requests a pod needed until it acquired a slot",
+		Buckets: []float64{0, 1, 2, 3, 5, 10},
+	})
+	skippedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pod_pacemaker_skipped",
+		Help: "Pods which were started without throttling",
+	}, []string{"reason"})
+	coordinatorFallbackCounter = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pod_pacemaker_coordinator_fallbacks",
+		Help: "Pods admitted without a lease because the coordinator was unreachable",
+	})
+	slotHoldDurationHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pod_pacemaker_slot_hold_duration_seconds",
+		Help:    "Duration between acquiring and releasing a slot",
+		Buckets: prometheus.ExponentialBucketsRange(1, 600, 8),
+	}, []string{"reason"})
+	throttlerWaitTimeHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pod_pacemaker_throttler_wait_duration_seconds",
+		Help:    "Time a wait request spent in each throttler of the chain",
+		Buckets: prometheus.ExponentialBucketsRange(0.1, 60, 5),
+	}, []string{"throttler"})
+	configChangesCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pod_pacemaker_config_changes",
+		Help: "Rebuilds of the throttlers by the config which matched the node",
+	}, []string{"config"})
+	configErrorsCounter = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pod_pacemaker_config_errors",
+		Help: "Configs which couldn't be applied, the previous throttlers are kept",
+	}, []string{"config", "reason"})
+)
+
+// config error reasons
+const (
+	configErrorInvalid    = "invalid"
+	configErrorNodeLookup = "node_lookup"
+)
+
+// slot release reasons, used as label of the slot hold duration
+const (
+	releaseStarted   = "started"
+	releaseCompleted = "completed"
+	releaseDeleted   = "deleted"
+	releaseFailed    = "failed"
+	releaseOutdated  = "outdated"
+	releaseForced    = "forced"
+)
+
+// releaseSlot releases the slot and records how long it was held
+func releaseSlot(ctx context.Context, t throttler.Throttler, slotId string, reason string) {
+	acquiredAt, held := slotAcquiredAt(t, slotId)
+	t.ReleaseSlot(ctx, slotId)
+	if held {
+		heldFor := time.Since(acquiredAt)
+		slotHoldDurationHistogram.WithLabelValues(reason).Observe(heldFor.Seconds())
+		flightrecorder.Record(flightrecorder.KindRelease, slotId, reason, map[string]any{"heldFor": heldFor.String()})
+	}
+}
+
+// observeBreakdown records the time spent in each throttler, also of failed requests as they waited as well
+func observeBreakdown(breakdown *throttler.Breakdown) {
+	for _, stage := range breakdown.Stages {
+		throttlerWaitTimeHistogram.WithLabelValues(stage.Type).Observe(stage.Duration.Seconds())
+	}
+}
+
+// slotAcquiredAt returns the time the slot was acquired by the first throttler
+func slotAcquiredAt(t throttler.Throttler, slotId string) (time.Time, bool) {
+	lookup, ok := t.(throttler.SlotLookup)
+	if !ok {
+		return time.Time{}, false
+	}
+	return lookup.AcquiredAt(slotId)
+}
+
+var (
+	throttlerLabels         = []string{"throttler", "stage", "group"}
+	throttlerActiveSlotDesc = prometheus.NewDesc("pod_pacemaker_throttler_active_slots", "Slots which are held in the throttler", throttlerLabels, nil)
+	throttlerWaitersDesc    = prometheus.NewDesc("pod_pacemaker_throttler_waiters", "Pods which are waiting for the throttler", throttlerLabels, nil)
+	throttlerLimitDesc      = prometheus.NewDesc("pod_pacemaker_throttler_limit", "The configured limit of the throttler, e.g. the maximum slots or load", throttlerLabels, nil)
+	throttlerUsageDesc      = prometheus.NewDesc("pod_pacemaker_throttler_usage", "The value which is compared against the limit, including pending increments", throttlerLabels, nil)
+	throttlerLoadDesc       = prometheus.NewDesc("pod_pacemaker_throttler_load", "The last measured load of cpu, io and load average throttlers", throttlerLabels, nil)
+	throttlerTokensDesc     = prometheus.NewDesc("pod_pacemaker_throttler_tokens", "The available tokens of rate limit throttlers", throttlerLabels, nil)
+	waitersDesc             = prometheus.NewDesc("pod_pacemaker_waiters", "Pods which are waiting for a slot", nil, nil)
+)
+
+// throttlerCollector reads the state of the throttlers at scrape time.
+// The stage is the position of the throttler in the pipeline, which keeps the labels bounded.
+type throttlerCollector struct {
+	throttler throttler.Throttler
+}
+
+func newThrottlerCollector(t throttler.Throttler) *throttlerCollector {
+	return &throttlerCollector{throttler: t}
+}
+
+func (c *throttlerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- throttlerActiveSlotDesc
+	ch <- throttlerWaitersDesc
+	ch <- throttlerLimitDesc
+	ch <- throttlerUsageDesc
+	ch <- throttlerLoadDesc
+	ch <- throttlerTokensDesc
+	ch <- waitersDesc
+}
+
+func (c *throttlerCollector) Collect(ch chan<- prometheus.Metric) {
+	snapshot := c.throttler.Describe()
+	ch <- prometheus.MustNewConstMetric(waitersDesc, prometheus.GaugeValue, float64(snapshot.Waiters))
+
+	// the stage is the position of the throttler in the chain of its group
+	stages := map[string]int{}
+	for _, child := range snapshot.Children {
+		labels := []string{child.Type, strconv.Itoa(stages[child.Group]), child.Group}
+		stages[child.Group]++
+		ch <- prometheus.MustNewConstMetric(throttlerActiveSlotDesc, prometheus.GaugeValue, float64(child.ActiveSlots), labels...)
+		ch <- prometheus.MustNewConstMetric(throttlerWaitersDesc, prometheus.GaugeValue, float64(child.Waiters), labels...)
+		collectOptional(ch, throttlerLimitDesc, child.Limit, labels)
+		collectOptional(ch, throttlerUsageDesc, child.Usage, labels)
+		collectOptional(ch, throttlerLoadDesc, child.Load, labels)
+		collectOptional(ch, throttlerTokensDesc, child.TokensAvailable, labels)
+	}
+}
+
+func collectOptional(ch chan<- prometheus.Metric, desc *prometheus.Desc, value *float64, labels []string) {
+	if value == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, *value, labels...)
+}
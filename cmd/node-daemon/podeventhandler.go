@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"sync"
 
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
 	"woehrl01/pod-pacemaker/pkg/throttler"
 
 	log "github.com/sirupsen/logrus"
@@ -14,12 +16,26 @@ import (
 type PodEventHandler struct {
 	throttler throttler.Throttler
 	ctx       context.Context
+
+	mu sync.Mutex
+	// recorded is the last state of each pod with a slot which was written to the flight recorder
+	recorded map[string]podState
+}
+
+// podState is what the flight recorder keeps of a pod event, only its transitions are recorded
+type podState struct {
+	phase      v1.PodPhase
+	started    bool
+	terminated bool
+	failed     bool
+	deleted    bool
 }
 
 func NewPodEventHandler(throttler throttler.Throttler, ctx context.Context) *PodEventHandler {
 	return &PodEventHandler{
 		throttler: throttler,
 		ctx:       ctx,
+		recorded:  map[string]podState{},
 	}
 }
 
@@ -35,16 +51,11 @@ func (p *PodEventHandler) OnAdd(pod *v1.Pod) {
 	}
 
 	if !hasSlot { // nothing to do as it has no slot
+		p.forget(slotName)
 		return
 	}
 
-	allStarted := true // checking if all containers are started
-	for _, containerStatus := range pod.Status.ContainerStatuses {
-		if containerStatus.Started == nil || !*containerStatus.Started {
-			allStarted = false
-			break
-		}
-	}
+	allStarted := allContainersStarted(pod)
 
 	allTerminated := true // checking if all containers are terminated, e.g. pod is done for jobs
 	for _, containerStatus := range pod.Status.ContainerStatuses {
@@ -65,25 +76,69 @@ func (p *PodEventHandler) OnAdd(pod *v1.Pod) {
 	markedAsDeleted := pod.DeletionTimestamp != nil
 	completed := pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed
 
+	p.recordTransition(slotName, podState{
+		phase:      pod.Status.Phase,
+		started:    allStarted,
+		terminated: allTerminated,
+		failed:     failedState,
+		deleted:    markedAsDeleted,
+	})
+
 	if allStarted {
 		log.WithField("pod", slotName).Debug("Pod is fully started, releasing slot")
-		p.throttler.ReleaseSlot(p.ctx, slotName)
+		releaseSlot(p.ctx, p.throttler, slotName, releaseStarted)
 	} else if allTerminated || completed {
 		log.WithField("pod", slotName).Debug("Pod is completed, releasing slot")
-		p.throttler.ReleaseSlot(p.ctx, slotName)
+		releaseSlot(p.ctx, p.throttler, slotName, releaseCompleted)
 	} else if markedAsDeleted {
 		log.WithField("pod", slotName).Debug("Pod is marked as deleted, releasing slot")
-		p.throttler.ReleaseSlot(p.ctx, slotName)
+		releaseSlot(p.ctx, p.throttler, slotName, releaseDeleted)
 	} else if failedState {
 		log.WithField("pod", slotName).Debug("Pod has failed, releasing slot")
-		p.throttler.ReleaseSlot(p.ctx, slotName)
+		releaseSlot(p.ctx, p.throttler, slotName, releaseFailed)
 	} else {
 		log.WithField("pod", slotName).Debug("Pod is not fully started yet")
 	}
 }
 
 func (p *PodEventHandler) OnDelete(pod *v1.Pod) {
-	p.throttler.ReleaseSlot(p.ctx, buildSlotName(pod))
+	p.forget(buildSlotName(pod))
+	releaseSlot(p.ctx, p.throttler, buildSlotName(pod), releaseDeleted)
+}
+
+// recordTransition writes the pod event to the flight recorder if the state of the pod changed since its last event,
+// the informer resyncs and status updates of unrelated fields would otherwise evict the useful entries
+func (p *PodEventHandler) recordTransition(slotName string, state podState) {
+	p.mu.Lock()
+	last, ok := p.recorded[slotName]
+	p.recorded[slotName] = state
+	p.mu.Unlock()
+	if ok && last == state {
+		return
+	}
+
+	flightrecorder.Record(flightrecorder.KindPodEvent, slotName, string(state.phase), map[string]any{
+		"started":    state.started,
+		"terminated": state.terminated,
+		"failed":     state.failed,
+		"deleted":    state.deleted,
+	})
+}
+
+// forget drops the last recorded state of a pod whose slot was released
+func (p *PodEventHandler) forget(slotName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.recorded, slotName)
+}
+
+func allContainersStarted(pod *v1.Pod) bool {
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		if containerStatus.Started == nil || !*containerStatus.Started {
+			return false
+		}
+	}
+	return true
 }
 
 func buildSlotName(pod *v1.Pod) string {
@@ -103,6 +158,7 @@ func (p *PodEventHandler) RemoveOutdatedSlots(currentPods []*v1.Pod) {
 			continue
 		}
 		log.WithField("slot", slot).Warn("Removing outdated slot")
-		p.throttler.ReleaseSlot(p.ctx, slot)
+		p.forget(slot)
+		releaseSlot(p.ctx, p.throttler, slot, releaseOutdated)
 	}
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPodEventHandlerRecordsTransitionsOnly(t *testing.T) {
+	concurrency, err := throttler.NewDynamicConcurrencyThrottler(2, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := concurrency.AquireSlot(context.Background(), "default/web", throttler.Data{}); err != nil {
+		t.Fatal(err)
+	}
+	handler := NewPodEventHandler(concurrency, context.Background())
+	started := false
+	pod := testPod("default", "web")
+	pod.Status.Phase = v1.PodPending
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "app", Started: &started}}
+
+	before := podEvents("default/web")
+	handler.OnAdd(pod)
+	handler.OnAdd(pod) // e.g. a resync
+	pod.Status.Phase = v1.PodRunning
+	handler.OnAdd(pod)
+	handler.OnAdd(pod)
+
+	if got := podEvents("default/web") - before; got != 2 {
+		t.Errorf("recorded %d pod events, want 2", got)
+	}
+}
+
+func podEvents(slot string) int {
+	count := 0
+	for _, entry := range flightrecorder.Default.Entries() {
+		if entry.Kind == flightrecorder.KindPodEvent && entry.Slot == slot {
+			count++
+		}
+	}
+	return count
+}
@@ -8,58 +8,67 @@ import (
 	"syscall"
 	"time"
 
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
 	"woehrl01/pod-pacemaker/pkg/podaccessor"
 	"woehrl01/pod-pacemaker/pkg/throttler"
+	"woehrl01/pod-pacemaker/pkg/tracing"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/types/known/durationpb"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 
 	pb "woehrl01/pod-pacemaker/proto"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 )
 
-var (
-	waitTimeHistogram = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "pod_pacemaker_wait_duration_seconds",
-		Help:    "Duration of wait requests",
-		Buckets: prometheus.ExponentialBucketsRange(0.1, 60, 5),
-	})
-	podNotFoundCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "pod_pacemaker_pod_not_found",
-		Help: "Pod not found",
-	})
-	waitFailedCounter = promauto.NewCounterVec(prometheus.CounterOpts{
-		Name: "pod_pacemaker_wait_failed",
-		Help: "Wait failed",
-	}, []string{"reason"})
-)
-
 type podLimitService struct {
 	pb.UnimplementedPodLimiterServer
 	throttler   throttler.Throttler
 	podAccessor podaccessor.PodAccessor
+	configs     ConfigProvider
+	skipPolicy  *SkipPolicyEvaluator
+	groups      *ThrottleGroupRouter
+	notifier    *PodNotifier
+	admission   *AdmissionGate
 	options     Options
 	inflight    *NamedLocks
+	tickets     *TicketStore
+}
+
+type ConfigProvider interface {
+	CurrentConfig() *v1beta1.PacemakerConfig
+	ConfigEvaluations() []ConfigEvaluation
+	ThrottleGroups() []throttleGroupMatcher
+	ActiveSchedules() []string
 }
 
 type Options struct {
 	Socket                string
-	SkipDaemonSets        bool
 	TrackInflightRequests bool
+	RetryGracePeriod      time.Duration
+	NamespaceWaitMetrics  bool
 }
 
 var _ pb.PodLimiterServer = &podLimitService{}
 
-func NewPodLimitersServer(throttler throttler.Throttler, podAccessor podaccessor.PodAccessor, o Options) *podLimitService {
+func NewPodLimitersServer(throttler throttler.Throttler, podAccessor podaccessor.PodAccessor, configs ConfigProvider, skipPolicy *SkipPolicyEvaluator, groups *ThrottleGroupRouter, notifier *PodNotifier, o Options) *podLimitService {
 	return &podLimitService{
 		throttler:   throttler,
 		podAccessor: podAccessor,
+		configs:     configs,
+		skipPolicy:  skipPolicy,
+		groups:      groups,
+		notifier:    notifier,
+		admission:   NewAdmissionGate(),
 		options:     o,
 		inflight:    NewNamedLocks(),
+		tickets:     NewTicketStore(o.RetryGracePeriod),
 	}
 }
 
@@ -67,6 +76,8 @@ func (s *podLimitService) Wait(ctx context.Context, in *pb.WaitRequest) (*pb.Wai
 	log.Debugf("Received: %v", in.GetSlotName())
 
 	slotId := in.GetSlotName()
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("slot", slotId))
+	flightrecorder.Record(flightrecorder.KindWaitRequest, slotId, "wait requested", nil)
 
 	if s.options.TrackInflightRequests {
 		if acquired := s.inflight.TryAcquire(slotId); !acquired {
@@ -75,8 +86,7 @@ func (s *podLimitService) Wait(ctx context.Context, in *pb.WaitRequest) (*pb.Wai
 	}
 	defer s.inflight.Release(slotId)
 
-	startTime := time.Now()
-
+	_, lookupSpan := tracing.Tracer().Start(ctx, "pod lookup")
 	var pod *corev1.Pod
 	wait.PollUntilContextCancel(ctx, 500*time.Millisecond, true, func(ctx context.Context) (bool, error) {
 		p, err := s.podAccessor.GetPodByKey(slotId)
@@ -91,45 +101,152 @@ func (s *podLimitService) Wait(ctx context.Context, in *pb.WaitRequest) (*pb.Wai
 		pod = p
 		return true, nil
 	})
+	lookupSpan.End()
 	if pod == nil {
 		log.Warnf("Failed to get pod: %v", slotId)
 		waitFailedCounter.WithLabelValues("pod_not_found").Inc()
+		recordDecision(ctx, slotId, false, "pod_not_found", nil)
 		return &pb.WaitResponse{Success: false, Message: "Failed to get pod"}, nil
 	}
 
-	data := throttler.Data{
-		Pod: pod,
+	if reason := s.skipPolicy.SkipReason(pod, s.configs.CurrentConfig()); reason != "" {
+		log.Debugf("Skipping pod %v: %s", slotId, reason)
+		skippedCounter.WithLabelValues(reason).Inc()
+		recordDecision(ctx, slotId, true, "skipped_"+reason, nil)
+		return &pb.WaitResponse{Success: true, Message: fmt.Sprintf("Skipped (%s)", reason)}, nil
+	}
+
+	ticket := s.tickets.Checkout(pod.UID, slotId)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("ticket", int64(ticket.number)), attribute.Int("attempts", ticket.attempts))
+
+	group := s.groups.Route(pod, s.configs.ThrottleGroups())
+	if group != "" {
+		log.Debugf("Pod %v is throttled by throttle group %s", slotId, group)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("throttleGroup", group))
 	}
 
-	if s.options.SkipDaemonSets && pod.ObjectMeta.OwnerReferences != nil && len(pod.ObjectMeta.OwnerReferences) > 0 && pod.ObjectMeta.OwnerReferences[0].Kind == "DaemonSet" {
-		log.Debugf("Skipping daemonset: %v", pod.ObjectMeta.Name)
-		return &pb.WaitResponse{Success: true, Message: "Skipped daemonset"}, nil
+	breakdown := &throttler.Breakdown{}
+	data := throttler.Data{
+		Pod:       pod,
+		Ticket:    ticket.number,
+		Breakdown: breakdown,
+		Group:     group,
 	}
 
-	if err := s.throttler.AquireSlot(ctx, slotId, data); err != nil {
-		log.Debugf("Failed to acquire lock: %v", err)
+	reporter := s.notifier.StartWait(pod, func() WaitStatus {
+		return s.waitStatus(pod, slotId)
+	})
+	defer reporter.Stop()
+
+	err := s.admission.Wait(ctx)
+	if err == nil {
+		err = s.throttler.AquireSlot(ctx, slotId, data)
+	}
+	observeBreakdown(breakdown)
+	if err != nil {
+		log.WithField("breakdown", breakdown).Debugf("Failed to acquire lock: %v", err)
+		s.tickets.Park(pod.UID)
 		waitFailedCounter.WithLabelValues("failed_to_acquire_lock").Inc()
-		return &pb.WaitResponse{Success: false, Message: "Failed to acquire lock in time"}, nil
+		recordDecision(ctx, slotId, false, "failed_to_acquire_lock", breakdown)
+		return &pb.WaitResponse{Success: false, Message: "Failed to acquire lock in time", Breakdown: toProtoBreakdown(breakdown)}, nil
 	}
 
 	if ctx.Err() != nil {
 		log.Debugf("Context cancelled")
+		s.tickets.Park(pod.UID)
 		waitFailedCounter.WithLabelValues("context_cancelled").Inc()
-		return &pb.WaitResponse{Success: false, Message: "Context cancelled"}, nil
+		recordDecision(ctx, slotId, false, "context_cancelled", breakdown)
+		return &pb.WaitResponse{Success: false, Message: "Context cancelled", Breakdown: toProtoBreakdown(breakdown)}, nil
 	}
 
-	duration := time.Since(startTime)
-	log.WithFields(log.Fields{
-		"duration": duration,
-		"slot":     slotId,
-	}).Debug("Acquired slot")
+	duration := s.tickets.Complete(pod.UID) // includes the time spent in previous attempts
+	reporter.Acquired(duration)
+
+	entry := log.WithFields(log.Fields{
+		"duration":  duration,
+		"slot":      slotId,
+		"attempts":  ticket.attempts,
+		"breakdown": breakdown, // only covers this attempt
+	})
+	if duration >= eventWaitThreshold {
+		entry.Info("Acquired slot")
+	} else {
+		entry.Debug("Acquired slot")
+	}
 
 	waitTimeHistogram.Observe(duration.Seconds())
+	if s.options.NamespaceWaitMetrics {
+		namespaceWaitTimeHistogram.WithLabelValues(pod.Namespace).Observe(duration.Seconds())
+	}
 
-	return &pb.WaitResponse{Success: true, Message: "Waited successfully"}, nil
+	recordDecision(ctx, slotId, true, "acquired", breakdown)
+	return &pb.WaitResponse{Success: true, Message: "Waited successfully", Breakdown: toProtoBreakdown(breakdown)}, nil
+}
+
+// recordDecision records the outcome of a wait request as its own span and in the flight recorder
+func recordDecision(ctx context.Context, slotId string, success bool, reason string, breakdown *throttler.Breakdown) {
+	_, span := tracing.Tracer().Start(ctx, "decision", trace.WithAttributes(
+		attribute.Bool("success", success),
+		attribute.String("reason", reason),
+	))
+	span.End()
+	flightrecorder.Record(flightrecorder.KindDecision, slotId, reason, map[string]any{
+		"success":   success,
+		"breakdown": breakdown.String(),
+	})
+}
+
+func toProtoBreakdown(breakdown *throttler.Breakdown) []*pb.StageWait {
+	stages := make([]*pb.StageWait, 0, len(breakdown.Stages))
+	for _, stage := range breakdown.Stages {
+		stages = append(stages, &pb.StageWait{
+			Throttler:   stage.Type,
+			Description: stage.Description,
+			Duration:    durationpb.New(stage.Duration),
+		})
+	}
+	return stages
+}
+
+func (s *podLimitService) waitStatus(pod *corev1.Pod, slotId string) WaitStatus {
+	return WaitStatus{
+		Position:  s.tickets.Position(pod.UID),
+		BlockedBy: s.blockedBy(slotId),
+	}
+}
+
+// blockedBy describes what a waiting slot is currently waiting for
+func (s *podLimitService) blockedBy(slotId string) string {
+	if s.admission.IsPaused() {
+		return "paused admission"
+	}
+	if reporter, ok := s.throttler.(throttler.BlockingReporter); ok {
+		if blockedBy, ok := reporter.BlockedBy(slotId); ok {
+			return blockedBy.Describe().Explain()
+		}
+	}
+	return "unknown"
+}
+
+func (s *podLimitService) GetSettings(ctx context.Context, in *pb.SettingsRequest) (*pb.SettingsResponse, error) {
+	config := s.configs.CurrentConfig()
+	if config == nil {
+		return &pb.SettingsResponse{}, nil
+	}
+
+	settings := config.Spec.CniSettings
+	response := &pb.SettingsResponse{
+		MaxWaitTimeInSeconds:       settings.MaxWaitTimeInSeconds,
+		SuccessOnConnectionTimeout: settings.SuccessOnConnectionTimeout,
+		DisableThrottling:          settings.DisableThrottling,
+	}
+	if settings.NamespaceExclusions != nil {
+		response.NamespaceExclusions = &pb.NamespaceExclusions{Namespaces: settings.NamespaceExclusions}
+	}
+	return response, nil
 }
 
-func startGrpcServer(throttler throttler.Throttler, o Options, podAccessor podaccessor.PodAccessor, stopper <-chan struct{}) {
+func startGrpcServer(throttler throttler.Throttler, o Options, podAccessor podaccessor.PodAccessor, configs ConfigProvider, skipPolicy *SkipPolicyEvaluator, groups *ThrottleGroupRouter, notifier *PodNotifier, stopper <-chan struct{}) {
 	_ = syscall.Unlink(o.Socket) // clean up old socket and ignore errors
 	lis, err := net.Listen("unix", o.Socket)
 	if err != nil {
@@ -137,15 +254,17 @@ func startGrpcServer(throttler throttler.Throttler, o Options, podAccessor podac
 	}
 	defer lis.Close()
 
-	s := grpc.NewServer()
+	s := grpc.NewServer(grpc.StatsHandler(otelgrpc.NewServerHandler()))
 	go func() {
 		<-stopper
 		s.GracefulStop()
 	}()
 
-	service := NewPodLimitersServer(throttler, podAccessor, o)
+	service := NewPodLimitersServer(throttler, podAccessor, configs, skipPolicy, groups, notifier, o)
+	go adoptFallbackLocksPeriodically(service, o.Socket, stopper)
 
 	pb.RegisterPodLimiterServer(s, service)
+	pb.RegisterAdminServer(s, NewAdminServer(service))
 
 	log.Printf("server listening at %v", lis.Addr())
 	if err := s.Serve(lis); err != nil {
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+	"woehrl01/pod-pacemaker/pkg/tracing"
+	pb "woehrl01/pod-pacemaker/proto"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// staticConfigs is a ConfigProvider without any config
+type staticConfigs struct{}
+
+func (staticConfigs) CurrentConfig() *v1beta1.PacemakerConfig { return nil }
+func (staticConfigs) ConfigEvaluations() []ConfigEvaluation   { return nil }
+func (staticConfigs) ThrottleGroups() []throttleGroupMatcher  { return nil }
+func (staticConfigs) ActiveSchedules() []string               { return nil }
+
+// newTestService returns a service which knows the pods and throttles by the throttlers
+func newTestService(t *testing.T, throttlers []throttler.Throttler, pods ...*v1.Pod) *podLimitService {
+	t.Helper()
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	namespaceIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pod := range pods {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Fatal(err)
+		}
+		if err := namespaceIndexer.Add(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	namespaces := corelisters.NewNamespaceLister(namespaceIndexer)
+
+	dynamic := throttler.NewDynamicThrottler()
+	dynamic.SetThrottlers(throttlers)
+	notifier := NewPodNotifier(fake.NewSimpleClientset(), "node", false)
+	t.Cleanup(notifier.Shutdown)
+
+	return NewPodLimitersServer(throttler.NewAllThrottler(dynamic), podAccessorFunc(func(key string) (*v1.Pod, error) {
+		item, exists, err := podIndexer.GetByKey(key)
+		if err != nil || !exists {
+			return nil, err
+		}
+		return item.(*v1.Pod), nil
+	}), staticConfigs{}, NewSkipPolicyEvaluator(namespaces, true), NewThrottleGroupRouter(namespaces), notifier, Options{RetryGracePeriod: time.Minute})
+}
+
+type podAccessorFunc func(key string) (*v1.Pod, error)
+
+func (f podAccessorFunc) GetPodByKey(key string) (*v1.Pod, error) { return f(key) }
+
+func testPod(namespace string, name string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID("uid-" + name)}}
+}
+
+func TestWaitTracesTheStages(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown, err := tracing.Setup(context.Background(), tracing.Options{SpanExporter: exporter, SampleRatio: 1, ServiceName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+
+	concurrency, err := throttler.NewDynamicConcurrencyThrottler(2, "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rateLimit, err := throttler.NewRateLimitThrottler("1ms", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := newTestService(t, []throttler.Throttler{concurrency, rateLimit}, testPod("default", "web"))
+
+	// the CNI plugin starts the trace, its span is the parent of the request of the daemon
+	ctx, root := tracing.Tracer().Start(context.Background(), "cni add")
+	response, err := service.Wait(ctx, &pb.WaitRequest{SlotName: "default/web"})
+	root.End()
+	if err != nil || !response.Success {
+		t.Fatalf("Wait() = %v, %v, want success", response, err)
+	}
+	if err := otel.GetTracerProvider().(*sdktrace.TracerProvider).ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	rootSpan := findSpan(t, spans, "cni add")
+	if rootSpan.Parent.IsValid() {
+		t.Errorf("span cni add has a parent, want it to be the root")
+	}
+
+	want := []string{"pod lookup", "acquire " + throttler.TypeMaxConcurrent, "acquire " + throttler.TypeRateLimit, "decision"}
+	var previous time.Time
+	for _, name := range want {
+		span := findSpan(t, spans, name)
+		if span.Parent.SpanID() != rootSpan.SpanContext.SpanID() {
+			t.Errorf("span %s is not a child of cni add", name)
+		}
+		if span.StartTime.Before(previous) {
+			t.Errorf("span %s started before the previous stage", name)
+		}
+		previous = span.StartTime
+	}
+	if got := len(spans); got != len(want)+1 {
+		t.Errorf("got %d spans, want %d: %v", got, len(want)+1, spans)
+	}
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span %s in %v", name, spans)
+	return tracetest.SpanStub{}
+}
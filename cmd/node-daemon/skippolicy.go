@@ -0,0 +1,111 @@
+package main
+
+import (
+	"slices"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+const (
+	skipAnnotation   = "pod-pacemaker/skip"
+	mirrorAnnotation = "kubernetes.io/config.mirror"
+)
+
+type SkipPolicyEvaluator struct {
+	namespaces     corelisters.NamespaceLister
+	skipDaemonSets bool
+}
+
+func NewSkipPolicyEvaluator(namespaces corelisters.NamespaceLister, skipDaemonSets bool) *SkipPolicyEvaluator {
+	return &SkipPolicyEvaluator{
+		namespaces:     namespaces,
+		skipDaemonSets: skipDaemonSets,
+	}
+}
+
+// SkipReason returns why the pod is not throttled, or an empty string if it has to be throttled
+func (e *SkipPolicyEvaluator) SkipReason(pod *v1.Pod, config *v1beta1.PacemakerConfig) string {
+	if pod.Annotations[skipAnnotation] == "true" {
+		return "annotation"
+	}
+
+	ownerKind := ownerKindOf(pod)
+	if e.skipDaemonSets && ownerKind == "DaemonSet" {
+		return "owner_daemonset"
+	}
+
+	if config == nil {
+		return ""
+	}
+	policy := config.Spec.SkipPolicy
+
+	if policy.HostNetwork && pod.Spec.HostNetwork {
+		return "host_network"
+	}
+
+	if ownerKind != "" && slices.Contains(policy.OwnerKinds, ownerKind) {
+		switch ownerKind {
+		case "DaemonSet":
+			return "owner_daemonset"
+		case "Job":
+			return "owner_job"
+		default:
+			return "static_pod"
+		}
+	}
+
+	if pod.Spec.PriorityClassName != "" && slices.Contains(policy.PriorityClassNames, pod.Spec.PriorityClassName) {
+		return "priority_class"
+	}
+
+	if matchesAnySelector(policy.PodSelectors, pod.Labels) {
+		return "pod_selector"
+	}
+
+	if len(policy.NamespaceSelectors) > 0 {
+		namespace, err := e.namespaces.Get(pod.Namespace)
+		if err != nil {
+			log.Warnf("Failed to get namespace %s: %v", pod.Namespace, err)
+		} else if matchesAnySelector(policy.NamespaceSelectors, namespace.Labels) {
+			return "namespace_selector"
+		}
+	}
+
+	return ""
+}
+
+// ownerKindOf returns the kind of the controller of the pod, as used in the skip policy
+func ownerKindOf(pod *v1.Pod) string {
+	if _, ok := pod.Annotations[mirrorAnnotation]; ok {
+		return "Static"
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Controller != nil && *owner.Controller || len(pod.OwnerReferences) == 1 {
+			if owner.Kind == "Node" {
+				return "Static"
+			}
+			return owner.Kind
+		}
+	}
+	return ""
+}
+
+func matchesAnySelector(selectors []metav1.LabelSelector, podLabels map[string]string) bool {
+	for i := range selectors {
+		selector, err := metav1.LabelSelectorAsSelector(&selectors[i])
+		if err != nil {
+			log.Warnf("Ignoring invalid label selector: %v", err)
+			continue
+		}
+		if !selector.Empty() && selector.Matches(labels.Set(podLabels)) {
+			return true
+		}
+	}
+	return false
+}
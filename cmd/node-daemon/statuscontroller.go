@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned"
+	configinformers "woehrl01/pod-pacemaker/pkg/generated/informers/externalversions/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	statusLeaseName = "pod-pacemaker-status"
+	// bursts of events, e.g. many nodes joining at once, are combined into one update
+	statusSyncDelay = 2 * time.Second
+	// the status is recomputed in this interval, e.g. to retry failed updates
+	statusResyncInterval = 5 * time.Minute
+	// the node names in the status are truncated to keep the object small in large clusters
+	maxStatusNodes = 50
+)
+
+// statusController writes the status of all PacemakerConfigs. It runs in every daemon, but only the leader writes.
+type statusController struct {
+	configs   configinformers.PacemakerConfigInformer
+	client    versioned.Interface
+	clientset kubernetes.Interface
+	recorder  record.EventRecorder
+	trigger   chan struct{}
+}
+
+func newStatusController(configs configinformers.PacemakerConfigInformer, client versioned.Interface, clientset kubernetes.Interface, recorder record.EventRecorder) *statusController {
+	return &statusController{
+		configs:   configs,
+		client:    client,
+		clientset: clientset,
+		recorder:  recorder,
+		trigger:   make(chan struct{}, 1),
+	}
+}
+
+// Run takes part in the leader election until the context is done
+func (c *statusController) Run(ctx context.Context, namespace string, identity string) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: statusLeaseName, Namespace: namespace},
+		Client:     c.clientset.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			ReleaseOnCancel: true,
+			LeaseDuration:   30 * time.Second,
+			RenewDeadline:   20 * time.Second,
+			RetryPeriod:     5 * time.Second,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: c.lead,
+				OnStoppedLeading: func() {
+					log.Infof("Stopped writing the status of the configs")
+				},
+			},
+		})
+	}
+}
+
+// lead writes the status until the leadership is lost
+func (c *statusController) lead(ctx context.Context) {
+	log.Infof("Writing the status of the configs")
+
+	// only the leader watches all nodes, the other daemons only need their own
+	factory := informers.NewSharedInformerFactory(c.clientset, 0 /*no resync*/)
+	nodeInformer := factory.Core().V1().Nodes()
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { c.enqueue() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// nodes update their status frequently, only the labels matter
+			if !labels.Equals(oldObj.(*v1.Node).Labels, newObj.(*v1.Node).Labels) {
+				c.enqueue()
+			}
+		},
+		DeleteFunc: func(obj interface{}) { c.enqueue() },
+	})
+	factory.Start(ctx.Done())
+
+	registration, err := c.configs.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.enqueue() },
+		UpdateFunc: func(oldObj, newObj interface{}) { c.enqueue() },
+		DeleteFunc: func(obj interface{}) { c.enqueue() },
+	})
+	if err != nil {
+		log.Errorf("Failed to watch the configs: %v", err)
+		return
+	}
+	defer c.configs.Informer().RemoveEventHandler(registration)
+
+	if !cache.WaitForCacheSync(ctx.Done(), nodeInformer.Informer().HasSynced) {
+		return
+	}
+
+	ticker := time.NewTicker(statusResyncInterval)
+	defer ticker.Stop()
+	for {
+		c.sync(ctx, nodeInformer.Lister())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(statusSyncDelay):
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.trigger:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *statusController) enqueue() {
+	select {
+	case c.trigger <- struct{}{}:
+	default: // already pending
+	}
+}
+
+// sync computes the status of all configs and writes the ones which changed
+func (c *statusController) sync(ctx context.Context, nodes corelisters.NodeLister) {
+	allNodes, err := nodes.List(labels.Everything())
+	if err != nil {
+		log.Warnf("Failed to list nodes: %v", err)
+		return
+	}
+
+	configs, err := c.configs.Lister().List(labels.Everything())
+	if err != nil {
+		log.Warnf("Failed to list configs: %v", err)
+		return
+	}
+	statuses := make(map[string]*v1beta1.PacemakerConfigStatus, len(configs))
+	invalid := map[string]string{}
+	for _, config := range configs {
+		status := &v1beta1.PacemakerConfigStatus{
+			ObservedGeneration: config.Generation,
+			Conditions:         config.Status.Conditions,
+		}
+		statuses[config.Name] = status
+
+		if errs := config.Spec.Validate(); len(errs) > 0 {
+			setValidCondition(status, metav1.ConditionFalse, v1beta1.ReasonInvalidValue, errs.ToAggregate().Error())
+			invalid[config.Name] = errs.ToAggregate().Error()
+		} else {
+			setValidCondition(status, metav1.ConditionTrue, v1beta1.ReasonValid, "The config is valid")
+		}
+	}
+
+	for _, node := range allNodes {
+		_, evaluations := selectConfig(configs, node.Labels, invalid)
+		for _, evaluation := range evaluations {
+			status := statuses[evaluation.Name]
+			if evaluation.Effective {
+				status.EffectiveNodeCount++
+				status.EffectiveNodes = append(status.EffectiveNodes, node.Name)
+			} else if evaluation.Matches {
+				status.ShadowedNodeCount++
+				status.ShadowedNodes = append(status.ShadowedNodes, node.Name)
+			}
+		}
+	}
+
+	for _, config := range configs {
+		status := statuses[config.Name]
+		status.EffectiveNodes = truncateNodes(status.EffectiveNodes)
+		status.ShadowedNodes = truncateNodes(status.ShadowedNodes)
+		previous := meta.FindStatusCondition(config.Status.Conditions, v1beta1.ConditionValid)
+		if err := c.writeStatus(ctx, config, status); err != nil {
+			log.Warnf("Failed to update the status of config %s: %v", config.Name, err)
+			c.enqueue() // retry, e.g. after a conflict
+			continue
+		}
+
+		// tell the user once per invalid generation, the daemons report the configs they fail to apply
+		valid := meta.FindStatusCondition(status.Conditions, v1beta1.ConditionValid)
+		if valid.Status == metav1.ConditionFalse && (previous == nil || previous.Status != metav1.ConditionFalse || previous.ObservedGeneration != valid.ObservedGeneration) {
+			c.recorder.Eventf(configReference(config), v1.EventTypeWarning, "InvalidConfig", "%s: %s", valid.Reason, valid.Message)
+		}
+	}
+}
+
+func (c *statusController) writeStatus(ctx context.Context, config *v1beta1.PacemakerConfig, status *v1beta1.PacemakerConfigStatus) error {
+	if equality.Semantic.DeepEqual(config.Status, *status) {
+		return nil
+	}
+	// the objects of the informer are shared and must not be modified
+	updated := config.DeepCopy()
+	updated.Status = *status
+	_, err := c.client.WoehrlV1beta1().PacemakerConfigs().UpdateStatus(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+func setValidCondition(status *v1beta1.PacemakerConfigStatus, value metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               v1beta1.ConditionValid,
+		Status:             value,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: status.ObservedGeneration,
+	})
+}
+
+func truncateNodes(nodes []string) []string {
+	sort.Strings(nodes)
+	if len(nodes) > maxStatusNodes {
+		return append(nodes[:maxStatusNodes], fmt.Sprintf("... and %d more", len(nodes)-maxStatusNodes))
+	}
+	return nodes
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/expression"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+)
+
+// throttleGroupMatcher decides if a pod belongs to a throttle group, it is compiled once when the config is applied
+type throttleGroupMatcher struct {
+	name              string
+	namespaceSelector labels.Selector
+	podSelector       labels.Selector
+	expression        *expression.PodExpression
+}
+
+func compileThrottleGroups(groups []v1beta1.ThrottleGroup) ([]throttleGroupMatcher, error) {
+	matchers := make([]throttleGroupMatcher, 0, len(groups))
+	for _, group := range groups {
+		matcher := throttleGroupMatcher{name: group.Name}
+		var err error
+		if group.NamespaceSelector != nil {
+			if matcher.namespaceSelector, err = metav1.LabelSelectorAsSelector(group.NamespaceSelector); err != nil {
+				return nil, fmt.Errorf("throttle group %s: %w", group.Name, err)
+			}
+		}
+		if group.PodSelector != nil {
+			if matcher.podSelector, err = metav1.LabelSelectorAsSelector(group.PodSelector); err != nil {
+				return nil, fmt.Errorf("throttle group %s: %w", group.Name, err)
+			}
+		}
+		if group.Expression != "" {
+			if matcher.expression, err = expression.CompilePodExpression(group.Expression); err != nil {
+				return nil, fmt.Errorf("throttle group %s: %w", group.Name, err)
+			}
+		}
+		matchers = append(matchers, matcher)
+	}
+	return matchers, nil
+}
+
+type ThrottleGroupRouter struct {
+	namespaces corelisters.NamespaceLister
+}
+
+func NewThrottleGroupRouter(namespaces corelisters.NamespaceLister) *ThrottleGroupRouter {
+	return &ThrottleGroupRouter{namespaces: namespaces}
+}
+
+// Route returns the name of the first group the pod matches, or an empty string for the default chain
+func (r *ThrottleGroupRouter) Route(pod *v1.Pod, groups []throttleGroupMatcher) string {
+	for _, group := range groups {
+		if r.matches(pod, group) {
+			return group.name
+		}
+	}
+	return ""
+}
+
+func (r *ThrottleGroupRouter) matches(pod *v1.Pod, group throttleGroupMatcher) bool {
+	if group.podSelector != nil && !group.podSelector.Matches(labels.Set(pod.Labels)) {
+		return false
+	}
+	if group.namespaceSelector != nil {
+		namespace, err := r.namespaces.Get(pod.Namespace)
+		if err != nil {
+			log.Warnf("Failed to get namespace %s, pod %s/%s doesn't match throttle group %s: %v", pod.Namespace, pod.Namespace, pod.Name, group.name, err)
+			return false
+		}
+		if !group.namespaceSelector.Matches(labels.Set(namespace.Labels)) {
+			return false
+		}
+	}
+	if group.expression != nil {
+		matches, err := group.expression.Matches(pod)
+		if err != nil {
+			// most errors are fields which aren't set in the pod, e.g. labels, so they only mean the pod doesn't match
+			log.Debugf("Failed to evaluate the expression of throttle group %s for pod %s/%s: %v", group.name, pod.Namespace, pod.Name, err)
+			return false
+		}
+		return matches
+	}
+	return true
+}
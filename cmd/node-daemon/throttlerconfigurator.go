@@ -1,145 +1,463 @@
 package main
 
 import (
-	"context"
+	"fmt"
+	"slices"
 	"sort"
+	"strings"
 	"sync"
-	"woehrl01/pod-pacemaker/api/v1alpha"
+	"sync/atomic"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+	configinformers "woehrl01/pod-pacemaker/pkg/generated/informers/externalversions/api/v1beta1"
 	"woehrl01/pod-pacemaker/pkg/throttler"
 
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/cache"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	// the configs are evaluated again after this delay, if the node couldn't be read
+	configRetryInterval = 30 * time.Second
 )
 
 type throttlerConfigurator struct {
-	informers           cache.SharedIndexInformer
-	clientset           *kubernetes.Clientset
+	configs             configinformers.PacemakerConfigInformer
+	nodes               corelisters.NodeLister
+	recorder            record.EventRecorder
 	currentCloseChannel chan struct{}
 	lock                sync.Mutex
 	nodeName            string
 	dynamicThrottlers   throttler.DynamicThrottler
+	clock               throttler.Clock
+	currentSelection    atomic.Pointer[configSelection]
+	retryPending        atomic.Bool
+	retryInterval       time.Duration
+}
+
+// ConfigEvaluation explains why a config is or isn't effective on this node
+type ConfigEvaluation struct {
+	Name      string `json:"name"`
+	Priority  int    `json:"priority"`
+	Matches   bool   `json:"matches"`
+	Effective bool   `json:"effective"`
+	Reason    string `json:"reason"`
+	// Overrides are the fields the config sets on top of the configs it is merged onto
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+type configSelection struct {
+	config      *v1beta1.PacemakerConfig
+	evaluations []ConfigEvaluation
+	// the labels of the node the config was selected for
+	nodeLabels map[string]string
+	groups     []throttleGroupMatcher
+	// the schedules of the config which were active when it was applied
+	schedules []string
+	// nextSchedule is the next time the active schedules may change, zero if never
+	nextSchedule time.Time
+}
+
+// pipeline are the throttlers which are built from a config
+type pipeline struct {
+	throttlers []throttler.Throttler
+	groups     []throttler.Group
+	matchers   []throttleGroupMatcher
 }
 
-func NewThrottlerConfigurator(informer cache.SharedIndexInformer, clientSet *kubernetes.Clientset, nodeName string, dynamicThrottler throttler.DynamicThrottler) *throttlerConfigurator {
+func NewThrottlerConfigurator(configs configinformers.PacemakerConfigInformer, nodes corelisters.NodeLister, recorder record.EventRecorder, nodeName string, dynamicThrottler throttler.DynamicThrottler, clock throttler.Clock) *throttlerConfigurator {
 	return &throttlerConfigurator{
-		informers:           informer,
-		clientset:           clientSet,
+		configs:             configs,
+		nodes:               nodes,
+		recorder:            recorder,
 		currentCloseChannel: make(chan struct{}),
 		nodeName:            nodeName,
 		dynamicThrottlers:   dynamicThrottler,
+		clock:               clock,
+		retryInterval:       configRetryInterval,
 	}
 }
 
+// Updatethrottlers rebuilds the throttlers from the config which matches the node. A config which is invalid is skipped
+// and the next matching config is tried instead. If no matching config is valid or the node can't be read,
+// the current throttlers are kept.
 func (t *throttlerConfigurator) Updatethrottlers() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	close(t.currentCloseChannel) // close the current throttlers
-	t.currentCloseChannel = make(chan struct{})
+	// invalid are the configs which failed to build, by name
+	invalid := map[string]string{}
+	for {
+		selection, err := t.getMatchingConfig(invalid)
+		if err != nil {
+			log.Errorf("Failed to evaluate the configs, keeping the current throttlers: %v", err)
+			configErrorsCounter.WithLabelValues("", configErrorNodeLookup).Inc()
+			t.retryLater()
+			return
+		}
 
-	matchingConfig := t.getMatchingConfig()
+		if selection.config == nil && len(invalid) > 0 {
+			t.keepThrottlers(selection)
+			return
+		}
+		if selection.config == nil {
+			log.Infof("No matching config found")
+			t.replaceThrottlers(&pipeline{throttlers: []throttler.Throttler{}}, make(chan struct{}))
+			t.currentSelection.Store(selection)
+			configChangesCounter.WithLabelValues("").Inc()
+			flightrecorder.Record(flightrecorder.KindConfigChange, "", "no matching config", nil)
+			return
+		}
 
-	if matchingConfig == nil {
-		log.Infof("No matching config found")
-		t.dynamicThrottlers.SetThrottlers([]throttler.Throttler{})
-		return
+		if built, closeChannel, ok := t.build(selection, invalid); ok {
+			t.applySelection(selection, built, closeChannel)
+			return
+		}
 	}
+}
 
-	throttlers := []throttler.Throttler{}
-	// rate limit first
-	if matchingConfig.Spec.ThrottleConfig.RateLimit.FillFactor != "" && matchingConfig.Spec.ThrottleConfig.RateLimit.Burst > 0 {
-		throttlers = append(throttlers, throttler.NewRateLimitThrottler(
-			matchingConfig.Spec.ThrottleConfig.RateLimit.FillFactor,
-			matchingConfig.Spec.ThrottleConfig.RateLimit.Burst,
-		))
+// build builds the throttlers of the selected config. If the config is invalid, it is reported and added to invalid.
+func (t *throttlerConfigurator) build(selection *configSelection, invalid map[string]string) (*pipeline, chan struct{}, bool) {
+	matchingConfig := selection.config
+	if len(matchingConfig.Spec.Schedules) > 0 {
+		spec, active, next := v1beta1.ApplySchedules(matchingConfig.Spec, t.clock.Now())
+		matchingConfig = matchingConfig.DeepCopy()
+		matchingConfig.Spec = spec
+		selection.config = matchingConfig
+		selection.schedules = active
+		selection.nextSchedule = next
 	}
 
-	// then max concurrent
-	if matchingConfig.Spec.ThrottleConfig.MaxConcurrent.Value > 0 || matchingConfig.Spec.ThrottleConfig.MaxConcurrent.PerCore != "" {
-		throttlers = append(throttlers, throttler.NewDynamicConcurrencyThrottler(
-			matchingConfig.Spec.ThrottleConfig.MaxConcurrent.Value,
-			matchingConfig.Spec.ThrottleConfig.MaxConcurrent.PerCore,
-		))
+	closeChannel := make(chan struct{})
+	built, err := t.buildThrottlers(matchingConfig, closeChannel)
+	if err != nil {
+		close(closeChannel) // stop the monitors which were already started
+		culprit := t.invalidMergedConfig(selection, matchingConfig)
+		log.Errorf("Config %s is invalid, trying the next matching config: %v", culprit.Name, err)
+		configErrorsCounter.WithLabelValues(culprit.Name, configErrorInvalid).Inc()
+		flightrecorder.Record(flightrecorder.KindConfigChange, "", culprit.Name, map[string]any{"error": err.Error()})
+		t.recorder.Eventf(configReference(culprit), v1.EventTypeWarning, "InvalidConfig",
+			"Config is invalid, node %s skips it: %v", t.nodeName, err)
+		invalid[culprit.Name] = err.Error()
+		return nil, nil, false
 	}
+	return built, closeChannel, true
+}
 
-	// then load average
-	if matchingConfig.Spec.ThrottleConfig.LoadAvg.MaxLoad != "" {
-		throttlers = append(throttlers, throttler.NewConcurrencyControllerBasedOnLoadAvg(
-			matchingConfig.Spec.ThrottleConfig.LoadAvg.MaxLoad,
-			matchingConfig.Spec.ThrottleConfig.LoadAvg.PerCore,
-			matchingConfig.Spec.ThrottleConfig.LoadAvg.IncrementBy,
-			t.currentCloseChannel,
-		))
+// invalidMergedConfig returns the config of a merged config which is invalid on its own, so only that one is skipped
+// and the others are merged onto the next matching config. If none is invalid on its own, the merged config is skipped.
+func (t *throttlerConfigurator) invalidMergedConfig(selection *configSelection, merged *v1beta1.PacemakerConfig) *v1beta1.PacemakerConfig {
+	for _, evaluation := range selection.evaluations {
+		if !evaluation.Effective || evaluation.Name == merged.Name {
+			continue
+		}
+		config, err := t.configs.Lister().Get(evaluation.Name)
+		if err == nil && len(config.Spec.Validate()) > 0 {
+			return config
+		}
 	}
+	return merged
+}
 
-	// then CPU load
-	if matchingConfig.Spec.ThrottleConfig.Cpu.MaxLoad != "" {
-		throttlers = append(throttlers, throttler.NewConcurrencyControllerBasedOnCpu(
-			matchingConfig.Spec.ThrottleConfig.Cpu.MaxLoad,
-			matchingConfig.Spec.ThrottleConfig.Cpu.IncrementBy,
-			t.currentCloseChannel,
-		))
+// keepThrottlers keeps the current throttlers, because none of the matching configs is valid
+func (t *throttlerConfigurator) keepThrottlers(selection *configSelection) {
+	if previous := t.currentSelection.Load(); previous != nil {
+		log.Errorf("No matching config is valid, keeping the current throttlers")
+		// the labels were evaluated, so the next update of the node doesn't select the invalid configs again
+		kept := *previous
+		kept.nodeLabels = selection.nodeLabels
+		t.currentSelection.Store(&kept)
+		return
 	}
+	// there are no previous throttlers, but the node must still become ready
+	log.Errorf("No matching config is valid, starting without throttlers")
+	t.currentSelection.Store(&configSelection{config: nil, evaluations: selection.evaluations, nodeLabels: selection.nodeLabels})
+}
 
-	// then I/O load
-	if matchingConfig.Spec.ThrottleConfig.IO.MaxLoad != "" {
-		throttlers = append(throttlers, throttler.NewConcurrencyControllerBasedOnIOLoad(
-			matchingConfig.Spec.ThrottleConfig.IO.MaxLoad,
-			matchingConfig.Spec.ThrottleConfig.IO.IncrementBy,
-			t.currentCloseChannel,
-		))
+// applySelection activates the throttlers which were built from the selected config
+func (t *throttlerConfigurator) applySelection(selection *configSelection, built *pipeline, closeChannel chan struct{}) {
+	matchingConfig := selection.config
+	log.Infof("Config %s matches node labels", matchingConfig.Name)
+	if len(selection.schedules) > 0 {
+		log.Infof("Schedules %s of config %s are active", strings.Join(selection.schedules, ", "), matchingConfig.Name)
 	}
+	for _, evaluation := range selection.evaluations {
+		if len(evaluation.Overrides) > 0 {
+			log.Infof("Config %s is merged onto the lower configs and overrides %s", evaluation.Name, strings.Join(evaluation.Overrides, ", "))
+		}
+	}
+	t.replaceThrottlers(built, closeChannel)
+	t.selectAgainAt(selection.nextSchedule)
+	selection.groups = built.matchers
+	t.currentSelection.Store(selection)
+	configChangesCounter.WithLabelValues(matchingConfig.Name).Inc()
 
-	if len(throttlers) == 0 {
+	if len(built.throttlers) == 0 {
 		log.Infof("No throttlers found")
 	}
 
-	for _, t := range throttlers {
+	descriptions := make([]string, 0, len(built.throttlers))
+	for _, t := range built.throttlers {
 		log.Infof("Throttler is active: %s", t)
+		descriptions = append(descriptions, t.String())
 	}
-
-	t.dynamicThrottlers.SetThrottlers(throttlers)
+	for _, group := range built.groups {
+		for _, t := range group.Throttlers {
+			log.Infof("Throttler of group %s is active: %s", group.Name, t)
+			descriptions = append(descriptions, group.Name+": "+t.String())
+		}
+	}
+	flightrecorder.Record(flightrecorder.KindConfigChange, "", matchingConfig.Name, map[string]any{"throttlers": descriptions, "schedules": selection.schedules})
 }
 
-func (t *throttlerConfigurator) getMatchingConfig() *v1alpha.PacemakerConfig {
-	allConfigsUnstructured := t.informers.GetStore().List()
+func (t *throttlerConfigurator) buildThrottlers(config *v1beta1.PacemakerConfig, closeChannel chan struct{}) (*pipeline, error) {
+	if errs := config.Spec.Validate(); len(errs) > 0 {
+		return nil, errs.ToAggregate()
+	}
+	matchers, err := compileThrottleGroups(config.Spec.ThrottleGroups)
+	if err != nil {
+		return nil, err
+	}
+	env := throttler.DefaultEnvironment
+	if config.Spec.Warmup != nil {
+		env.Scale = throttler.NewWarmupRamp(config.Spec.Warmup, t.clock, t.warmupStart(config.Spec.Warmup.From)).Fraction
+	}
+	throttlers, err := env.Build(config.Spec.Throttlers, closeChannel)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := env.BuildGroups(config.Spec.ThrottleGroups, closeChannel)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeline{throttlers: throttlers, groups: groups, matchers: matchers}, nil
+}
 
-	allConfigs := make([]*v1alpha.PacemakerConfig, 0, len(allConfigsUnstructured))
-	for _, config := range allConfigsUnstructured {
-		unstructured := config.(*unstructured.Unstructured)
-		config, err := v1alpha.ConvertToPacemakerConfig(unstructured)
+// warmupStart returns the start of the warmup, which is the boot of the node or the time it became ready
+func (t *throttlerConfigurator) warmupStart(from string) func() (time.Time, bool) {
+	if from == v1beta1.WarmupFromBoot {
+		return throttler.GetBootTime
+	}
+	return func() (time.Time, bool) {
+		node, err := t.nodes.Get(t.nodeName)
 		if err != nil {
-			log.Fatalf("Failed to convert config %s: %v", unstructured.GetName(), err)
+			return time.Time{}, false
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == v1.NodeReady && condition.Status == v1.ConditionTrue {
+				return condition.LastTransitionTime.Time, true
+			}
+		}
+		return time.Time{}, false // the ramp starts once the node is ready
+	}
+}
+
+// replaceThrottlers activates the throttlers and closes the previous ones
+func (t *throttlerConfigurator) replaceThrottlers(built *pipeline, closeChannel chan struct{}) {
+	// the pods which are still starting keep their slots in the new throttlers
+	throttler.AdoptSlots(t.dynamicThrottlers.GetThrottlers(), built.throttlers)
+	throttler.AdoptGroupSlots(t.dynamicThrottlers.GetGroups(), built.groups)
+
+	// the waiting slots move on to the new throttlers before the monitors of the previous ones stop
+	t.dynamicThrottlers.Replace(built.throttlers, built.groups)
+	close(t.currentCloseChannel)
+	t.currentCloseChannel = closeChannel
+}
+
+// selectAgainAt checks the schedules again once the active schedules may change, unless the throttlers are replaced before
+func (t *throttlerConfigurator) selectAgainAt(next time.Time) {
+	if next.IsZero() {
+		return
+	}
+	replaced := t.currentCloseChannel
+	go func() {
+		select {
+		case <-t.clock.After(next.Sub(t.clock.Now())):
+			t.recheckSchedules()
+		case <-replaced:
+		}
+	}()
+}
+
+// recheckSchedules selects the config again if other schedules are active now. A transition doesn't always change them,
+// e.g. a cron schedule which starts again before its duration ended only extends the window.
+func (t *throttlerConfigurator) recheckSchedules() {
+	t.lock.Lock()
+	selection := t.currentSelection.Load()
+	if selection != nil && selection.config != nil {
+		// the throttlers of the active schedules are merged into the spec, but its schedules are kept
+		_, active, next := v1beta1.ApplySchedules(selection.config.Spec, t.clock.Now())
+		if slices.Equal(active, selection.schedules) {
+			t.selectAgainAt(next)
+			t.lock.Unlock()
+			return
 		}
-		allConfigs = append(allConfigs, config)
 	}
+	t.lock.Unlock()
+	t.Updatethrottlers()
+}
+
+func (t *throttlerConfigurator) retryLater() {
+	if t.retryPending.Swap(true) {
+		return
+	}
+	time.AfterFunc(t.retryInterval, func() {
+		t.retryPending.Store(false)
+		t.Updatethrottlers()
+	})
+}
+
+// CurrentConfig returns the config which is currently effective on this node, or nil if none matches
+func (t *throttlerConfigurator) CurrentConfig() *v1beta1.PacemakerConfig {
+	selection := t.currentSelection.Load()
+	if selection == nil {
+		return nil
+	}
+	return selection.config
+}
+
+// ThrottleGroups returns the matchers of the throttle groups of the current config, in the order they are tried
+func (t *throttlerConfigurator) ThrottleGroups() []throttleGroupMatcher {
+	selection := t.currentSelection.Load()
+	if selection == nil {
+		return nil
+	}
+	return selection.groups
+}
+
+// ActiveSchedules returns the schedules of the current config which are applied to its throttlers
+func (t *throttlerConfigurator) ActiveSchedules() []string {
+	selection := t.currentSelection.Load()
+	if selection == nil {
+		return nil
+	}
+	return selection.schedules
+}
 
-	node, err := t.clientset.CoreV1().Nodes().Get(context.Background(), t.nodeName, metav1.GetOptions{})
+// Evaluated reports if the configs were evaluated at least once, even if none of them matches
+func (t *throttlerConfigurator) Evaluated() bool {
+	return t.currentSelection.Load() != nil
+}
+
+// ConfigEvaluations returns the result of the last config selection, ordered by priority
+func (t *throttlerConfigurator) ConfigEvaluations() []ConfigEvaluation {
+	selection := t.currentSelection.Load()
+	if selection == nil {
+		return []ConfigEvaluation{}
+	}
+	return selection.evaluations
+}
+
+// NodeUpdated selects the config again if the labels of the node changed since the last selection
+func (t *throttlerConfigurator) NodeUpdated(node *v1.Node) {
+	selection := t.currentSelection.Load()
+	if selection == nil || labels.Equals(selection.nodeLabels, node.Labels) {
+		return // the first selection happens once the configs are synced
+	}
+	log.Infof("Labels of node %s changed, selecting the config again", node.Name)
+	t.Updatethrottlers()
+}
+
+func (t *throttlerConfigurator) getMatchingConfig(invalid map[string]string) (*configSelection, error) {
+	allConfigs, err := t.configs.Lister().List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configs: %w", err)
+	}
+
+	node, err := t.nodes.Get(t.nodeName)
 	if err != nil {
-		log.Fatalf("Failed to get node %s: %v", t.nodeName, err)
+		return nil, fmt.Errorf("failed to get node %s: %w", t.nodeName, err)
 	}
 
-	sort.Slice(allConfigs, func(i, j int) bool {
-		a := allConfigs[i]
-		b := allConfigs[j]
-		return a.Spec.Priority > b.Spec.Priority
+	matchingConfig, evaluations := selectConfig(allConfigs, node.Labels, invalid)
+	return &configSelection{config: matchingConfig, evaluations: evaluations, nodeLabels: node.Labels}, nil
+}
+
+// configReference is used as the object of events, the objects of the informer have no kind
+func configReference(config *v1beta1.PacemakerConfig) *v1.ObjectReference {
+	return &v1.ObjectReference{
+		APIVersion:      v1beta1.SchemeGroupVersion.String(),
+		Kind:            "PacemakerConfig",
+		Name:            config.Name,
+		UID:             config.UID,
+		ResourceVersion: config.ResourceVersion,
+	}
+}
+
+// selectConfig returns the config with the highest priority which matches the node labels. If it merges, it is merged
+// onto the matching config with the next lower priority, until a config doesn't merge, and the merged config is returned.
+// Configs with the same priority are ordered by name, so every node and the status controller pick the same ones.
+// The invalid configs are skipped, they map the name of the config to the reason.
+func selectConfig(configs []*v1beta1.PacemakerConfig, nodeLabels map[string]string, invalid map[string]string) (*v1beta1.PacemakerConfig, []ConfigEvaluation) {
+	sorted := make([]*v1beta1.PacemakerConfig, len(configs))
+	copy(sorted, configs)
+	sort.Slice(sorted, func(i, j int) bool {
+		a := sorted[i]
+		b := sorted[j]
+		if a.Spec.Priority != b.Spec.Priority {
+			return a.Spec.Priority > b.Spec.Priority
+		}
+		return a.Name < b.Name
 	})
 
-	var matchingConfig *v1alpha.PacemakerConfig
-	for _, config := range allConfigs {
+	var effective []*v1beta1.PacemakerConfig // ordered by priority, all but the last one merge
+	evaluations := make([]ConfigEvaluation, 0, len(sorted))
+	for _, config := range sorted {
 		c := config
-		labelSelector := labels.Set(c.Spec.NodeSelector).AsSelector()
-		if !labelSelector.Matches(labels.Set(node.Labels)) {
-			log.Debugf("Label selector %s does not match node labels %s", labelSelector, node.Labels)
-			continue
+		evaluation := ConfigEvaluation{Name: c.Name, Priority: c.Spec.Priority}
+		labelSelector, err := nodeSelector(c)
+		if err != nil {
+			evaluation.Reason = fmt.Sprintf("invalid node selector: %v", err)
+		} else if !labelSelector.Matches(labels.Set(nodeLabels)) {
+			log.Debugf("Label selector %s does not match node labels %s", labelSelector, nodeLabels)
+			evaluation.Reason = fmt.Sprintf("node selector %s does not match the node labels", labelSelector)
+		} else if reason, ok := invalid[c.Name]; ok {
+			evaluation.Matches = true
+			evaluation.Reason = fmt.Sprintf("matches, but is skipped because it is invalid: %s", reason)
+		} else if len(effective) > 0 && !effective[len(effective)-1].Spec.Merge {
+			evaluation.Matches = true
+			evaluation.Reason = fmt.Sprintf("matches, but is shadowed by %s with a higher priority", effective[0].Name)
+		} else {
+			evaluation.Matches = true
+			evaluation.Effective = true
+			if len(effective) == 0 {
+				evaluation.Reason = "highest priority config which matches the node labels"
+			} else {
+				evaluation.Reason = fmt.Sprintf("matches, %s is merged onto it", effective[len(effective)-1].Name)
+			}
+			effective = append(effective, c)
 		}
-		log.Infof("Config %s matches node labels", c.Name)
-		matchingConfig = c
-		break // we only need the highest priority config which matches
+		evaluations = append(evaluations, evaluation)
+	}
+	if len(effective) == 0 {
+		return nil, evaluations
+	}
+
+	// apply the configs from the lowest priority upwards, the merged config keeps the name of the highest one
+	spec := effective[len(effective)-1].Spec
+	for i := len(effective) - 2; i >= 0; i-- {
+		merged, overrides := v1beta1.MergeSpec(spec, effective[i].Spec)
+		spec = merged
+		evaluations[slices.IndexFunc(evaluations, func(e ConfigEvaluation) bool { return e.Name == effective[i].Name })].Overrides = overrides
+	}
+	matchingConfig := effective[0]
+	if len(effective) > 1 {
+		matchingConfig = matchingConfig.DeepCopy()
+		matchingConfig.Spec = spec
+	}
+	return matchingConfig, evaluations
+}
+
+// nodeSelector returns the selector of the config, a config without selector selects all nodes
+func nodeSelector(config *v1beta1.PacemakerConfig) (labels.Selector, error) {
+	if config.Spec.NodeSelector == nil {
+		return labels.Everything(), nil
 	}
-	return matchingConfig
+	return metav1.LabelSelectorAsSelector(config.Spec.NodeSelector)
 }
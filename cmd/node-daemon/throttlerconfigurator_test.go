@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned/fake"
+	"woehrl01/pod-pacemaker/pkg/generated/informers/externalversions"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// testConfigurator is a configurator whose configs and node are set directly in the caches of the informers
+type testConfigurator struct {
+	*throttlerConfigurator
+	configs  cache.Indexer
+	nodes    cache.Indexer
+	recorder *record.FakeRecorder
+	dynamic  throttler.DynamicThrottler
+}
+
+func newTestConfigurator(t *testing.T) *testConfigurator {
+	t.Helper()
+	configs := externalversions.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Woehrl().V1beta1().PacemakerConfigs()
+	nodes := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	recorder := record.NewFakeRecorder(10)
+	dynamic := throttler.NewDynamicThrottler()
+	c := NewThrottlerConfigurator(configs, corelisters.NewNodeLister(nodes), recorder, "node", dynamic, throttler.RealClock)
+	t.Cleanup(func() { close(c.currentCloseChannel) })
+	return &testConfigurator{throttlerConfigurator: c, configs: configs.Informer().GetIndexer(), nodes: nodes, recorder: recorder, dynamic: dynamic}
+}
+
+func (c *testConfigurator) setNode(t *testing.T, labels map[string]string) {
+	t.Helper()
+	if err := c.nodes.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: labels}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *testConfigurator) setConfig(t *testing.T, config *v1beta1.PacemakerConfig) {
+	t.Helper()
+	if err := c.configs.Update(config); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testClock only moves when the test sets it, its timers never fire
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) After(time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *testClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func maxConcurrentConfig(name string, priority int, value int) *v1beta1.PacemakerConfig {
+	return &v1beta1.PacemakerConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1beta1.PacemakerConfigSpec{
+			Priority:   priority,
+			Throttlers: []v1beta1.Throttler{{MaxConcurrent: &v1beta1.MaxConcurrent{Value: value}}},
+		},
+	}
+}
+
+func invalidConfig(name string, priority int) *v1beta1.PacemakerConfig {
+	return &v1beta1.PacemakerConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1beta1.PacemakerConfigSpec{
+			Priority:   priority,
+			Throttlers: []v1beta1.Throttler{{RateLimit: &v1beta1.RateLimit{Burst: 0}}},
+		},
+	}
+}
+
+// mergingConfig only sets the priority and is merged onto the next matching config
+func mergingConfig(name string, priority int) *v1beta1.PacemakerConfig {
+	return &v1beta1.PacemakerConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1beta1.PacemakerConfigSpec{Priority: priority, Merge: true},
+	}
+}
+
+func unparsableSelectorConfig(name string, priority int) *v1beta1.PacemakerConfig {
+	config := maxConcurrentConfig(name, priority, 1)
+	config.Spec.NodeSelector = &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "pool", Operator: "Unknown", Values: []string{"batch"}},
+	}}
+	return config
+}
+
+func TestUpdatethrottlersFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		// previous is applied before the config under test, nil for a fresh daemon
+		previous *v1beta1.PacemakerConfig
+		configs  []*v1beta1.PacemakerConfig
+		// wantConfig is the name of the effective config, empty if none is
+		wantConfig string
+		// wantThrottlers are the descriptions of the active throttlers
+		wantThrottlers []string
+		wantEvent      string
+	}{
+		{
+			name:           "config which fails to parse is skipped",
+			configs:        []*v1beta1.PacemakerConfig{unparsableSelectorConfig("broken", 10), maxConcurrentConfig("fallback", 0, 3)},
+			wantConfig:     "fallback",
+			wantThrottlers: []string{"maxConcurrent = 3"},
+		},
+		{
+			name:           "invalid values keep the last good throttlers",
+			previous:       maxConcurrentConfig("good", 0, 3),
+			configs:        []*v1beta1.PacemakerConfig{invalidConfig("good", 0)},
+			wantConfig:     "good",
+			wantThrottlers: []string{"maxConcurrent = 3"},
+			wantEvent:      "InvalidConfig",
+		},
+		{
+			name:           "invalid config falls through to the next matching config",
+			previous:       maxConcurrentConfig("good", 0, 3),
+			configs:        []*v1beta1.PacemakerConfig{invalidConfig("bad", 10), maxConcurrentConfig("fallback", 5, 2)},
+			wantConfig:     "fallback",
+			wantThrottlers: []string{"maxConcurrent = 2"},
+			wantEvent:      "InvalidConfig",
+		},
+		{
+			name:           "invalid config is skipped by a merging config",
+			configs:        []*v1beta1.PacemakerConfig{mergingConfig("top", 10), invalidConfig("bad", 5), maxConcurrentConfig("base", 0, 2)},
+			wantConfig:     "top",
+			wantThrottlers: []string{"maxConcurrent = 2"},
+			wantEvent:      "InvalidConfig",
+		},
+		{
+			name:      "fresh daemon starts without throttlers",
+			configs:   []*v1beta1.PacemakerConfig{invalidConfig("bad", 0)},
+			wantEvent: "InvalidConfig",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfigurator(t)
+			c.setNode(t, map[string]string{"pool": "batch"})
+			if tt.previous != nil {
+				c.setConfig(t, tt.previous)
+				c.Updatethrottlers()
+			}
+			for _, config := range tt.configs {
+				c.setConfig(t, config)
+			}
+			c.Updatethrottlers()
+
+			if !c.Evaluated() {
+				t.Fatalf("Evaluated() = false, want true")
+			}
+			gotConfig := ""
+			if config := c.CurrentConfig(); config != nil {
+				gotConfig = config.Name
+			}
+			if gotConfig != tt.wantConfig {
+				t.Errorf("CurrentConfig() = %q, want %q", gotConfig, tt.wantConfig)
+			}
+			throttlers := c.dynamic.GetThrottlers()
+			if len(throttlers) != len(tt.wantThrottlers) {
+				t.Fatalf("got throttlers %v, want %v", throttlers, tt.wantThrottlers)
+			}
+			for i, want := range tt.wantThrottlers {
+				if !strings.Contains(throttlers[i].String(), want) {
+					t.Errorf("throttler %d is %s, want %s", i, throttlers[i], want)
+				}
+			}
+			select {
+			case event := <-c.recorder.Events:
+				if tt.wantEvent == "" || !strings.Contains(event, tt.wantEvent) {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("got no event, want %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdatethrottlersRetriesNodeLookup(t *testing.T) {
+	c := newTestConfigurator(t)
+	c.retryInterval = 10 * time.Millisecond
+	c.setConfig(t, maxConcurrentConfig("config", 0, 3))
+
+	c.Updatethrottlers()
+	if c.Evaluated() {
+		t.Fatalf("Evaluated() = true without a node, want false")
+	}
+	if !c.retryPending.Load() {
+		t.Fatalf("no retry is scheduled after the node lookup failed")
+	}
+
+	c.setNode(t, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.Evaluated() {
+		if time.Now().After(deadline) {
+			t.Fatalf("configs weren't evaluated again after the node appeared")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if config := c.CurrentConfig(); config == nil || config.Name != "config" {
+		t.Errorf("CurrentConfig() = %v, want config", config)
+	}
+}
+
+func TestNodeUpdatedSkipsEvaluatedLabelsOfInvalidConfig(t *testing.T) {
+	c := newTestConfigurator(t)
+	c.setNode(t, map[string]string{"pool": "batch"})
+	c.setConfig(t, maxConcurrentConfig("config", 0, 3))
+	c.Updatethrottlers()
+
+	c.setConfig(t, invalidConfig("config", 0))
+	relabeled := map[string]string{"pool": "web"}
+	c.setNode(t, relabeled)
+	c.Updatethrottlers()
+	<-c.recorder.Events // the invalid config is reported once
+
+	c.NodeUpdated(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node", Labels: relabeled}})
+	select {
+	case event := <-c.recorder.Events:
+		t.Errorf("the config was selected again for the same labels: %s", event)
+	default:
+	}
+	if config := c.CurrentConfig(); config == nil || len(config.Spec.Throttlers) != 1 || config.Spec.Throttlers[0].MaxConcurrent == nil {
+		t.Errorf("CurrentConfig() = %v, want the last good config", config)
+	}
+}
+
+func TestRecheckSchedulesKeepsThrottlersOfTheSameSchedules(t *testing.T) {
+	c := newTestConfigurator(t)
+	c.setNode(t, nil)
+	config := maxConcurrentConfig("config", 0, 3)
+	// starts every minute and lasts an hour, so each start only extends the window
+	config.Spec.Schedules = []v1beta1.Schedule{{
+		Name:       "always",
+		Cron:       "* * * * *",
+		Duration:   &metav1.Duration{Duration: time.Hour},
+		Throttlers: []v1beta1.Throttler{{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 1}}},
+	}}
+	c.setConfig(t, config)
+	c.Updatethrottlers()
+	if got := c.ActiveSchedules(); len(got) != 1 || got[0] != "always" {
+		t.Fatalf("ActiveSchedules() = %v, want [always]", got)
+	}
+	before := c.dynamic.GetThrottlers()
+
+	c.recheckSchedules()
+
+	after := c.dynamic.GetThrottlers()
+	if len(after) != 1 || after[0] != before[0] {
+		t.Errorf("throttlers were rebuilt although the active schedules didn't change")
+	}
+}
+
+// a pod which waits in the throttlers of the previous schedule continues in the throttlers of the next one
+func TestScheduleSwitchMovesTheWaitersToTheNewThrottlers(t *testing.T) {
+	c := newTestConfigurator(t)
+	clock := &testClock{now: time.Date(2026, 1, 5, 21, 59, 0, 0, time.UTC)}
+	c.clock = clock
+	c.setNode(t, nil)
+	config := &v1beta1.PacemakerConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "config"},
+		Spec: v1beta1.PacemakerConfigSpec{
+			Throttlers: []v1beta1.Throttler{
+				{RateLimit: &v1beta1.RateLimit{FillFactor: metav1.Duration{Duration: time.Hour}, Burst: 2}},
+				{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 1}},
+			},
+			Schedules: []v1beta1.Schedule{{
+				Name:       "night",
+				Cron:       "0 22 * * *",
+				Duration:   &metav1.Duration{Duration: 8 * time.Hour},
+				Throttlers: []v1beta1.Throttler{{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 2}}},
+			}},
+		},
+	}
+	c.setConfig(t, config)
+	c.Updatethrottlers()
+	all := throttler.NewAllThrottler(c.dynamic)
+
+	if err := all.AquireSlot(context.Background(), "default/running", throttler.Data{Ticket: 1}); err != nil {
+		t.Fatal(err)
+	}
+	waiting := make(chan error, 1)
+	go func() {
+		waiting <- all.AquireSlot(context.Background(), "default/waiting", throttler.Data{Ticket: 2})
+	}()
+	select {
+	case err := <-waiting:
+		t.Fatalf("the pod wasn't blocked by the concurrency of the day: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Set(time.Date(2026, 1, 5, 22, 0, 30, 0, time.UTC))
+	c.recheckSchedules()
+	if got := c.ActiveSchedules(); len(got) != 1 || got[0] != "night" {
+		t.Fatalf("ActiveSchedules() = %v, want [night]", got)
+	}
+	select {
+	case err := <-waiting:
+		if err != nil {
+			t.Fatalf("the waiting pod failed with the switch: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the waiting pod hangs in the throttlers of the previous schedule")
+	}
+
+	// both tokens were taken before the switch, the limiter of the night doesn't start with a full burst
+	all.ReleaseSlot(context.Background(), "default/running")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := all.AquireSlot(ctx, "default/next", throttler.Data{Ticket: 3}); err == nil {
+		t.Errorf("the pod was admitted with a token of the refilled rate limit")
+	}
+
+	all.ReleaseSlot(context.Background(), "default/waiting")
+	if slots := all.ActiveSlots(); len(slots) != 0 {
+		t.Errorf("slots %v are still active after their release", slots)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ticket keeps the queue position of a pod across kubelet sandbox retries
+type ticket struct {
+	slotId      string
+	number      uint64
+	attempts    int
+	cancelledAt time.Time
+	// attemptStarted is the start of the current attempt, waited the time spent in the previous attempts
+	attemptStarted time.Time
+	waited         time.Duration
+}
+
+// Waited returns the time the pod spent waiting in all attempts, without the backoff of the kubelet between them
+func (t ticket) Waited(now time.Time) time.Duration {
+	if !t.cancelledAt.IsZero() {
+		return t.waited
+	}
+	return t.waited + now.Sub(t.attemptStarted)
+}
+
+// TicketStore hands out queue positions keyed by pod UID. Tickets of cancelled requests are kept
+// for a grace period, so a retried request resumes where the previous one left off.
+type TicketStore struct {
+	tickets     map[types.UID]*ticket
+	next        uint64
+	gracePeriod time.Duration
+	mux         *sync.Mutex
+	now         func() time.Time
+}
+
+func NewTicketStore(gracePeriod time.Duration) *TicketStore {
+	return &TicketStore{
+		tickets:     make(map[types.UID]*ticket),
+		next:        1,
+		gracePeriod: gracePeriod,
+		mux:         &sync.Mutex{},
+		now:         time.Now,
+	}
+}
+
+// Checkout returns the ticket of the pod, either a parked one from a previous attempt or a new one
+func (ts *TicketStore) Checkout(uid types.UID, slotId string) ticket {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+
+	now := ts.now()
+	ts.removeExpired(now)
+
+	t, ok := ts.tickets[uid]
+	if !ok {
+		t = &ticket{
+			slotId: slotId,
+			number: ts.next,
+		}
+		ts.next++
+		ts.tickets[uid] = t
+	}
+	t.attempts++
+	t.cancelledAt = time.Time{}
+	t.attemptStarted = now
+	return *t
+}
+
+// Park keeps the ticket of a cancelled request for the grace period
+func (ts *TicketStore) Park(uid types.UID) {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+	if t, ok := ts.tickets[uid]; ok && t.cancelledAt.IsZero() {
+		now := ts.now()
+		t.waited = t.Waited(now)
+		t.cancelledAt = now
+	}
+}
+
+// Complete removes the ticket after the pod acquired its slot and returns the time it waited in all attempts
+func (ts *TicketStore) Complete(uid types.UID) time.Duration {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+	t, ok := ts.tickets[uid]
+	if !ok {
+		return 0
+	}
+	waitRetriesHistogram.Observe(float64(t.attempts - 1))
+	delete(ts.tickets, uid)
+	return t.Waited(ts.now())
+}
+
+// Position returns the position of the pod among all waiting pods, starting at 1
+func (ts *TicketStore) Position(uid types.UID) int {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+	t, ok := ts.tickets[uid]
+	if !ok {
+		return 0
+	}
+	position := 1
+	for _, other := range ts.tickets {
+		if other.cancelledAt.IsZero() && other.number < t.number {
+			position++
+		}
+	}
+	return position
+}
+
+// Waiting returns the tickets of all pods which are currently waiting, ordered by their position
+func (ts *TicketStore) Waiting() []ticket {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+	waiting := []ticket{}
+	for _, t := range ts.tickets {
+		if t.cancelledAt.IsZero() {
+			waiting = append(waiting, *t)
+		}
+	}
+	sort.Slice(waiting, func(i, j int) bool {
+		return waiting[i].number < waiting[j].number
+	})
+	return waiting
+}
+
+// removeExpired drops parked tickets which outlived the grace period. This needs be called with the lock held.
+func (ts *TicketStore) removeExpired(now time.Time) {
+	for uid, t := range ts.tickets {
+		if !t.cancelledAt.IsZero() && now.Sub(t.cancelledAt) > ts.gracePeriod {
+			delete(ts.tickets, uid)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestTicketStore returns a store on a clock which is only moved by advance
+func newTestTicketStore(gracePeriod time.Duration) (*TicketStore, func(time.Duration)) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	ts := NewTicketStore(gracePeriod)
+	ts.now = func() time.Time { return now }
+	return ts, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTicketStoreKeepsThePositionAcrossRetries(t *testing.T) {
+	ts, advance := newTestTicketStore(time.Minute)
+
+	first := ts.Checkout("uid-first", "default/first")
+	ts.Checkout("uid-second", "default/second")
+	advance(10 * time.Second)
+	ts.Park("uid-first")
+	if got := ts.Position("uid-second"); got != 1 {
+		t.Errorf("Position() of the second pod = %d while the first is parked, want 1", got)
+	}
+
+	// the kubelet backs off before it retries the sandbox
+	advance(30 * time.Second)
+	retried := ts.Checkout("uid-first", "default/first")
+	if retried.number != first.number {
+		t.Errorf("retry got ticket %d, want %d", retried.number, first.number)
+	}
+	if retried.attempts != 2 {
+		t.Errorf("retry has %d attempts, want 2", retried.attempts)
+	}
+	if got := ts.Position("uid-first"); got != 1 {
+		t.Errorf("Position() after the retry = %d, want 1", got)
+	}
+	if got := ts.Position("uid-second"); got != 2 {
+		t.Errorf("Position() of the second pod = %d, want 2", got)
+	}
+	if got := len(ts.Waiting()); got != 2 {
+		t.Errorf("got %d waiting tickets, want 2", got)
+	}
+
+	advance(5 * time.Second)
+	if waited := ts.Complete("uid-first"); waited != 15*time.Second {
+		t.Errorf("Complete() = %s, want the 15s of both attempts without the backoff", waited)
+	}
+	if got := ts.Position("uid-first"); got != 0 {
+		t.Errorf("Position() after Complete() = %d, want 0", got)
+	}
+	if got := ts.Position("uid-second"); got != 1 {
+		t.Errorf("Position() of the second pod after Complete() = %d, want 1", got)
+	}
+}
+
+func TestTicketStoreExpiresParkedTickets(t *testing.T) {
+	tests := []struct {
+		name       string
+		backoff    time.Duration
+		wantResume bool
+	}{
+		{name: "retry within the grace period", backoff: 30 * time.Second, wantResume: true},
+		{name: "retry after the grace period", backoff: 2 * time.Minute, wantResume: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, advance := newTestTicketStore(time.Minute)
+			first := ts.Checkout("uid", "default/web")
+			ts.Park("uid")
+			advance(tt.backoff)
+
+			retried := ts.Checkout("uid", "default/web")
+			if resumed := retried.number == first.number; resumed != tt.wantResume {
+				t.Errorf("retry got ticket %d after ticket %d, want resumed %v", retried.number, first.number, tt.wantResume)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1alpha"
+	"woehrl01/pod-pacemaker/api/v1beta1"
+
+	"github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	configFiles  = flag.StringSlice("config", []string{}, "PacemakerConfig manifests to compare, can be repeated")
+	workloadFile = flag.String("workload", "", "The workload and node model, defaults to 40 pods on 8 cpus")
+	traceFile    = flag.String("trace", "", "A flight recorder export (pacemakerctl flightrecorder) whose pods are replayed instead of the synthetic pods")
+	maxDuration  = flag.Duration("max-duration", time.Hour, "The simulated time after which the simulation of a config is stopped")
+	debugLogging = flag.Bool("debug-logging", false, "Enable debug logging of the throttlers")
+)
+
+const usage = `Usage: pacemaker-sim --config <file> [--config <file>...] [flags]
+
+Runs the throttlers of each PacemakerConfig against a virtual clock and a synthetic node,
+and compares the time until the pods are started.
+
+Flags:
+`
+
+type namedConfig struct {
+	name   string
+	config []v1beta1.Throttler
+	warmup *v1beta1.Warmup
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *debugLogging {
+		logrus.SetLevel(logrus.DebugLevel)
+	} else {
+		logrus.SetLevel(logrus.WarnLevel)
+	}
+
+	if len(*configFiles) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	configs := []namedConfig{}
+	for _, path := range *configFiles {
+		config, err := loadConfig(path)
+		if err != nil {
+			fail(err)
+		}
+		configs = append(configs, config)
+	}
+
+	workload, err := loadWorkload(*workloadFile)
+	if err != nil {
+		fail(err)
+	}
+
+	pods := syntheticPods(workload)
+	if *traceFile != "" {
+		if pods, err = tracePods(*traceFile, workload); err != nil {
+			fail(err)
+		}
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+	fmt.Fprintf(out, "%d pods on %d cpus\n\n", len(pods), workload.Node.Cpus)
+	fmt.Fprintln(out, "CONFIG\tP50\tP90\tP99\tMAX\tWAIT P90\tMAX QUEUE\tPODS/MIN\tMAKESPAN\tNOT STARTED")
+	for _, config := range configs {
+		r, err := simulate(config.name, config.config, config.warmup, workload, pods, *maxDuration)
+		if err != nil {
+			out.Flush()
+			fail(fmt.Errorf("invalid config %s: %w", config.name, err))
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%.1f\t%s\t%d\n", r.Config,
+			round(percentile(r.TimeToStart, 50)), round(percentile(r.TimeToStart, 90)),
+			round(percentile(r.TimeToStart, 99)), round(percentile(r.TimeToStart, 100)),
+			round(percentile(r.Wait, 90)), r.MaxQueue, r.Throughput(), round(r.Makespan), r.NotStarted)
+	}
+}
+
+func loadConfig(path string) (namedConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return namedConfig{}, err
+	}
+	config, err := parseConfig(content)
+	if err != nil {
+		return namedConfig{}, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	name := config.Name
+	if name == "" {
+		name = path
+	}
+	if errs := config.Spec.Validate(); len(errs) > 0 {
+		return namedConfig{}, fmt.Errorf("invalid config %s: %w", name, errs.ToAggregate())
+	}
+	if len(config.Spec.ThrottleGroups) > 0 {
+		// the simulated pods have no labels, so they would all pass the default chain anyway
+		fmt.Fprintf(os.Stderr, "Warning: config %s has throttle groups, only its default throttlers are simulated\n", name)
+	}
+	if len(config.Spec.Schedules) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: config %s has schedules, they are not applied in the simulation\n", name)
+	}
+	return namedConfig{name: name, config: config.Spec.Throttlers, warmup: config.Spec.Warmup}, nil
+}
+
+// parseConfig reads a config of any served version, v1alpha configs are converted to v1beta1
+func parseConfig(content []byte) (*v1beta1.PacemakerConfig, error) {
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(content, &typeMeta); err != nil {
+		return nil, err
+	}
+	if typeMeta.APIVersion == "woehrl.net/v1alpha" {
+		var config v1alpha.PacemakerConfig
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, err
+		}
+		return v1beta1.ConvertFromV1alpha(&config)
+	}
+	var config v1beta1.PacemakerConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(100 * time.Millisecond)
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/throttler"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// the throttlers run in goroutines, the simulation waits until they don't change anymore before it advances the clock
+	settlePoll   = 200 * time.Microsecond
+	settleRounds = 10
+	// the sampling time of the cpu and io load, like the real samplers
+	sampleDuration = 5 * time.Second
+	// the time constant of the 1 minute load average
+	loadAvgPeriod = time.Minute
+)
+
+var simulationStart = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Result is the outcome of a single config
+type Result struct {
+	Config string
+	// TimeToStart is the time from the creation of a pod until it was started, ordered
+	TimeToStart []time.Duration
+	// Wait is the time a pod waited for its slot, ordered
+	Wait          []time.Duration
+	MaxQueue      int
+	Makespan      time.Duration
+	NotStarted    int
+	SimulatedTime time.Duration
+}
+
+// Throughput returns the started pods per minute
+func (r Result) Throughput() float64 {
+	if r.Makespan <= 0 {
+		return 0
+	}
+	return float64(len(r.TimeToStart)) / r.Makespan.Minutes()
+}
+
+// node models the contention on the node, pods start slower if the cpu is oversubscribed
+type node struct {
+	mu       sync.Mutex
+	model    NodeModel
+	cpuCost  float64
+	ioCost   float64
+	starting map[string]time.Duration // the remaining uncontended startup time
+	loadAvg  float64
+}
+
+func (n *node) demand() float64 {
+	return n.model.BaseCpu + float64(len(n.starting))*n.cpuCost
+}
+
+// rate is the progress of the starting pods per second
+func (n *node) rate() float64 {
+	return math.Min(1, float64(n.model.Cpus)/math.Max(n.demand(), 0.001))
+}
+
+func (n *node) cpuLoad() float64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return math.Min(100, n.demand()/float64(n.model.Cpus)*100)
+}
+
+func (n *node) ioLoad() float64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return math.Min(100, n.model.BaseIo+float64(len(n.starting))*n.ioCost)
+}
+
+func (n *node) loadAverage(perCpu bool) float64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if perCpu {
+		return n.loadAvg / float64(n.model.Cpus)
+	}
+	return n.loadAvg
+}
+
+func (n *node) start(name string, startupTime time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.starting[name] = startupTime
+}
+
+// nextCompletion returns the time until the next pod finished its startup
+func (n *node) nextCompletion() (time.Duration, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.starting) == 0 {
+		return 0, false
+	}
+	shortest := time.Duration(math.MaxInt64)
+	for _, remaining := range n.starting {
+		shortest = min(shortest, remaining)
+	}
+	return time.Duration(float64(shortest) / n.rate()), true
+}
+
+// progress advances the starting pods and returns the ones which finished
+func (n *node) progress(dt time.Duration) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	decay := math.Exp(-dt.Seconds() / loadAvgPeriod.Seconds())
+	n.loadAvg = n.loadAvg*decay + n.demand()*(1-decay)
+
+	done := []string{}
+	step := time.Duration(float64(dt) * n.rate())
+	for name, remaining := range n.starting {
+		remaining -= step
+		if remaining <= time.Millisecond { // rounding of the rate
+			done = append(done, name)
+			delete(n.starting, name)
+			continue
+		}
+		n.starting[name] = remaining
+	}
+	sort.Strings(done)
+	return done
+}
+
+type acquired struct {
+	name string
+	err  error
+}
+
+// simulate runs the pods against the throttlers of the config until all pods started or maxDuration passed
+func simulate(name string, config []v1beta1.Throttler, warmup *v1beta1.Warmup, workload Workload, pods []simPod, maxDuration time.Duration) (Result, error) {
+	clock := newVirtualClock(simulationStart)
+	n := &node{
+		model:    workload.Node,
+		cpuCost:  workload.CpuCost,
+		ioCost:   workload.IoCost,
+		starting: map[string]time.Duration{},
+	}
+	env := throttler.Environment{
+		Clock:  clock,
+		NumCPU: workload.Node.Cpus,
+		CpuLoad: func() float64 {
+			<-clock.After(sampleDuration)
+			return n.cpuLoad()
+		},
+		IoLoad: func() float64 {
+			<-clock.After(sampleDuration)
+			return n.ioLoad()
+		},
+		LoadAvg: n.loadAverage,
+	}
+	if warmup != nil {
+		// the simulated node becomes ready when the simulation starts
+		env.Scale = throttler.NewWarmupRamp(warmup, clock, func() (time.Time, bool) { return simulationStart, true }).Fraction
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	throttlers, err := env.Build(config, stop)
+	if err != nil {
+		return Result{}, err
+	}
+	dynamic := throttler.NewDynamicThrottler()
+	dynamic.SetThrottlers(throttlers)
+	chain := throttler.NewAllThrottler(dynamic)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make(chan acquired, len(pods))
+	byName := make(map[string]simPod, len(pods))
+	acquiredAt := map[string]time.Time{}
+	startedAt := map[string]time.Time{}
+	result := Result{Config: name}
+
+	// settle waits until all goroutines of the throttlers are blocked, by waiting for a stable state
+	settle := func() {
+		last := ""
+		stable := 0
+		for stable < settleRounds {
+			time.Sleep(settlePoll)
+		drain:
+			for {
+				select {
+				case a := <-results:
+					if a.err == nil {
+						acquiredAt[a.name] = clock.Now()
+						n.start(a.name, byName[a.name].startupTime)
+					}
+				default:
+					break drain
+				}
+			}
+			state := fmt.Sprintf("%d/%d", len(acquiredAt), clock.PendingTimers())
+			for _, child := range chain.Describe().Children {
+				state += fmt.Sprintf("/%d:%d", child.ActiveSlots, child.Waiters)
+			}
+			if state == last {
+				stable++
+			} else {
+				stable = 0
+				last = state
+			}
+		}
+	}
+
+	next := 0
+	for {
+		settle()
+
+		now := clock.Now()
+		elapsed := now.Sub(simulationStart)
+		result.MaxQueue = max(result.MaxQueue, next-len(acquiredAt))
+		if len(startedAt) == len(pods) || elapsed >= maxDuration {
+			break
+		}
+
+		// the next event is either a new pod, a started pod or a timer of the throttlers
+		until := maxDuration - elapsed
+		if next < len(pods) {
+			until = min(until, pods[next].arrival-elapsed)
+		}
+		if d, ok := n.nextCompletion(); ok {
+			until = min(until, d)
+		}
+		if t, ok := clock.NextTimer(); ok {
+			until = min(until, t.Sub(now))
+		}
+		until = max(until, 0)
+
+		done := n.progress(until)
+		clock.AdvanceTo(now.Add(until))
+		now = clock.Now()
+
+		for _, podName := range done {
+			startedAt[podName] = now
+			chain.ReleaseSlot(ctx, podName) // the pod is fully started
+		}
+		for next < len(pods) && pods[next].arrival <= now.Sub(simulationStart) {
+			pod := pods[next]
+			byName[pod.name] = pod
+			data := throttler.Data{Pod: newPod(pod.name), Ticket: uint64(next)}
+			go func() {
+				results <- acquired{name: pod.name, err: chain.AquireSlot(ctx, pod.name, data)}
+			}()
+			next++
+		}
+	}
+
+	result.SimulatedTime = clock.Now().Sub(simulationStart)
+	var lastStart time.Duration
+	for _, pod := range pods {
+		started, ok := startedAt[pod.name]
+		if !ok {
+			result.NotStarted++
+			continue
+		}
+		result.TimeToStart = append(result.TimeToStart, started.Sub(simulationStart)-pod.arrival)
+		result.Wait = append(result.Wait, acquiredAt[pod.name].Sub(simulationStart)-pod.arrival)
+		lastStart = max(lastStart, started.Sub(simulationStart))
+	}
+	if len(pods) > 0 {
+		result.Makespan = lastStart - pods[0].arrival
+	}
+	sort.Slice(result.TimeToStart, func(i, j int) bool { return result.TimeToStart[i] < result.TimeToStart[j] })
+	sort.Slice(result.Wait, func(i, j int) bool { return result.Wait[i] < result.Wait[j] })
+	return result, nil
+}
+
+func newPod(slotName string) *v1.Pod {
+	namespace, name, found := strings.Cut(slotName, "/")
+	if !found {
+		namespace, name = "default", slotName
+	}
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+// percentile returns the p-th percentile of the ordered durations
+func percentile(ordered []time.Duration, p float64) time.Duration {
+	if len(ordered) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(ordered)))) - 1
+	return ordered[max(0, min(i, len(ordered)-1))]
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Workload describes the node and the pods which are started on it
+type Workload struct {
+	Node NodeModel `json:"node"`
+	// Pods is the number of pods, ignored if a trace is used
+	Pods int `json:"pods"`
+	// ArrivalInterval is the time between two pods, 0 creates all pods at once
+	ArrivalInterval metav1.Duration `json:"arrivalInterval"`
+	StartupTime     Distribution    `json:"startupTime"`
+	// CpuCost is the number of cores a pod uses while it is starting
+	CpuCost float64 `json:"cpuCost"`
+	// IoCost is the IO wait in percent a pod adds while it is starting
+	IoCost float64 `json:"ioCost"`
+	Seed   int64   `json:"seed"`
+}
+
+type NodeModel struct {
+	Cpus int `json:"cpus"`
+	// BaseCpu is the number of cores used by the pods which are already running
+	BaseCpu float64 `json:"baseCpu"`
+	// BaseIo is the IO wait in percent caused by the pods which are already running
+	BaseIo float64 `json:"baseIo"`
+}
+
+// Distribution of a duration, the startup time of a pod is its duration without any contention
+type Distribution struct {
+	// Type is one of fixed, uniform, normal or exponential
+	Type   string          `json:"type"`
+	Mean   metav1.Duration `json:"mean"`
+	Stddev metav1.Duration `json:"stddev"`
+	Min    metav1.Duration `json:"min"`
+	Max    metav1.Duration `json:"max"`
+}
+
+var defaultWorkload = Workload{
+	Node:            NodeModel{Cpus: 8},
+	Pods:            40,
+	ArrivalInterval: metav1.Duration{},
+	StartupTime: Distribution{
+		Type:   "normal",
+		Mean:   metav1.Duration{Duration: 20 * time.Second},
+		Stddev: metav1.Duration{Duration: 5 * time.Second},
+	},
+	CpuCost: 1,
+	IoCost:  2,
+	Seed:    1,
+}
+
+// simPod is a pod of the workload with its arrival and startup time
+type simPod struct {
+	name        string
+	arrival     time.Duration
+	startupTime time.Duration
+}
+
+func loadWorkload(path string) (Workload, error) {
+	workload := defaultWorkload
+	if path == "" {
+		return workload, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return workload, err
+	}
+	if err := yaml.UnmarshalStrict(content, &workload); err != nil {
+		return workload, fmt.Errorf("failed to parse workload %s: %w", path, err)
+	}
+	if workload.Node.Cpus < 1 {
+		return workload, fmt.Errorf("node.cpus must be at least 1")
+	}
+	return workload, nil
+}
+
+func (d Distribution) sample(r *rand.Rand) time.Duration {
+	mean := d.Mean.Duration.Seconds()
+	var v float64
+	switch d.Type {
+	case "", "fixed":
+		v = mean
+	case "uniform":
+		v = d.Min.Duration.Seconds() + r.Float64()*(d.Max.Duration.Seconds()-d.Min.Duration.Seconds())
+	case "normal":
+		v = mean + r.NormFloat64()*d.Stddev.Duration.Seconds()
+	case "exponential":
+		v = r.ExpFloat64() * mean
+	default:
+		v = mean
+	}
+	v = math.Max(v, d.Min.Duration.Seconds())
+	if d.Max.Duration > 0 {
+		v = math.Min(v, d.Max.Duration.Seconds())
+	}
+	return time.Duration(math.Max(v, 0.1) * float64(time.Second))
+}
+
+// syntheticPods creates the pods of the workload
+func syntheticPods(w Workload) []simPod {
+	r := rand.New(rand.NewSource(w.Seed))
+	pods := make([]simPod, 0, w.Pods)
+	for i := 0; i < w.Pods; i++ {
+		pods = append(pods, simPod{
+			name:        fmt.Sprintf("default/pod-%d", i),
+			arrival:     time.Duration(i) * w.ArrivalInterval.Duration,
+			startupTime: w.StartupTime.sample(r),
+		})
+	}
+	return pods
+}
+
+// tracePods replays the pods of a flight recorder export, pods without a release use the startup time of the workload
+func tracePods(path string, w Workload) ([]simPod, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	arrivals := map[string]time.Time{}
+	startupTimes := map[string]time.Duration{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry flightrecorder.Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse trace %s: %w", path, err)
+		}
+		switch entry.Kind {
+		case flightrecorder.KindWaitRequest:
+			if _, ok := arrivals[entry.Slot]; !ok { // retried requests keep their first arrival
+				arrivals[entry.Slot] = entry.Time
+			}
+		case flightrecorder.KindRelease:
+			if entry.Message != "started" {
+				continue
+			}
+			if heldFor, ok := entry.Fields["heldFor"].(string); ok {
+				if d, err := time.ParseDuration(heldFor); err == nil {
+					startupTimes[entry.Slot] = d
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(arrivals) == 0 {
+		return nil, fmt.Errorf("trace %s contains no wait requests", path)
+	}
+
+	var first time.Time
+	for _, t := range arrivals {
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+	}
+
+	names := make([]string, 0, len(arrivals))
+	for name := range arrivals {
+		names = append(names, name)
+	}
+	sort.Strings(names) // the random startup times must not depend on the map order
+
+	r := rand.New(rand.NewSource(w.Seed))
+	pods := make([]simPod, 0, len(arrivals))
+	for _, name := range names {
+		startupTime, ok := startupTimes[name]
+		if !ok {
+			startupTime = w.StartupTime.sample(r)
+		}
+		pods = append(pods, simPod{name: name, arrival: arrivals[name].Sub(first), startupTime: startupTime})
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].arrival == pods[j].arrival {
+			return pods[i].name < pods[j].name
+		}
+		return pods[i].arrival < pods[j].arrival
+	})
+	return pods, nil
+}
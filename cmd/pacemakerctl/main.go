@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "woehrl01/pod-pacemaker/proto"
+)
+
+var (
+	daemonSocket = flag.String("daemon-socket", "/var/run/pod-pacemaker/pod-pacemaker.sock", "The socket for the daemon")
+	timeout      = flag.Duration("timeout", 10*time.Second, "The timeout for the request to the daemon")
+)
+
+const usage = `Usage: pacemakerctl [flags] <command> [args]
+
+Commands:
+  slots             List the active slots with their acquire time and owning throttler
+  waiters           List the queued pods with their position and elapsed time
+  config            Show the effective PacemakerConfig and why other configs did not match
+  throttlers        Show the state of the active throttlers, e.g. limits, usage and measured load
+  flightrecorder    Export the recent decisions, pod events, load samples and config changes as JSON lines
+  release <slot>    Force-release the slot of a pod, e.g. "default/my-pod"
+  pause             Pause admission, no pod acquires a slot until resumed
+  resume            Resume admission
+
+Flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	conn, err := grpc.NewClient(fmt.Sprintf("unix://%s", *daemonSocket),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		fail(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	client := pb.NewAdminClient(conn)
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	switch flag.Arg(0) {
+	case "slots":
+		err = listSlots(ctx, client, out)
+	case "waiters":
+		err = listWaiters(ctx, client, out)
+	case "config":
+		err = showConfig(ctx, client, out)
+	case "throttlers":
+		err = describeThrottlers(ctx, client, out)
+	case "flightrecorder":
+		err = exportFlightRecorder(ctx, client)
+	case "release":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = releaseSlot(ctx, client, flag.Arg(1))
+	case "pause":
+		err = setAdmission(ctx, client, true)
+	case "resume":
+		err = setAdmission(ctx, client, false)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		out.Flush()
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
+
+func listSlots(ctx context.Context, client pb.AdminClient, out *tabwriter.Writer) error {
+	r, err := client.ListSlots(ctx, &pb.ListSlotsRequest{})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "SLOT\tACQUIRED\tAGE\tTHROTTLER")
+	for _, slot := range r.Slots {
+		acquired, age := "-", "-"
+		if slot.AcquiredAt != nil {
+			acquired = slot.AcquiredAt.AsTime().Local().Format(time.RFC3339)
+			age = time.Since(slot.AcquiredAt.AsTime()).Round(time.Second).String()
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", slot.SlotName, acquired, age, slot.Throttler)
+	}
+	return nil
+}
+
+func listWaiters(ctx context.Context, client pb.AdminClient, out *tabwriter.Writer) error {
+	r, err := client.ListWaiters(ctx, &pb.ListWaitersRequest{})
+	if err != nil {
+		return err
+	}
+	if r.AdmissionPaused {
+		fmt.Fprintln(out, "Admission is paused")
+	}
+	fmt.Fprintln(out, "POSITION\tSLOT\tELAPSED\tATTEMPTS\tBLOCKED BY")
+	for _, waiter := range r.Waiters {
+		fmt.Fprintf(out, "%d\t%s\t%s\t%d\t%s\n", waiter.Position, waiter.SlotName, waiter.Elapsed.AsDuration().Round(time.Second), waiter.Attempts, waiter.BlockedBy)
+	}
+	return nil
+}
+
+func showConfig(ctx context.Context, client pb.AdminClient, out *tabwriter.Writer) error {
+	r, err := client.GetConfig(ctx, &pb.GetConfigRequest{})
+	if err != nil {
+		return err
+	}
+	if r.EffectiveConfig == "" {
+		fmt.Fprintln(out, "No config matches this node")
+	} else {
+		fmt.Fprintf(out, "Effective config: %s\n", r.EffectiveConfig)
+	}
+	fmt.Fprintln(out, "CONFIG\tPRIORITY\tEFFECTIVE\tREASON\tOVERRIDES")
+	for _, evaluation := range r.Evaluations {
+		overrides := strings.Join(evaluation.Overrides, ",")
+		if overrides == "" {
+			overrides = "-"
+		}
+		fmt.Fprintf(out, "%s\t%d\t%v\t%s\t%s\n", evaluation.Name, evaluation.Priority, evaluation.Effective, evaluation.Reason, overrides)
+	}
+	return nil
+}
+
+func describeThrottlers(ctx context.Context, client pb.AdminClient, out *tabwriter.Writer) error {
+	r, err := client.DescribeThrottlers(ctx, &pb.DescribeThrottlersRequest{})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "GROUP\tTYPE\tLIMIT\tUSAGE\tLOAD\tPENDING\tTOKENS\tACTIVE\tWAITERS\tDESCRIPTION")
+	for _, snapshot := range r.Snapshot.GetChildren() {
+		group := snapshot.Group
+		if group == "" {
+			group = "-"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", group, snapshot.Type,
+			formatOptional(snapshot.Limit), formatOptional(snapshot.Usage), formatOptional(snapshot.Load),
+			formatOptional(snapshot.PendingIncrement), formatOptional(snapshot.TokensAvailable),
+			snapshot.ActiveSlots, snapshot.Waiters, snapshot.Description)
+	}
+	return nil
+}
+
+func formatOptional(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.4g", *v)
+}
+
+func exportFlightRecorder(ctx context.Context, client pb.AdminClient) error {
+	stream, err := client.ExportFlightRecorder(ctx, &pb.ExportFlightRecorderRequest{})
+	if err != nil {
+		return err
+	}
+	for {
+		r, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range r.Entries {
+			fmt.Println(entry)
+		}
+	}
+}
+
+func releaseSlot(ctx context.Context, client pb.AdminClient, slot string) error {
+	r, err := client.ReleaseSlot(ctx, &pb.ReleaseSlotRequest{SlotName: slot})
+	if err != nil {
+		return err
+	}
+	if !r.Released {
+		return fmt.Errorf("slot %s is not active", slot)
+	}
+	fmt.Printf("Released slot %s\n", slot)
+	return nil
+}
+
+func setAdmission(ctx context.Context, client pb.AdminClient, paused bool) error {
+	r, err := client.SetAdmission(ctx, &pb.SetAdmissionRequest{Paused: paused})
+	if err != nil {
+		return err
+	}
+	if r.Paused {
+		fmt.Println("Admission paused")
+	} else {
+		fmt.Println("Admission resumed")
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"woehrl01/pod-pacemaker/api/v1alpha"
+	"woehrl01/pod-pacemaker/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const v1alphaVersion = "woehrl.net/v1alpha"
+
+// the conversion request of a list contains all configs, so it is allowed to be larger than an admission request
+const maxConversionRequestSize = 16 << 20
+
+// converter converts PacemakerConfigs between the served versions, the API server calls it for every read and write
+type converter struct{}
+
+func (c *converter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxConversionRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := apiextensionsv1.ConversionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("invalid conversion review: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	review.Response = convert(review.Request)
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Warnf("Failed to write conversion response: %v", err)
+	}
+}
+
+func convert(request *apiextensionsv1.ConversionRequest) *apiextensionsv1.ConversionResponse {
+	response := &apiextensionsv1.ConversionResponse{UID: request.UID}
+	converted := make([]runtime.RawExtension, 0, len(request.Objects))
+	for _, obj := range request.Objects {
+		raw, err := convertObject(obj.Raw, request.DesiredAPIVersion)
+		if err != nil {
+			// the API server fails the whole request, the other objects can't be returned
+			log.Warnf("Failed to convert config to %s: %v", request.DesiredAPIVersion, err)
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return response
+		}
+		converted = append(converted, runtime.RawExtension{Raw: raw})
+	}
+	response.ConvertedObjects = converted
+	response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return response
+}
+
+func convertObject(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, err
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+
+	switch {
+	case typeMeta.APIVersion == v1alphaVersion && desiredAPIVersion == v1beta1.SchemeGroupVersion.String():
+		in := &v1alpha.PacemakerConfig{}
+		if err := json.Unmarshal(raw, in); err != nil {
+			return nil, err
+		}
+		out, err := v1beta1.ConvertFromV1alpha(in)
+		if err != nil {
+			return nil, fmt.Errorf("config %s can't be converted: %w", in.Name, err)
+		}
+		return json.Marshal(out)
+	case typeMeta.APIVersion == v1beta1.SchemeGroupVersion.String() && desiredAPIVersion == v1alphaVersion:
+		in := &v1beta1.PacemakerConfig{}
+		if err := json.Unmarshal(raw, in); err != nil {
+			return nil, err
+		}
+		out, err := v1beta1.ConvertToV1alpha(in)
+		if err != nil {
+			return nil, fmt.Errorf("config %s can't be converted: %w", in.Name, err)
+		}
+		return json.Marshal(out)
+	default:
+		return nil, fmt.Errorf("unsupported conversion from %s to %s", typeMeta.APIVersion, desiredAPIVersion)
+	}
+}
+
+// decodeConfig returns the config of an admission request in v1beta1, a v1alpha config is converted first
+func decodeConfig(raw []byte) (*v1beta1.PacemakerConfig, error) {
+	converted, err := convertObject(raw, v1beta1.SchemeGroupVersion.String())
+	if err != nil {
+		return nil, err
+	}
+	config := &v1beta1.PacemakerConfig{}
+	if err := json.Unmarshal(converted, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
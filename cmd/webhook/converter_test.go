@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1alpha"
+	"woehrl01/pod-pacemaker/api/v1beta1"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestConvertObjectRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		spec v1beta1.PacemakerConfigSpec
+		// wantAnnotation is set if v1alpha can't express the spec
+		wantAnnotation bool
+	}{
+		{
+			name: "expressible in v1alpha",
+			spec: v1beta1.PacemakerConfigSpec{
+				Priority:     10,
+				NodeSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "batch"}},
+				Throttlers: []v1beta1.Throttler{
+					{RateLimit: &v1beta1.RateLimit{FillFactor: metav1.Duration{Duration: time.Second}, Burst: 5}},
+					{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 3}},
+				},
+			},
+		},
+		{
+			name: "match expressions",
+			spec: v1beta1.PacemakerConfigSpec{
+				NodeSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "pool", Operator: metav1.LabelSelectorOpIn, Values: []string{"batch", "web"}},
+				}},
+				Throttlers: []v1beta1.Throttler{{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 3}}},
+			},
+			wantAnnotation: true,
+		},
+		{
+			name: "two throttlers of the same type",
+			spec: v1beta1.PacemakerConfigSpec{
+				Throttlers: []v1beta1.Throttler{
+					{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 3}},
+					{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 5}},
+				},
+			},
+			wantAnnotation: true,
+		},
+		{
+			name: "merged config",
+			spec: v1beta1.PacemakerConfigSpec{
+				Merge:      true,
+				Throttlers: []v1beta1.Throttler{{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 3}}},
+			},
+			wantAnnotation: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := &v1beta1.PacemakerConfig{
+				TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "PacemakerConfig"},
+				ObjectMeta: metav1.ObjectMeta{Name: "config", Labels: map[string]string{"team": "a"}},
+				Spec:       tt.spec,
+			}
+			raw, err := json.Marshal(original)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := convertObject(raw, v1alphaVersion)
+			if err != nil {
+				t.Fatalf("conversion to v1alpha failed: %v", err)
+			}
+			alpha := &v1alpha.PacemakerConfig{}
+			if err := json.Unmarshal(stored, alpha); err != nil {
+				t.Fatal(err)
+			}
+			if alpha.APIVersion != v1alphaVersion {
+				t.Errorf("got apiVersion %s, want %s", alpha.APIVersion, v1alphaVersion)
+			}
+			if _, ok := alpha.Annotations[v1beta1.SpecAnnotation]; ok != tt.wantAnnotation {
+				t.Errorf("annotation %s is set: %v, want %v", v1beta1.SpecAnnotation, ok, tt.wantAnnotation)
+			}
+
+			read, err := convertObject(stored, v1beta1.SchemeGroupVersion.String())
+			if err != nil {
+				t.Fatalf("conversion to v1beta1 failed: %v", err)
+			}
+			roundTripped := &v1beta1.PacemakerConfig{}
+			if err := json.Unmarshal(read, roundTripped); err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(roundTripped.Spec, original.Spec) {
+				t.Errorf("got spec %+v after the round trip, want %+v", roundTripped.Spec, original.Spec)
+			}
+			if _, ok := roundTripped.Annotations[v1beta1.SpecAnnotation]; ok {
+				t.Errorf("annotation %s is left on the v1beta1 config", v1beta1.SpecAnnotation)
+			}
+			if roundTripped.Labels["team"] != "a" {
+				t.Errorf("got labels %v, want the labels of the original", roundTripped.Labels)
+			}
+		})
+	}
+}
+
+// v1alpha skips throttlers which aren't configured completely, so they must not show up in v1beta1
+func TestConvertObjectSkipsPartialV1alphaThrottlers(t *testing.T) {
+	tests := []struct {
+		name   string
+		config v1alpha.NodeThrottleConfig
+		want   []v1beta1.Throttler
+	}{
+		{
+			name:   "rate limit without burst",
+			config: v1alpha.NodeThrottleConfig{RateLimit: v1alpha.RateLimitConfig{FillFactor: "1s"}},
+		},
+		{
+			name:   "rate limit without fill factor",
+			config: v1alpha.NodeThrottleConfig{RateLimit: v1alpha.RateLimitConfig{Burst: 5}},
+		},
+		{
+			name:   "load average without max load",
+			config: v1alpha.NodeThrottleConfig{LoadAvg: v1alpha.LoadAvg{IncrementBy: "0.5"}},
+		},
+		{
+			name: "cpu and io without max load",
+			config: v1alpha.NodeThrottleConfig{
+				Cpu: v1alpha.Cpu{IncrementBy: "5"},
+				IO:  v1alpha.IO{IncrementBy: "5"},
+			},
+		},
+		{
+			name: "partial throttlers next to complete ones",
+			config: v1alpha.NodeThrottleConfig{
+				RateLimit:     v1alpha.RateLimitConfig{FillFactor: "1s"},
+				MaxConcurrent: v1alpha.MaxConcurrentConfig{Value: 3},
+				Cpu:           v1alpha.Cpu{MaxLoad: "80"},
+			},
+			want: []v1beta1.Throttler{
+				{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 3}},
+				{Cpu: &v1beta1.LoadLimit{MaxLoad: resource.MustParse("80")}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(&v1alpha.PacemakerConfig{
+				TypeMeta:   metav1.TypeMeta{APIVersion: v1alphaVersion, Kind: "PacemakerConfig"},
+				ObjectMeta: metav1.ObjectMeta{Name: "config"},
+				Spec:       v1alpha.PacemakerConfigSpec{ThrottleConfig: tt.config},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			read, err := convertObject(raw, v1beta1.SchemeGroupVersion.String())
+			if err != nil {
+				t.Fatalf("conversion to v1beta1 failed: %v", err)
+			}
+			config := &v1beta1.PacemakerConfig{}
+			if err := json.Unmarshal(read, config); err != nil {
+				t.Fatal(err)
+			}
+			if !equality.Semantic.DeepEqual(config.Spec.Throttlers, tt.want) {
+				t.Errorf("got throttlers %+v, want %+v", config.Spec.Throttlers, tt.want)
+			}
+		})
+	}
+}
+
+// a v1alpha client which changes the spec of a config with a kept spec replaces the kept spec
+func TestConvertObjectIgnoresOutdatedSpecAnnotation(t *testing.T) {
+	original := &v1beta1.PacemakerConfig{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "PacemakerConfig"},
+		ObjectMeta: metav1.ObjectMeta{Name: "config"},
+		Spec: v1beta1.PacemakerConfigSpec{
+			Merge:      true,
+			Throttlers: []v1beta1.Throttler{{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 3}}},
+		},
+	}
+	raw, err := json.Marshal(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := convertObject(raw, v1alphaVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alpha := &v1alpha.PacemakerConfig{}
+	if err := json.Unmarshal(stored, alpha); err != nil {
+		t.Fatal(err)
+	}
+	alpha.Spec.ThrottleConfig.MaxConcurrent.Value = 7
+	edited, err := json.Marshal(alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := decodeConfig(edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Spec.Merge || len(config.Spec.Throttlers) != 1 || config.Spec.Throttlers[0].MaxConcurrent.Value != 7 {
+		t.Errorf("got spec %+v, want the edited v1alpha spec", config.Spec)
+	}
+}
+
+func TestConvertFailsTheWholeRequest(t *testing.T) {
+	valid, err := json.Marshal(&v1beta1.PacemakerConfig{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "PacemakerConfig"},
+		ObjectMeta: metav1.ObjectMeta{Name: "valid"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := convert(&apiextensionsv1.ConversionRequest{
+		UID:               "request",
+		DesiredAPIVersion: v1alphaVersion,
+		Objects: []runtime.RawExtension{
+			{Raw: valid},
+			{Raw: []byte(`{"apiVersion":"woehrl.net/v2","kind":"PacemakerConfig"}`)},
+		},
+	})
+	if response.UID != "request" || response.Result.Status != metav1.StatusFailure || len(response.ConvertedObjects) != 0 {
+		t.Errorf("got response %+v, want a failure without objects", response)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	crdName = "pacemakerconfigs.woehrl.net"
+	// the CRD is checked again in this interval, e.g. after it was applied again or the certificate was rotated
+	conversionCheckInterval = 5 * time.Minute
+	conversionRetryInterval = 10 * time.Second
+)
+
+// ensureConversionWebhook points the conversion of the CRD to this webhook until the context is done.
+// Helm doesn't manage the CRD after the installation, so the webhook configures it itself.
+func ensureConversionWebhook(ctx context.Context, client apiextensionsclient.Interface) {
+	for {
+		interval := conversionCheckInterval
+		if err := patchConversion(ctx, client); err != nil {
+			log.Warnf("Failed to configure the conversion webhook of the CRD: %v", err)
+			interval = conversionRetryInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func patchConversion(ctx context.Context, client apiextensionsclient.Interface) error {
+	caBundle, err := os.ReadFile(*caFile)
+	if err != nil {
+		return fmt.Errorf("failed to read the CA: %w", err)
+	}
+	path := "/convert"
+	port := int32(443)
+	conversion := apiextensionsv1.CustomResourceConversion{
+		Strategy: apiextensionsv1.WebhookConverter,
+		Webhook: &apiextensionsv1.WebhookConversion{
+			ConversionReviewVersions: []string{"v1"},
+			ClientConfig: &apiextensionsv1.WebhookClientConfig{
+				Service: &apiextensionsv1.ServiceReference{
+					Name:      *serviceName,
+					Namespace: *serviceNamespace,
+					Path:      &path,
+					Port:      &port,
+				},
+				CABundle: caBundle,
+			},
+		},
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"conversion": conversion},
+	})
+	if err != nil {
+		return err
+	}
+	// the API server doesn't write the CRD if nothing changed
+	_, err = client.ApiextensionsV1().CustomResourceDefinitions().Patch(ctx, crdName, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
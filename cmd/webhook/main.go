@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned"
+	"woehrl01/pod-pacemaker/pkg/generated/informers/externalversions"
+	listers "woehrl01/pod-pacemaker/pkg/generated/listers/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	port             = flag.Int("port", 9443, "The port of the webhook server")
+	tlsCertFile      = flag.String("tls-cert-file", "/etc/webhook/certs/tls.crt", "The TLS certificate of the webhook server")
+	tlsKeyFile       = flag.String("tls-key-file", "/etc/webhook/certs/tls.key", "The TLS key of the webhook server")
+	caFile           = flag.String("ca-file", "/etc/webhook/certs/ca.crt", "The CA which signed the TLS certificate, it is added to the conversion webhook of the CRD")
+	serviceName      = flag.String("service-name", "pod-pacemaker-webhook", "The name of the service of the webhook")
+	serviceNamespace = flag.String("service-namespace", os.Getenv("POD_NAMESPACE"), "The namespace of the service of the webhook, defaults to POD_NAMESPACE")
+	debugLogging     = flag.Bool("debug-logging", false, "Enable debug logging")
+)
+
+func main() {
+	flag.Parse()
+	if *debugLogging {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		log.Fatalf("Failed to get kubernetes config: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	crds := apiextensionsclient.NewForConfigOrDie(config)
+	clientset := versioned.NewForConfigOrDie(config)
+	go ensureConversionWebhook(ctx, crds)
+	go migrateStoredVersions(ctx, crds, clientset)
+
+	// the existing configs are only needed for the warnings, a stale cache doesn't affect the validation.
+	// The server doesn't wait for the sync, listing the configs requires the conversion of this webhook.
+	factory := externalversions.NewSharedInformerFactory(clientset, 0 /*no resync*/)
+	configs := factory.Woehrl().V1beta1().PacemakerConfigs().Lister()
+	factory.Start(ctx.Done())
+
+	certificate, err := newCertificateReloader(*tlsCertFile, *tlsKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load the TLS certificate: %v", err)
+	}
+
+	server := newServer(fmt.Sprintf(":%d", *port), configs, certificate)
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer shutdownCancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("Webhook listening on port %d", *port)
+	if err := server.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Failed to serve the webhook: %v", err)
+	}
+}
+
+// newServer returns the server of the webhook, the existing configs are listed from the lister
+func newServer(addr string, configs listers.PacemakerConfigLister, certificate *certificateReloader) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/validate", &validator{configs: func() []*v1beta1.PacemakerConfig {
+		existing, err := configs.List(labels.Everything())
+		if err != nil {
+			log.Warnf("Failed to list the configs: %v", err)
+		}
+		return existing
+	}})
+	mux.Handle("/convert", &converter{})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certificate.GetCertificate,
+		},
+	}
+}
+
+// certificateReloader reads the certificate again once the mounted secret changed, e.g. after a rotation
+type certificateReloader struct {
+	mu       sync.Mutex
+	certFile string
+	keyFile  string
+	modTime  time.Time
+	current  *tls.Certificate
+}
+
+func newCertificateReloader(certFile string, keyFile string) (*certificateReloader, error) {
+	r := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.certFile)
+	if err != nil {
+		if r.current != nil {
+			return r.current, nil
+		}
+		return nil, err
+	}
+	if r.current != nil && info.ModTime().Equal(r.modTime) {
+		return r.current, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.current != nil {
+			log.Warnf("Failed to reload the TLS certificate, keeping the current one: %v", err)
+			return r.current, nil
+		}
+		return nil, err
+	}
+	r.current = &certificate
+	r.modTime = info.ModTime()
+	return r.current, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned"
+
+	log "github.com/sirupsen/logrus"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// migrateStoredVersions rewrites the configs which are still stored as v1alpha in the storage version v1beta1 and removes
+// v1alpha from the stored versions of the CRD, so reading them doesn't depend on the conversion of this webhook anymore.
+// It is retried until it succeeds or the context is done, the first attempts fail until the webhook serves the conversion.
+func migrateStoredVersions(ctx context.Context, crds apiextensionsclient.Interface, configs versioned.Interface) {
+	for {
+		done, err := migrate(ctx, crds, configs)
+		if err != nil {
+			log.Warnf("Failed to migrate the configs to %s: %v", v1beta1.SchemeGroupVersion.Version, err)
+		}
+		if done {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(conversionRetryInterval):
+		}
+	}
+}
+
+func migrate(ctx context.Context, crds apiextensionsclient.Interface, configs versioned.Interface) (bool, error) {
+	crd, err := crds.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, crdName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get the CRD: %w", err)
+	}
+	storageVersion := v1beta1.SchemeGroupVersion.Version
+	if slices.Equal(crd.Status.StoredVersions, []string{storageVersion}) {
+		return true, nil
+	}
+
+	list, err := configs.WoehrlV1beta1().PacemakerConfigs().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to list the configs: %w", err)
+	}
+	for i := range list.Items {
+		// the API server writes the unchanged config in the storage version, because its encoding differs from the stored one
+		if _, err := configs.WoehrlV1beta1().PacemakerConfigs().Update(ctx, &list.Items[i], metav1.UpdateOptions{}); err != nil {
+			return false, fmt.Errorf("failed to rewrite config %s: %w", list.Items[i].Name, err)
+		}
+	}
+
+	crd.Status.StoredVersions = []string{storageVersion}
+	if _, err := crds.ApiextensionsV1().CustomResourceDefinitions().UpdateStatus(ctx, crd, metav1.UpdateOptions{}); err != nil {
+		return false, fmt.Errorf("failed to update the stored versions of the CRD: %w", err)
+	}
+	log.Infof("Migrated %d configs to %s", len(list.Items), storageVersion)
+	return true, nil
+}
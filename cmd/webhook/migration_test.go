@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned/fake"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name           string
+		storedVersions []string
+		wantUpdates    int
+	}{
+		{name: "configs stored as v1alpha are rewritten", storedVersions: []string{"v1alpha", "v1beta1"}, wantUpdates: 2},
+		{name: "migrated configs aren't written again", storedVersions: []string{"v1beta1"}, wantUpdates: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crds := apiextensionsfake.NewSimpleClientset(&apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: crdName},
+				Status:     apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: tt.storedVersions},
+			})
+			configs := fake.NewSimpleClientset(
+				&v1beta1.PacemakerConfig{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+				&v1beta1.PacemakerConfig{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+			)
+
+			done, err := migrate(context.Background(), crds, configs)
+			if err != nil || !done {
+				t.Fatalf("migrate() = %v, %v, want done", done, err)
+			}
+
+			updates := 0
+			for _, action := range configs.Actions() {
+				if action.GetVerb() == "update" {
+					updates++
+				}
+			}
+			if updates != tt.wantUpdates {
+				t.Errorf("got %d updates of configs, want %d", updates, tt.wantUpdates)
+			}
+			crd, err := crds.ApiextensionsV1().CustomResourceDefinitions().Get(context.Background(), crdName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(crd.Status.StoredVersions, []string{"v1beta1"}) {
+				t.Errorf("got stored versions %v, want [v1beta1]", crd.Status.StoredVersions)
+			}
+		})
+	}
+}
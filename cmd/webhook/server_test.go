@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned"
+	"woehrl01/pod-pacemaker/pkg/generated/informers/externalversions"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// fakeAPIServer serves the configs and the CRD like the API server, the configs are listed or streamed to a watch
+type fakeAPIServer struct {
+	configs []v1beta1.PacemakerConfig
+
+	mu      sync.Mutex
+	patches [][]byte
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/pacemakerconfigs") && r.URL.Query().Get("watch") == "true":
+		s.watch(w, r)
+	case strings.HasSuffix(r.URL.Path, "/pacemakerconfigs"):
+		json.NewEncoder(w).Encode(v1beta1.PacemakerConfigList{
+			TypeMeta: metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "PacemakerConfigList"},
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items:    s.configs,
+		})
+	case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, "/customresourcedefinitions/"+crdName):
+		patch, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.patches = append(s.patches, patch)
+		s.mu.Unlock()
+		json.NewEncoder(w).Encode(apiextensionsv1.CustomResourceDefinition{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"},
+			ObjectMeta: metav1.ObjectMeta{Name: crdName},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// watch streams the configs as initial events if they are requested, and keeps the watch open until the client leaves
+func (s *fakeAPIServer) watch(w http.ResponseWriter, r *http.Request) {
+	encoder := json.NewEncoder(w)
+	if r.URL.Query().Get("sendInitialEvents") == "true" {
+		for i := range s.configs {
+			encoder.Encode(metav1.WatchEvent{Type: "ADDED", Object: runtime.RawExtension{Object: &s.configs[i]}})
+		}
+		encoder.Encode(metav1.WatchEvent{Type: "BOOKMARK", Object: runtime.RawExtension{Object: &v1beta1.PacemakerConfig{
+			TypeMeta: metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "PacemakerConfig"},
+			ObjectMeta: metav1.ObjectMeta{
+				ResourceVersion: "1",
+				Annotations:     map[string]string{metav1.InitialEventsAnnotationKey: "true"},
+			},
+		}}})
+	}
+	w.(http.Flusher).Flush()
+	<-r.Context().Done()
+}
+
+func (s *fakeAPIServer) Patches() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.patches
+}
+
+// writeCertificate writes a self-signed certificate for 127.0.0.1 and returns the files of the certificate and its key
+func writeCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pod-pacemaker-webhook"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// startWebhook runs the webhook server with TLS against the fake API server and returns its URL and a client which trusts it
+func startWebhook(t *testing.T, apiServer *httptest.Server) (string, *http.Client) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	factory := externalversions.NewSharedInformerFactory(versioned.NewForConfigOrDie(&rest.Config{Host: apiServer.URL}), 0)
+	informer := factory.Woehrl().V1beta1().PacemakerConfigs()
+	configs := informer.Lister()
+	factory.Start(ctx.Done())
+	syncCtx, syncCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer syncCancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.Informer().HasSynced) {
+		t.Fatalf("the informer didn't sync with the fake API server")
+	}
+
+	certFile, keyFile := writeCertificate(t)
+	certificate, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newServer(listener.Addr().String(), configs, certificate)
+	go server.ServeTLS(listener, "", "")
+	t.Cleanup(func() { server.Close() })
+
+	pool := x509.NewCertPool()
+	ca, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.AppendCertsFromPEM(ca)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}, Timeout: 10 * time.Second}
+	return "https://" + listener.Addr().String(), client
+}
+
+func post(t *testing.T, client *http.Client, url string, request any, response any) {
+	t.Helper()
+	body, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		content, _ := io.ReadAll(resp.Body)
+		t.Fatalf("POST %s returned %d: %s", url, resp.StatusCode, content)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookServer(t *testing.T) {
+	existing := *selectingConfig("batch", 10, map[string]string{"pool": "batch"})
+	existing.ResourceVersion = "1"
+	apiServer := httptest.NewServer(&fakeAPIServer{configs: []v1beta1.PacemakerConfig{existing}})
+	// registered first so that it runs after the informer stopped watching
+	t.Cleanup(apiServer.Close)
+	url, client := startWebhook(t, apiServer)
+
+	t.Run("validates against the configs of the informer", func(t *testing.T) {
+		raw, err := json.Marshal(selectingConfig("all", 10, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		review := admissionv1.AdmissionReview{}
+		post(t, client, url+"/validate", admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       types.UID("request"),
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			},
+		}, &review)
+		if review.Response == nil || !review.Response.Allowed {
+			t.Fatalf("got response %v, want the config to be allowed", review.Response)
+		}
+		if len(review.Response.Warnings) != 1 || !strings.Contains(review.Response.Warnings[0], "batch has the same priority 10") {
+			t.Errorf("got warnings %q, want the tie with the existing config batch", review.Response.Warnings)
+		}
+	})
+
+	t.Run("converts configs", func(t *testing.T) {
+		raw, err := json.Marshal(selectingConfig("all", 10, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		review := apiextensionsv1.ConversionReview{}
+		post(t, client, url+"/convert", apiextensionsv1.ConversionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "apiextensions.k8s.io/v1", Kind: "ConversionReview"},
+			Request: &apiextensionsv1.ConversionRequest{
+				UID:               types.UID("conversion"),
+				DesiredAPIVersion: v1alphaVersion,
+				Objects:           []runtime.RawExtension{{Raw: raw}},
+			},
+		}, &review)
+		if review.Response == nil || review.Response.Result.Status != metav1.StatusSuccess || len(review.Response.ConvertedObjects) != 1 {
+			t.Fatalf("got response %v, want one converted object", review.Response)
+		}
+		if !strings.Contains(string(review.Response.ConvertedObjects[0].Raw), v1alphaVersion) {
+			t.Errorf("converted object %s isn't a %s", review.Response.ConvertedObjects[0].Raw, v1alphaVersion)
+		}
+	})
+
+	t.Run("serves the health check", func(t *testing.T) {
+		resp, err := client.Get(url + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("got status %d, want 200", resp.StatusCode)
+		}
+	})
+}
+
+func TestPatchConversionRegistersTheWebhook(t *testing.T) {
+	fake := &fakeAPIServer{}
+	apiServer := httptest.NewServer(fake)
+	defer apiServer.Close()
+
+	certFile, _ := writeCertificate(t)
+	previousCA, previousNamespace := *caFile, *serviceNamespace
+	*caFile, *serviceNamespace = certFile, "pod-pacemaker"
+	t.Cleanup(func() { *caFile, *serviceNamespace = previousCA, previousNamespace })
+
+	if err := patchConversion(context.Background(), apiextensionsclient.NewForConfigOrDie(&rest.Config{Host: apiServer.URL})); err != nil {
+		t.Fatal(err)
+	}
+
+	patches := fake.Patches()
+	if len(patches) != 1 {
+		t.Fatalf("got %d patches of the CRD, want 1", len(patches))
+	}
+	var patch struct {
+		Spec struct {
+			Conversion apiextensionsv1.CustomResourceConversion `json:"conversion"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(patches[0], &patch); err != nil {
+		t.Fatal(err)
+	}
+	conversion := patch.Spec.Conversion
+	if conversion.Strategy != apiextensionsv1.WebhookConverter || conversion.Webhook == nil || conversion.Webhook.ClientConfig == nil {
+		t.Fatalf("got conversion %+v, want the webhook", conversion)
+	}
+	service := conversion.Webhook.ClientConfig.Service
+	if service == nil || service.Name != *serviceName || service.Namespace != "pod-pacemaker" || *service.Path != "/convert" {
+		t.Errorf("got service %+v, want /convert of %s in pod-pacemaker", service, *serviceName)
+	}
+	ca, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(conversion.Webhook.ClientConfig.CABundle, ca) {
+		t.Errorf("the CA bundle isn't the CA of the webhook")
+	}
+}
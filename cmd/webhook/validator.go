@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+
+	log "github.com/sirupsen/logrus"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// the admission request of a PacemakerConfig is small, anything larger is rejected
+const maxRequestSize = 1 << 20
+
+// validator rejects PacemakerConfigs with invalid values and warns about configs which compete for the same nodes
+type validator struct {
+	// configs returns the existing configs
+	configs func() []*v1beta1.PacemakerConfig
+}
+
+func (v *validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("invalid admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	response := v.review(review.Request)
+	response.UID = review.Request.UID
+	review.Response = response
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Warnf("Failed to write admission response: %v", err)
+	}
+}
+
+func (v *validator) review(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if request.Operation != admissionv1.Create && request.Operation != admissionv1.Update {
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	config, err := decodeConfig(request.Object.Raw)
+	if err != nil {
+		return deny(fmt.Sprintf("failed to parse the config: %v", err))
+	}
+	if request.Operation == admissionv1.Update && specUnchanged(request.OldObject.Raw, config) {
+		// e.g. the migration of the storage version or a change of the labels, a stored config isn't rejected afterwards
+		return &admissionv1.AdmissionResponse{Allowed: true}
+	}
+
+	if errs := config.Spec.Validate(); len(errs) > 0 {
+		log.Infof("Denied config %s: %v", config.Name, errs.ToAggregate())
+		return deny(errs.ToAggregate().Error())
+	}
+
+	warnings := competingConfigs(config, v.configs())
+	for _, warning := range warnings {
+		log.Infof("Warning for config %s: %s", config.Name, warning)
+	}
+	return &admissionv1.AdmissionResponse{Allowed: true, Warnings: warnings}
+}
+
+// specUnchanged reports if the update keeps the spec of the stored config
+func specUnchanged(oldRaw []byte, config *v1beta1.PacemakerConfig) bool {
+	if len(oldRaw) == 0 {
+		return false
+	}
+	old, err := decodeConfig(oldRaw)
+	if err != nil {
+		return false
+	}
+	return equality.Semantic.DeepEqual(old.Spec, config.Spec)
+}
+
+func deny(message string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Code:    http.StatusUnprocessableEntity,
+			Reason:  metav1.StatusReasonInvalid,
+			Message: message,
+		},
+	}
+}
+
+// competingConfigs warns about configs with the same priority which select the same nodes,
+// and about configs with a higher priority which select every node of the config
+func competingConfigs(config *v1beta1.PacemakerConfig, existing []*v1beta1.PacemakerConfig) []string {
+	sort.Slice(existing, func(i, j int) bool { return existing[i].Name < existing[j].Name })
+
+	selector, err := requirements(config.Spec.NodeSelector)
+	if err != nil {
+		return nil // the selector was rejected by the validation already
+	}
+
+	warnings := []string{}
+	for _, other := range existing {
+		if other.Name == config.Name {
+			continue
+		}
+		otherSelector, err := requirements(other.Spec.NodeSelector)
+		if err != nil {
+			continue // an invalid selector doesn't select any node
+		}
+		switch {
+		case other.Spec.Priority == config.Spec.Priority && selectorsOverlap(selector, otherSelector):
+			winner := min(config.Name, other.Name)
+			warnings = append(warnings, fmt.Sprintf(
+				"%s has the same priority %d and selects some of the same nodes (%s), on these nodes %s is effective because its name sorts first",
+				other.Name, other.Spec.Priority, describeOverlap(selector, otherSelector), winner))
+		case other.Spec.Priority > config.Spec.Priority && !other.Spec.Merge && selectorCovers(otherSelector, selector):
+			warnings = append(warnings, fmt.Sprintf(
+				"%s has the higher priority %d and selects every node this config selects, this config is never effective",
+				other.Name, other.Spec.Priority))
+		}
+	}
+	return warnings
+}
+
+// requirements returns the requirements of the selector, a missing selector selects all nodes
+func requirements(selector *metav1.LabelSelector) (labels.Requirements, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	parsed, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	requirements, _ := parsed.Requirements()
+	return requirements, nil
+}
+
+// selectorsOverlap reports if a node can match both selectors. It is conservative, the selectors only
+// don't overlap if they contradict each other for a label, e.g. by requiring different values.
+func selectorsOverlap(a, b labels.Requirements) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x.Key() == y.Key() && (contradicts(x, y) || contradicts(y, x)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func contradicts(a, b labels.Requirement) bool {
+	allowed, limited := allowedValues(a)
+	switch b.Operator() {
+	case selection.DoesNotExist:
+		return a.Operator() != selection.DoesNotExist && a.Operator() != selection.NotIn && a.Operator() != selection.NotEquals
+	case selection.In, selection.Equals, selection.DoubleEquals:
+		return limited && !allowed.HasAny(b.Values().UnsortedList()...)
+	case selection.NotIn, selection.NotEquals:
+		return limited && b.Values().IsSuperset(allowed)
+	}
+	return false
+}
+
+// allowedValues returns the values a label can have, if the requirement limits them
+func allowedValues(r labels.Requirement) (sets.String, bool) {
+	switch r.Operator() {
+	case selection.In, selection.Equals, selection.DoubleEquals:
+		return r.Values(), true
+	}
+	return nil, false
+}
+
+// selectorCovers reports if every node which matches inner also matches outer. It is conservative,
+// each requirement of outer has to be implied by a requirement of inner on the same label.
+func selectorCovers(outer, inner labels.Requirements) bool {
+	for _, o := range outer {
+		implied := false
+		for _, i := range inner {
+			if i.Key() == o.Key() && implies(i, o) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+func implies(inner, outer labels.Requirement) bool {
+	allowed, limited := allowedValues(inner)
+	switch outer.Operator() {
+	case selection.Exists:
+		return limited || inner.Operator() == selection.Exists
+	case selection.DoesNotExist:
+		return inner.Operator() == selection.DoesNotExist
+	case selection.In, selection.Equals, selection.DoubleEquals:
+		return limited && outer.Values().IsSuperset(allowed)
+	case selection.NotIn, selection.NotEquals:
+		if limited {
+			return !outer.Values().HasAny(allowed.UnsortedList()...)
+		}
+		switch inner.Operator() {
+		case selection.DoesNotExist:
+			return true
+		case selection.NotIn, selection.NotEquals:
+			return inner.Values().IsSuperset(outer.Values())
+		}
+	}
+	return false
+}
+
+// describeOverlap returns the requirements a node needs to match both selectors
+func describeOverlap(a, b labels.Requirements) string {
+	combined := sets.New[string]()
+	for _, r := range append(append(labels.Requirements{}, a...), b...) {
+		combined.Insert(r.String())
+	}
+	if combined.Len() == 0 {
+		return "all nodes"
+	}
+	return strings.Join(sets.List(combined), ",")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+	"woehrl01/pod-pacemaker/pkg/generated/clientset/versioned/fake"
+	"woehrl01/pod-pacemaker/pkg/generated/informers/externalversions"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// newTestValidator returns a validator which lists the existing configs from the cache of an informer, like the webhook does
+func newTestValidator(t *testing.T, existing ...*v1beta1.PacemakerConfig) *validator {
+	t.Helper()
+	informer := externalversions.NewSharedInformerFactory(fake.NewSimpleClientset(), 0).Woehrl().V1beta1().PacemakerConfigs()
+	for _, config := range existing {
+		if err := informer.Informer().GetIndexer().Add(config); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &validator{configs: func() []*v1beta1.PacemakerConfig {
+		list, err := informer.Lister().List(labels.Everything())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}}
+}
+
+func selectingConfig(name string, priority int, selector map[string]string) *v1beta1.PacemakerConfig {
+	config := &v1beta1.PacemakerConfig{
+		TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.SchemeGroupVersion.String(), Kind: "PacemakerConfig"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: v1beta1.PacemakerConfigSpec{
+			Priority:   priority,
+			Throttlers: []v1beta1.Throttler{{MaxConcurrent: &v1beta1.MaxConcurrent{Value: 3}}},
+		},
+	}
+	if selector != nil {
+		config.Spec.NodeSelector = &metav1.LabelSelector{MatchLabels: selector}
+	}
+	return config
+}
+
+// admit posts the config as an admission review to the validator and returns its response
+func admit(t *testing.T, v *validator, operation admissionv1.Operation, config *v1beta1.PacemakerConfig, old *v1beta1.PacemakerConfig) *admissionv1.AdmissionResponse {
+	t.Helper()
+	raw, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldObject := runtime.RawExtension{}
+	if old != nil {
+		if oldObject.Raw, err = json.Marshal(old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("request-" + config.Name),
+			Operation: operation,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: oldObject,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	v.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", recorder.Code, recorder.Body)
+	}
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &review); err != nil {
+		t.Fatal(err)
+	}
+	if review.Response == nil || review.Response.UID != types.UID("request-"+config.Name) {
+		t.Fatalf("got response %v, want the UID of the request", review.Response)
+	}
+	return review.Response
+}
+
+func TestValidator(t *testing.T) {
+	invalid := selectingConfig("invalid", 0, nil)
+	invalid.Spec.Throttlers = []v1beta1.Throttler{{RateLimit: &v1beta1.RateLimit{Burst: 0}}}
+
+	tests := []struct {
+		name      string
+		existing  []*v1beta1.PacemakerConfig
+		operation admissionv1.Operation
+		config    *v1beta1.PacemakerConfig
+		// old is the stored config of an update
+		old       *v1beta1.PacemakerConfig
+		wantAllow bool
+		// wantWarnings are substrings of the expected warnings, in order
+		wantWarnings []string
+	}{
+		{
+			name:      "invalid values are rejected",
+			operation: admissionv1.Create,
+			config:    invalid,
+			wantAllow: false,
+		},
+		{
+			name:         "same priority on overlapping nodes",
+			existing:     []*v1beta1.PacemakerConfig{selectingConfig("batch", 10, map[string]string{"pool": "batch"})},
+			operation:    admissionv1.Create,
+			config:       selectingConfig("all", 10, nil),
+			wantAllow:    true,
+			wantWarnings: []string{"batch has the same priority 10 and selects some of the same nodes (pool=batch), on these nodes all is effective"},
+		},
+		{
+			name:         "fully shadowed by a higher priority",
+			existing:     []*v1beta1.PacemakerConfig{selectingConfig("all", 20, nil)},
+			operation:    admissionv1.Update,
+			config:       selectingConfig("batch", 10, map[string]string{"pool": "batch"}),
+			wantAllow:    true,
+			wantWarnings: []string{"all has the higher priority 20 and selects every node this config selects"},
+		},
+		{
+			name:      "same priority on separate nodes",
+			existing:  []*v1beta1.PacemakerConfig{selectingConfig("web", 10, map[string]string{"pool": "web"})},
+			operation: admissionv1.Create,
+			config:    selectingConfig("batch", 10, map[string]string{"pool": "batch"}),
+			wantAllow: true,
+		},
+		{
+			name:      "higher priority on other nodes",
+			existing:  []*v1beta1.PacemakerConfig{selectingConfig("web", 20, map[string]string{"pool": "web"})},
+			operation: admissionv1.Create,
+			config:    selectingConfig("batch", 10, map[string]string{"pool": "batch"}),
+			wantAllow: true,
+		},
+		{
+			name:      "updates of the config itself aren't compared with its stored version",
+			existing:  []*v1beta1.PacemakerConfig{selectingConfig("batch", 10, nil)},
+			operation: admissionv1.Update,
+			config:    selectingConfig("batch", 10, map[string]string{"pool": "batch"}),
+			wantAllow: true,
+		},
+		{
+			name:      "invalid values are rejected on updates which change the spec",
+			operation: admissionv1.Update,
+			config:    invalid,
+			old:       selectingConfig("invalid", 0, nil),
+			wantAllow: false,
+		},
+		{
+			name:      "updates which keep the spec of a stored config are allowed",
+			operation: admissionv1.Update,
+			config:    invalid,
+			old:       invalid,
+			wantAllow: true,
+		},
+		{
+			name:      "deletions are allowed",
+			operation: admissionv1.Delete,
+			config:    invalid,
+			wantAllow: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := admit(t, newTestValidator(t, tt.existing...), tt.operation, tt.config, tt.old)
+			if response.Allowed != tt.wantAllow {
+				t.Fatalf("Allowed = %v, want %v: %v", response.Allowed, tt.wantAllow, response.Result)
+			}
+			if !response.Allowed && (response.Result == nil || response.Result.Code != http.StatusUnprocessableEntity) {
+				t.Errorf("got result %v, want %d", response.Result, http.StatusUnprocessableEntity)
+			}
+			if len(response.Warnings) != len(tt.wantWarnings) {
+				t.Fatalf("got warnings %q, want %q", response.Warnings, tt.wantWarnings)
+			}
+			for i, want := range tt.wantWarnings {
+				if !strings.Contains(response.Warnings[i], want) {
+					t.Errorf("warning %d is %q, want %q", i, response.Warnings[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatorRejectsMalformedReviews(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	newTestValidator(t).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(`{"kind":"AdmissionReview"}`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("got status %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,130 @@
+package coordinator
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	pb "woehrl01/pod-pacemaker/proto"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+)
+
+// the attributes a budget is partitioned by, every value of the attribute has its own budget
+const (
+	KeyGlobal    = "Global"
+	KeyOwner     = "Owner"
+	KeyNamespace = "Namespace"
+	KeyLabel     = "Label"
+	KeyZone      = "Zone"
+)
+
+// Config is the file of the coordinator, usually mounted from a ConfigMap
+type Config struct {
+	Budgets []Budget `json:"budgets"`
+}
+
+// Budget limits the pods which start at the same time across all nodes
+type Budget struct {
+	Name string `json:"name"`
+	// Key partitions the budget, e.g. Owner gives every controller its own budget, defaults to Global
+	Key string `json:"key,omitempty"`
+	// Label is the label whose value partitions a budget with the key Label
+	Label string `json:"label,omitempty"`
+	// MaxConcurrent is the number of pods which start at the same time per key
+	MaxConcurrent int `json:"maxConcurrent"`
+	// Namespaces restricts the budget to the pods of these namespaces, all if empty
+	Namespaces []string `json:"namespaces,omitempty"`
+	// PodSelector restricts the budget to the pods with matching labels, all if unset
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+
+	selector labels.Selector
+}
+
+// LoadConfig reads and validates the budgets of the file
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("failed to parse budgets %s: %w", path, err)
+	}
+	if errs := config.Validate(); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid budgets %s: %w", path, errs.ToAggregate())
+	}
+	return config, nil
+}
+
+// Validate checks the budgets and compiles their selectors
+func (c *Config) Validate() field.ErrorList {
+	errs := field.ErrorList{}
+	names := map[string]bool{}
+	for i := range c.Budgets {
+		path := field.NewPath("budgets").Index(i)
+		budget := &c.Budgets[i]
+		if budget.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), "a budget needs a name"))
+		} else if names[budget.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), budget.Name))
+		}
+		names[budget.Name] = true
+
+		switch budget.Key {
+		case "":
+			budget.Key = KeyGlobal
+		case KeyGlobal, KeyOwner, KeyNamespace, KeyZone:
+		case KeyLabel:
+			if budget.Label == "" {
+				errs = append(errs, field.Required(path.Child("label"), "the label of the key is required"))
+			}
+		default:
+			errs = append(errs, field.NotSupported(path.Child("key"), budget.Key, []string{KeyGlobal, KeyOwner, KeyNamespace, KeyLabel, KeyZone}))
+		}
+		if budget.MaxConcurrent < 1 {
+			errs = append(errs, field.Invalid(path.Child("maxConcurrent"), budget.MaxConcurrent, "must be at least 1"))
+		}
+
+		budget.selector = labels.Everything()
+		if budget.PodSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(budget.PodSelector)
+			if err != nil {
+				errs = append(errs, field.Invalid(path.Child("podSelector"), budget.PodSelector, err.Error()))
+				continue
+			}
+			budget.selector = selector
+		}
+	}
+	return errs
+}
+
+// key returns the partition of the budget the lease counts against, false if the budget doesn't apply to the lease
+func (b *Budget) key(lease *pb.Lease) (string, bool) {
+	if len(b.Namespaces) > 0 && !slices.Contains(b.Namespaces, lease.Namespace) {
+		return "", false
+	}
+	if !b.selector.Matches(labels.Set(lease.Labels)) {
+		return "", false
+	}
+
+	switch b.Key {
+	case KeyOwner:
+		if lease.Owner == "" {
+			return "", false // pods without a controller don't share a budget
+		}
+		return lease.Namespace + "/" + lease.Owner, true
+	case KeyNamespace:
+		return lease.Namespace, true
+	case KeyLabel:
+		value, ok := lease.Labels[b.Label]
+		return value, ok
+	case KeyZone:
+		return lease.Zone, lease.Zone != ""
+	default:
+		return "", true
+	}
+}
@@ -0,0 +1,298 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	pb "woehrl01/pod-pacemaker/proto"
+
+	"github.com/sirupsen/logrus"
+)
+
+// budgetKey is a partition of a budget, e.g. the budget of a single namespace
+type budgetKey struct {
+	budget string
+	key    string
+}
+
+func (k budgetKey) String() string {
+	if k.key == "" {
+		return k.budget
+	}
+	return k.budget + "/" + k.key
+}
+
+// leaseId identifies a lease, the same slot name can be reused by a pod on another node
+type leaseId struct {
+	node string
+	slot string
+}
+
+type lease struct {
+	keys      []budgetKey
+	grantedAt time.Time
+	renewedAt time.Time
+}
+
+// Usage is the state of a partition of a budget
+type Usage struct {
+	Budget  string `json:"budget"`
+	Key     string `json:"key,omitempty"`
+	Active  int    `json:"active"`
+	Limit   int    `json:"limit"`
+	Waiters int    `json:"waiters"`
+}
+
+// ErrNotLeader is returned to the waiters when the leadership of the coordinator ends
+var ErrNotLeader = errors.New("the coordinator isn't the leader anymore")
+
+// Coordinator grants the leases of the pods which start on all nodes within the budgets.
+// The state is only kept in memory, a new coordinator learns the existing leases from the renewals of the daemons.
+type Coordinator struct {
+	budgets []Budget
+	ttl     time.Duration
+	// leader is done when the leadership ends, the leases of this coordinator aren't valid anymore
+	leader context.Context
+
+	mu      sync.Mutex
+	leases  map[leaseId]*lease
+	active  map[budgetKey]int
+	waiters map[budgetKey]int
+	// changed is closed and replaced whenever a lease is released
+	changed chan struct{}
+	// recoverUntil delays new leases until the daemons renewed the leases they hold
+	recoverUntil time.Time
+}
+
+// New returns a coordinator whose leases expire if they aren't renewed within the ttl.
+// No lease is granted during the recovery period, so the leases of a previous coordinator are known first.
+// The waiters are aborted with ErrNotLeader when the leader context is done.
+func New(leader context.Context, config *Config, ttl time.Duration, recovery time.Duration) *Coordinator {
+	return &Coordinator{
+		budgets:      config.Budgets,
+		ttl:          ttl,
+		leader:       leader,
+		leases:       map[leaseId]*lease{},
+		active:       map[budgetKey]int{},
+		waiters:      map[budgetKey]int{},
+		changed:      make(chan struct{}),
+		recoverUntil: time.Now().Add(recovery),
+	}
+}
+
+// keys returns the partitions of all budgets the lease counts against
+func (c *Coordinator) keys(l *pb.Lease) []budgetKey {
+	keys := []budgetKey{}
+	for i := range c.budgets {
+		if key, ok := c.budgets[i].key(l); ok {
+			keys = append(keys, budgetKey{budget: c.budgets[i].Name, key: key})
+		}
+	}
+	return keys
+}
+
+// Acquire blocks until all budgets of the lease have room and returns the budgets it counts against
+func (c *Coordinator) Acquire(ctx context.Context, l *pb.Lease) ([]string, error) {
+	if wait := time.Until(c.recoverUntil); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-c.leader.Done():
+			return nil, ErrNotLeader
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	id := leaseId{node: l.Node, slot: l.SlotName}
+	keys := c.keys(l)
+	waiting := false
+	defer func() {
+		if waiting {
+			c.mu.Lock()
+			c.removeWaiter(keys)
+			c.mu.Unlock()
+		}
+	}()
+
+	for {
+		c.mu.Lock()
+		if existing, ok := c.leases[id]; ok {
+			// a retry of the daemon, e.g. after a timeout of the CNI plugin
+			existing.renewedAt = time.Now()
+			c.mu.Unlock()
+			return budgetNames(existing.keys), nil
+		}
+		if c.fits(keys) {
+			c.add(id, keys)
+			c.mu.Unlock()
+			return budgetNames(keys), nil
+		}
+		if !waiting {
+			waiting = true
+			for _, key := range keys {
+				c.waiters[key]++
+			}
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-c.leader.Done():
+			return nil, ErrNotLeader
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Release releases the lease of the slot on the node, false if it isn't known
+func (c *Coordinator) Release(node string, slot string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.release(leaseId{node: node, slot: slot})
+}
+
+// Renew extends the leases of the node and releases the leases which the node doesn't hold anymore.
+// Unknown leases are adopted even beyond the budgets, the pods are starting already.
+// Recent leases are kept even if they are missing, the renewal may have been sent before they were granted.
+func (c *Coordinator) Renew(node string, leases []*pb.Lease) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	grantedBefore := time.Now().Add(-c.ttl / 2)
+
+	held := map[leaseId]bool{}
+	for _, l := range leases {
+		id := leaseId{node: node, slot: l.SlotName}
+		held[id] = true
+		if existing, ok := c.leases[id]; ok {
+			existing.renewedAt = time.Now()
+			continue
+		}
+		logrus.WithField("node", node).WithField("slot", l.SlotName).Debug("Adopting lease")
+		c.add(id, c.keys(l))
+	}
+	for id := range c.leases {
+		if id.node == node && !held[id] && c.leases[id].grantedAt.Before(grantedBefore) {
+			c.release(id)
+		}
+	}
+}
+
+// Run releases the leases which weren't renewed within the ttl, e.g. of a node which is gone, until the context is done
+func (c *Coordinator) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.expire()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Coordinator) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deadline := time.Now().Add(-c.ttl)
+	for id, l := range c.leases {
+		if l.renewedAt.Before(deadline) {
+			logrus.WithField("node", id.node).WithField("slot", id.slot).Info("Lease expired")
+			c.release(id)
+		}
+	}
+}
+
+// Usage returns the partitions of the budgets which are in use or waited for, ordered by budget and key
+func (c *Coordinator) Usage() []Usage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	keys := map[budgetKey]bool{}
+	for key := range c.active {
+		keys[key] = true
+	}
+	for key := range c.waiters {
+		keys[key] = true
+	}
+	usage := make([]Usage, 0, len(keys))
+	for key := range keys {
+		usage = append(usage, Usage{
+			Budget:  key.budget,
+			Key:     key.key,
+			Active:  c.active[key],
+			Limit:   c.limit(key.budget),
+			Waiters: c.waiters[key],
+		})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Budget != usage[j].Budget {
+			return usage[i].Budget < usage[j].Budget
+		}
+		return usage[i].Key < usage[j].Key
+	})
+	return usage
+}
+
+func (c *Coordinator) fits(keys []budgetKey) bool {
+	for _, key := range keys {
+		if c.active[key] >= c.limit(key.budget) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Coordinator) limit(budget string) int {
+	i := slices.IndexFunc(c.budgets, func(b Budget) bool { return b.Name == budget })
+	if i < 0 {
+		return 0
+	}
+	return c.budgets[i].MaxConcurrent
+}
+
+func (c *Coordinator) add(id leaseId, keys []budgetKey) {
+	now := time.Now()
+	c.leases[id] = &lease{keys: keys, grantedAt: now, renewedAt: now}
+	for _, key := range keys {
+		c.active[key]++
+	}
+}
+
+func (c *Coordinator) release(id leaseId) bool {
+	l, ok := c.leases[id]
+	if !ok {
+		return false
+	}
+	delete(c.leases, id)
+	for _, key := range l.keys {
+		if c.active[key]--; c.active[key] <= 0 {
+			delete(c.active, key)
+		}
+	}
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return true
+}
+
+func (c *Coordinator) removeWaiter(keys []budgetKey) {
+	for _, key := range keys {
+		if c.waiters[key]--; c.waiters[key] <= 0 {
+			delete(c.waiters, key)
+		}
+	}
+}
+
+func budgetNames(keys []budgetKey) []string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, key.String())
+	}
+	return names
+}
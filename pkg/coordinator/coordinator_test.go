@@ -0,0 +1,61 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "woehrl01/pod-pacemaker/proto"
+)
+
+func newTestCoordinator(t *testing.T, leader context.Context, maxConcurrent int) *Coordinator {
+	t.Helper()
+	config := &Config{Budgets: []Budget{{Name: "global", MaxConcurrent: maxConcurrent}}}
+	if errs := config.Validate(); len(errs) > 0 {
+		t.Fatal(errs.ToAggregate())
+	}
+	return New(leader, config, time.Minute, 0)
+}
+
+func TestAcquireAbortsWaitersWhenTheLeadershipEnds(t *testing.T) {
+	leader, stepDown := context.WithCancel(context.Background())
+	defer stepDown()
+	c := newTestCoordinator(t, leader, 1)
+	if _, err := c.Acquire(context.Background(), &pb.Lease{Node: "node", SlotName: "default/first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.Acquire(context.Background(), &pb.Lease{Node: "node", SlotName: "default/second"})
+		result <- err
+	}()
+	for len(c.Usage()) == 0 || c.Usage()[0].Waiters == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	stepDown()
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrNotLeader) {
+			t.Errorf("Acquire() = %v, want %v", err, ErrNotLeader)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the waiter wasn't aborted after the leadership ended")
+	}
+	if got := c.Usage()[0].Waiters; got != 0 {
+		t.Errorf("got %d waiters, want 0", got)
+	}
+}
+
+func TestAcquireDuringRecoveryFailsWithoutLeadership(t *testing.T) {
+	leader, stepDown := context.WithCancel(context.Background())
+	stepDown()
+	config := &Config{Budgets: []Budget{{Name: "global", MaxConcurrent: 1}}}
+	c := New(leader, config, time.Minute, time.Hour)
+
+	if _, err := c.Acquire(context.Background(), &pb.Lease{Node: "node", SlotName: "default/web"}); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("Acquire() = %v, want %v", err, ErrNotLeader)
+	}
+}
@@ -0,0 +1,137 @@
+package expression
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ConditionExpression is a compiled CEL expression which decides if a pod may start,
+// e.g. `active < 2 || (loadAvg < 4.0 && psi["memory"] < 10.0)`
+type ConditionExpression struct {
+	source    string
+	program   cel.Program
+	variables map[string]bool
+}
+
+// ConditionVariables are the values the expression is evaluated with
+type ConditionVariables struct {
+	// Active is the number of slots which are currently held in the throttler
+	Active int
+	Cpus   int
+	// Cpu and IO are the load in percent, LoadAvg is the 1 minute load average of the node
+	Cpu     float64
+	IO      float64
+	LoadAvg float64
+	// Psi is the share of time in percent in which some tasks stalled on the resource (cpu, memory, io) in the last 10 seconds
+	Psi map[string]float64
+	Now time.Time
+	// Pod is the pod which requests the slot, it may be nil
+	Pod *v1.Pod
+}
+
+var conditionEnvironment = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("active", cel.IntType),
+		cel.Variable("cpus", cel.IntType),
+		cel.Variable("cpu", cel.DoubleType),
+		cel.Variable("io", cel.DoubleType),
+		cel.Variable("loadAvg", cel.DoubleType),
+		cel.Variable("psi", cel.MapType(cel.StringType, cel.DoubleType)),
+		cel.Variable("now", cel.TimestampType),
+		cel.Variable("pod", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("requests", cel.MapType(cel.StringType, cel.DoubleType)),
+		cel.Variable("priority", cel.IntType),
+	)
+})
+
+// CompileConditionExpression parses and type-checks the expression
+func CompileConditionExpression(source string) (*ConditionExpression, error) {
+	env, err := conditionEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	ast, program, err := compileBool(env, source)
+	if err != nil {
+		return nil, err
+	}
+	variables := map[string]bool{}
+	for _, reference := range ast.NativeRep().ReferenceMap() {
+		if len(reference.OverloadIDs) == 0 {
+			variables[reference.Name] = true
+		}
+	}
+	return &ConditionExpression{source: source, program: program, variables: variables}, nil
+}
+
+// Uses reports if the expression references the variable, so e.g. the cpu load is only measured if it's needed
+func (e *ConditionExpression) Uses(variable string) bool {
+	return e.variables[variable]
+}
+
+// Evaluate returns the result of the expression, the requests of the pod are available
+// in cores and bytes, e.g. `requests["cpu"]` and `requests["memory"]`
+func (e *ConditionExpression) Evaluate(variables ConditionVariables) (bool, error) {
+	pod := map[string]any{}
+	requests := map[string]float64{}
+	priority := int32(0)
+	if variables.Pod != nil {
+		if e.Uses("pod") {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(variables.Pod)
+			if err != nil {
+				return false, err
+			}
+			pod = content
+		}
+		for name, quantity := range podRequests(variables.Pod) {
+			requests[string(name)] = quantity.AsApproximateFloat64()
+		}
+		if variables.Pod.Spec.Priority != nil {
+			priority = *variables.Pod.Spec.Priority
+		}
+	}
+	psi := variables.Psi
+	if psi == nil {
+		psi = map[string]float64{}
+	}
+	return evalBool(e.program, map[string]any{
+		"active":   variables.Active,
+		"cpus":     variables.Cpus,
+		"cpu":      variables.Cpu,
+		"io":       variables.IO,
+		"loadAvg":  variables.LoadAvg,
+		"psi":      psi,
+		"now":      variables.Now,
+		"pod":      pod,
+		"requests": requests,
+		"priority": priority,
+	})
+}
+
+func (e *ConditionExpression) String() string {
+	return e.source
+}
+
+// podRequests returns the resources the pod requests at most at the same time,
+// the containers run together while the init containers run one after another
+func podRequests(pod *v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		for name, quantity := range container.Resources.Requests {
+			sum := requests[name]
+			sum.Add(quantity)
+			requests[name] = sum
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
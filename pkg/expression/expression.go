@@ -0,0 +1,78 @@
+package expression
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// costLimit bounds the evaluation of an expression, e.g. a comprehension over all containers of a pod is far below it
+const costLimit = 100000
+
+// PodExpression is a compiled CEL expression on the pod which returns a bool,
+// e.g. `pod.metadata.labels["runtime"] == "java"`
+type PodExpression struct {
+	source  string
+	program cel.Program
+}
+
+var podEnvironment = sync.OnceValues(func() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable("pod", cel.MapType(cel.StringType, cel.DynType)))
+})
+
+// CompilePodExpression parses and type-checks the expression
+func CompilePodExpression(source string) (*PodExpression, error) {
+	env, err := podEnvironment()
+	if err != nil {
+		return nil, err
+	}
+	_, program, err := compileBool(env, source)
+	if err != nil {
+		return nil, err
+	}
+	return &PodExpression{source: source, program: program}, nil
+}
+
+// compileBool compiles an expression which has to return a bool, its evaluation is bounded by the cost limit
+func compileBool(env *cel.Env, source string) (*cel.Ast, cel.Program, error) {
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, nil, issues.Err()
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, nil, fmt.Errorf("must return a bool, not %s", ast.OutputType())
+	}
+	program, err := env.Program(ast, cel.CostLimit(costLimit))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ast, program, nil
+}
+
+// Matches evaluates the expression, the pod is available as `pod` in its JSON format
+func (e *PodExpression) Matches(pod *v1.Pod) (bool, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+	if err != nil {
+		return false, err
+	}
+	return evalBool(e.program, map[string]any{"pod": content})
+}
+
+func evalBool(program cel.Program, variables map[string]any) (bool, error) {
+	result, _, err := program.Eval(variables)
+	if err != nil {
+		return false, err
+	}
+	value, ok := result.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("returned %v instead of a bool", result.Value())
+	}
+	return value, nil
+}
+
+func (e *PodExpression) String() string {
+	return e.source
+}
@@ -0,0 +1,203 @@
+package fallback
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	lockFileSuffix = ".lock"
+	dirLockName    = ".semaphore"
+)
+
+// Lock is the content of a lock file. It either represents a slot acquired without the daemon,
+// or a plugin process which waits for such a slot.
+type Lock struct {
+	Slot  string    `json:"slot"`
+	PID   int       `json:"pid"`
+	Since time.Time `json:"since"`
+	Held  bool      `json:"held"`
+}
+
+// Semaphore is a crude file based semaphore, used by the CNI plugin if the daemon is unreachable
+type Semaphore struct {
+	dir           string
+	maxConcurrent int
+	holdTimeout   time.Duration
+}
+
+// Dir returns the directory of the lock files, which lives next to the daemon socket
+func Dir(daemonSocketPath string) string {
+	return filepath.Join(filepath.Dir(daemonSocketPath), "fallback")
+}
+
+func NewSemaphore(dir string, maxConcurrent int, holdTimeout time.Duration) *Semaphore {
+	return &Semaphore{
+		dir:           dir,
+		maxConcurrent: maxConcurrent,
+		holdTimeout:   holdTimeout,
+	}
+}
+
+// TryAcquire takes a slot if less than maxConcurrent slots are held and no other process waits longer.
+// Otherwise the caller is registered as waiter, so it keeps its position for the next attempt.
+func (s *Semaphore) TryAcquire(slot string) (bool, error) {
+	unlock, err := s.lockDir()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	locks, err := s.readAll()
+	if err != nil {
+		return false, err
+	}
+	locks = s.removeStale(locks)
+
+	held := 0
+	waitingSince := time.Now()
+	var olderWaiters int
+	for _, lock := range locks {
+		if lock.Slot == slot {
+			if lock.Held {
+				return true, nil // already acquired by a previous attempt
+			}
+			waitingSince = lock.Since
+		}
+	}
+	for _, lock := range locks {
+		if lock.Held {
+			held++
+		} else if lock.Slot != slot && lock.Since.Before(waitingSince) {
+			olderWaiters++
+		}
+	}
+
+	lock := Lock{
+		Slot:  slot,
+		PID:   os.Getpid(),
+		Since: waitingSince,
+	}
+	if held < s.maxConcurrent && olderWaiters == 0 {
+		lock.Held = true
+		lock.Since = time.Now()
+	}
+	return lock.Held, s.write(lock)
+}
+
+// Release removes the lock of the slot, if any
+func (s *Semaphore) Release(slot string) error {
+	err := os.Remove(s.path(slot))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns all held slots
+func (s *Semaphore) List() ([]Lock, error) {
+	locks, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	held := []Lock{}
+	for _, lock := range locks {
+		if lock.Held {
+			held = append(held, lock)
+		}
+	}
+	return held, nil
+}
+
+func (s *Semaphore) isExpired(lock Lock) bool {
+	return time.Since(lock.Since) > s.holdTimeout
+}
+
+// removeStale deletes held slots which outlived the hold timeout, and waiters whose process doesn't
+// exist anymore, e.g. because it was killed by the kubelet. PIDs are only meaningful in the host
+// PID namespace, so this must only be called by the plugin.
+func (s *Semaphore) removeStale(locks []Lock) []Lock {
+	valid := []Lock{}
+	for _, lock := range locks {
+		stale := s.isExpired(lock)
+		if !lock.Held {
+			stale = lock.PID <= 0 || !processExists(lock.PID)
+		}
+		if stale {
+			_ = os.Remove(s.path(lock.Slot))
+			continue
+		}
+		valid = append(valid, lock)
+	}
+	return valid
+}
+
+func (s *Semaphore) readAll() ([]Lock, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return []Lock{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	locks := []Lock{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), lockFileSuffix) {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue // removed in the meantime
+		}
+		var lock Lock
+		if err := json.Unmarshal(content, &lock); err != nil {
+			_ = os.Remove(path) // corrupt, e.g. the writer was killed while writing
+			continue
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+func (s *Semaphore) write(lock Lock) error {
+	content, err := json.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(lock.Slot), content, 0644)
+}
+
+func (s *Semaphore) path(slot string) string {
+	// namespaces can't contain underscores, so this is unique
+	return filepath.Join(s.dir, strings.ReplaceAll(slot, "/", "_")+lockFileSuffix)
+}
+
+// lockDir serializes access to the lock files across concurrently running plugin processes
+func (s *Semaphore) lockDir() (func(), error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, dirLockName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock %s: %v", s.dir, err)
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+func processExists(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
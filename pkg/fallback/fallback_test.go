@@ -0,0 +1,147 @@
+package fallback
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTryAcquire(t *testing.T) {
+	s := NewSemaphore(t.TempDir(), 1, time.Minute)
+
+	acquire := func(slot string, want bool) {
+		t.Helper()
+		acquired, err := s.TryAcquire(slot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if acquired != want {
+			t.Errorf("TryAcquire(%s) = %v, want %v", slot, acquired, want)
+		}
+	}
+
+	acquire("default/first", true)
+	acquire("default/first", true) // a retry of the same sandbox keeps its slot
+	acquire("default/second", false)
+	time.Sleep(time.Millisecond) // the waiters are ordered by the time they registered
+	acquire("default/third", false)
+
+	if err := s.Release("default/first"); err != nil {
+		t.Fatal(err)
+	}
+	// the second pod waits longer than the third one, so it gets the free slot first
+	acquire("default/third", false)
+	acquire("default/second", true)
+
+	locks, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 1 || locks[0].Slot != "default/second" {
+		t.Errorf("List() = %v, want only the held slot default/second", locks)
+	}
+}
+
+func TestTryAcquireRemovesStaleLocks(t *testing.T) {
+	exited := exec.Command("true")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		lock      Lock
+		wantStale bool
+	}{
+		{name: "held slot within the hold timeout", lock: Lock{Slot: "default/held", Held: true, Since: time.Now()}},
+		{name: "held slot after the hold timeout", lock: Lock{Slot: "default/held", Held: true, Since: time.Now().Add(-2 * time.Minute)}, wantStale: true},
+		{name: "waiter of a running process", lock: Lock{Slot: "default/waiter", PID: os.Getpid(), Since: time.Now().Add(-time.Hour)}},
+		{name: "waiter of an exited process", lock: Lock{Slot: "default/waiter", PID: exited.Process.Pid, Since: time.Now().Add(-time.Hour)}, wantStale: true},
+		{name: "waiter without a process", lock: Lock{Slot: "default/waiter", Since: time.Now().Add(-time.Hour)}, wantStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSemaphore(t.TempDir(), 1, time.Minute)
+			if err := os.MkdirAll(s.dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.write(tt.lock); err != nil {
+				t.Fatal(err)
+			}
+
+			acquired, err := s.TryAcquire("default/new")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if acquired != tt.wantStale {
+				t.Errorf("TryAcquire() = %v, want %v", acquired, tt.wantStale)
+			}
+			_, err = os.Stat(s.path(tt.lock.Slot))
+			if removed := os.IsNotExist(err); removed != tt.wantStale {
+				t.Errorf("lock file removed = %v, want %v", removed, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestTryAcquireIsSerializedByTheDirectoryLock(t *testing.T) {
+	const maxConcurrent = 3
+	s := NewSemaphore(t.TempDir(), maxConcurrent, time.Minute)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	acquired := 0
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// every plugin process opens the directory lock on its own
+			ok, err := NewSemaphore(s.dir, maxConcurrent, time.Minute).TryAcquire(fmt.Sprintf("default/pod-%d", i))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				acquired++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired != maxConcurrent {
+		t.Errorf("%d slots were acquired, want %d", acquired, maxConcurrent)
+	}
+	locks, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != maxConcurrent {
+		t.Errorf("List() returned %d held slots, want %d", len(locks), maxConcurrent)
+	}
+}
+
+func TestReadAllRemovesCorruptLocks(t *testing.T) {
+	s := NewSemaphore(t.TempDir(), 1, time.Minute)
+	corrupt := filepath.Join(s.dir, "default_web"+lockFileSuffix)
+	if err := os.WriteFile(corrupt, []byte(`{"slot":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	locks, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 0 {
+		t.Errorf("List() = %v, want none", locks)
+	}
+	if _, err := os.Stat(corrupt); !os.IsNotExist(err) {
+		t.Errorf("corrupt lock file wasn't removed")
+	}
+}
@@ -0,0 +1,106 @@
+package flightrecorder
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// kinds of entries
+const (
+	KindPodEvent     = "pod_event"
+	KindWaitRequest  = "wait_request"
+	KindDecision     = "decision"
+	KindRelease      = "release"
+	KindLoadSample   = "load_sample"
+	KindConfigChange = "config_change"
+)
+
+const DefaultSize = 10000
+
+type Entry struct {
+	Time    time.Time      `json:"time"`
+	Kind    string         `json:"kind"`
+	Slot    string         `json:"slot,omitempty"`
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// Recorder keeps the latest entries in a ring buffer, the oldest entries are overwritten
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+func New(size int) *Recorder {
+	return &Recorder{entries: make([]Entry, max(size, 1))}
+}
+
+// Default is the recorder of the process, used by the package level functions
+var Default = New(DefaultSize)
+
+func Record(kind string, slot string, message string, fields map[string]any) {
+	Default.Record(kind, slot, message, fields)
+}
+
+func (r *Recorder) Record(kind string, slot string, message string, fields map[string]any) {
+	entry := Entry{
+		Time:    time.Now(),
+		Kind:    kind,
+		Slot:    slot,
+		Message: message,
+		Fields:  fields,
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries returns the recorded entries, oldest first
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.snapshot()
+}
+
+// snapshot copies the entries, oldest first. This needs be called with the lock held.
+func (r *Recorder) snapshot() []Entry {
+	if !r.full {
+		return append([]Entry{}, r.entries[:r.next]...)
+	}
+	return append(append([]Entry{}, r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// Resize changes the capacity, the latest entries are kept
+func (r *Recorder) Resize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.snapshot()
+	size = max(size, 1)
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+	r.entries = make([]Entry, size)
+	copy(r.entries, entries)
+	r.next = len(entries) % size
+	r.full = len(entries) == size
+}
+
+// WriteJSONLines writes one JSON object per entry, oldest first
+func (r *Recorder) WriteJSONLines(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range r.Entries() {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package flightrecorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// record records the entries with the messages 1 to count
+func record(r *Recorder, count int) {
+	for i := 1; i <= count; i++ {
+		r.Record(KindDecision, "default/web", fmt.Sprint(i), nil)
+	}
+}
+
+func messages(entries []Entry) []string {
+	result := []string{}
+	for _, entry := range entries {
+		result = append(result, entry.Message)
+	}
+	return result
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		recorded int
+		// resize is applied after recording, if set
+		resize int
+		want   []string
+	}{
+		{name: "empty", size: 3, want: []string{}},
+		{name: "partially filled", size: 3, recorded: 2, want: []string{"1", "2"}},
+		{name: "exactly full", size: 3, recorded: 3, want: []string{"1", "2", "3"}},
+		{name: "wraps around", size: 3, recorded: 7, want: []string{"5", "6", "7"}},
+		{name: "shrink keeps the latest", size: 5, recorded: 7, resize: 2, want: []string{"6", "7"}},
+		{name: "grow keeps all", size: 3, recorded: 4, resize: 5, want: []string{"2", "3", "4"}},
+		{name: "resize to the same size", size: 3, recorded: 4, resize: 3, want: []string{"2", "3", "4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.size)
+			record(r, tt.recorded)
+			if tt.resize > 0 {
+				r.Resize(tt.resize)
+			}
+			if got := messages(r.Entries()); !slices.Equal(got, tt.want) {
+				t.Errorf("Entries() = %v, want %v", got, tt.want)
+			}
+
+			var buf bytes.Buffer
+			if err := r.WriteJSONLines(&buf); err != nil {
+				t.Fatal(err)
+			}
+			written := []string{}
+			decoder := json.NewDecoder(&buf)
+			for decoder.More() {
+				var entry Entry
+				if err := decoder.Decode(&entry); err != nil {
+					t.Fatal(err)
+				}
+				written = append(written, entry.Message)
+			}
+			if !slices.Equal(written, tt.want) {
+				t.Errorf("WriteJSONLines() wrote %v, want %v", written, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecorderWrapsAfterResize(t *testing.T) {
+	r := New(5)
+	record(r, 3)
+	r.Resize(2)
+	r.Record(KindDecision, "default/web", "4", nil)
+	if got, want := messages(r.Entries()), []string{"3", "4"}; !slices.Equal(got, want) {
+		t.Errorf("Entries() = %v, want %v", got, want)
+	}
+}
+
+func TestResizeKeepsConcurrentEntries(t *testing.T) {
+	// large enough for all entries, so none is overwritten
+	r := New(20000)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		record(r, 10000)
+	}()
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			if got := len(r.Entries()); got != 10000 {
+				t.Errorf("got %d entries, want all 10000", got)
+			}
+			return
+		default:
+			r.Resize(20000 + i%2)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1beta1 "woehrl01/pod-pacemaker/pkg/generated/clientset/versioned/typed/api/v1beta1"
+
+	rest "k8s.io/client-go/rest"
+	testing "k8s.io/client-go/testing"
+)
+
+type FakeWoehrlV1beta1 struct {
+	*testing.Fake
+}
+
+func (c *FakeWoehrlV1beta1) PacemakerConfigs() v1beta1.PacemakerConfigInterface {
+	return newFakePacemakerConfigs(c)
+}
+
+// RESTClient returns a RESTClient that is used to communicate
+// with API server by this client implementation.
+func (c *FakeWoehrlV1beta1) RESTClient() rest.Interface {
+	var ret *rest.RESTClient
+	return ret
+}
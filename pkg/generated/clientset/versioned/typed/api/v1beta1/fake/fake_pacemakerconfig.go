@@ -0,0 +1,37 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1beta1 "woehrl01/pod-pacemaker/api/v1beta1"
+	apiv1beta1 "woehrl01/pod-pacemaker/pkg/generated/clientset/versioned/typed/api/v1beta1"
+
+	gentype "k8s.io/client-go/gentype"
+)
+
+// fakePacemakerConfigs implements PacemakerConfigInterface
+type fakePacemakerConfigs struct {
+	*gentype.FakeClientWithList[*v1beta1.PacemakerConfig, *v1beta1.PacemakerConfigList]
+	Fake *FakeWoehrlV1beta1
+}
+
+func newFakePacemakerConfigs(fake *FakeWoehrlV1beta1) apiv1beta1.PacemakerConfigInterface {
+	return &fakePacemakerConfigs{
+		gentype.NewFakeClientWithList[*v1beta1.PacemakerConfig, *v1beta1.PacemakerConfigList](
+			fake.Fake,
+			"",
+			v1beta1.SchemeGroupVersion.WithResource("pacemakerconfigs"),
+			v1beta1.SchemeGroupVersion.WithKind("PacemakerConfig"),
+			func() *v1beta1.PacemakerConfig { return &v1beta1.PacemakerConfig{} },
+			func() *v1beta1.PacemakerConfigList { return &v1beta1.PacemakerConfigList{} },
+			func(dst, src *v1beta1.PacemakerConfigList) { dst.ListMeta = src.ListMeta },
+			func(list *v1beta1.PacemakerConfigList) []*v1beta1.PacemakerConfig {
+				return gentype.ToPointerSlice(list.Items)
+			},
+			func(list *v1beta1.PacemakerConfigList, items []*v1beta1.PacemakerConfig) {
+				list.Items = gentype.FromPointerSlice(items)
+			},
+		),
+		fake,
+	}
+}
@@ -0,0 +1,54 @@
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	context "context"
+	apiv1beta1 "woehrl01/pod-pacemaker/api/v1beta1"
+	scheme "woehrl01/pod-pacemaker/pkg/generated/clientset/versioned/scheme"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	gentype "k8s.io/client-go/gentype"
+)
+
+// PacemakerConfigsGetter has a method to return a PacemakerConfigInterface.
+// A group's client should implement this interface.
+type PacemakerConfigsGetter interface {
+	PacemakerConfigs() PacemakerConfigInterface
+}
+
+// PacemakerConfigInterface has methods to work with PacemakerConfig resources.
+type PacemakerConfigInterface interface {
+	Create(ctx context.Context, pacemakerConfig *apiv1beta1.PacemakerConfig, opts v1.CreateOptions) (*apiv1beta1.PacemakerConfig, error)
+	Update(ctx context.Context, pacemakerConfig *apiv1beta1.PacemakerConfig, opts v1.UpdateOptions) (*apiv1beta1.PacemakerConfig, error)
+	// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+	UpdateStatus(ctx context.Context, pacemakerConfig *apiv1beta1.PacemakerConfig, opts v1.UpdateOptions) (*apiv1beta1.PacemakerConfig, error)
+	Delete(ctx context.Context, name string, opts v1.DeleteOptions) error
+	DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error
+	Get(ctx context.Context, name string, opts v1.GetOptions) (*apiv1beta1.PacemakerConfig, error)
+	List(ctx context.Context, opts v1.ListOptions) (*apiv1beta1.PacemakerConfigList, error)
+	Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *apiv1beta1.PacemakerConfig, err error)
+	PacemakerConfigExpansion
+}
+
+// pacemakerConfigs implements PacemakerConfigInterface
+type pacemakerConfigs struct {
+	*gentype.ClientWithList[*apiv1beta1.PacemakerConfig, *apiv1beta1.PacemakerConfigList]
+}
+
+// newPacemakerConfigs returns a PacemakerConfigs
+func newPacemakerConfigs(c *WoehrlV1beta1Client) *pacemakerConfigs {
+	return &pacemakerConfigs{
+		gentype.NewClientWithList[*apiv1beta1.PacemakerConfig, *apiv1beta1.PacemakerConfigList](
+			"pacemakerconfigs",
+			c.RESTClient(),
+			scheme.ParameterCodec,
+			"",
+			func() *apiv1beta1.PacemakerConfig { return &apiv1beta1.PacemakerConfig{} },
+			func() *apiv1beta1.PacemakerConfigList { return &apiv1beta1.PacemakerConfigList{} },
+		),
+	}
+}
@@ -0,0 +1,29 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	internalinterfaces "woehrl01/pod-pacemaker/pkg/generated/informers/externalversions/internalinterfaces"
+)
+
+// Interface provides access to all the informers in this group version.
+type Interface interface {
+	// PacemakerConfigs returns a PacemakerConfigInformer.
+	PacemakerConfigs() PacemakerConfigInformer
+}
+
+type version struct {
+	factory          internalinterfaces.SharedInformerFactory
+	namespace        string
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+}
+
+// New returns a new Interface.
+func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakListOptions internalinterfaces.TweakListOptionsFunc) Interface {
+	return &version{factory: f, namespace: namespace, tweakListOptions: tweakListOptions}
+}
+
+// PacemakerConfigs returns a PacemakerConfigInformer.
+func (v *version) PacemakerConfigs() PacemakerConfigInformer {
+	return &pacemakerConfigInformer{factory: v.factory, tweakListOptions: v.tweakListOptions}
+}
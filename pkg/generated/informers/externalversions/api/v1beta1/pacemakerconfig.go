@@ -0,0 +1,85 @@
+// Code generated by informer-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	context "context"
+	time "time"
+	podpacemakerapiv1beta1 "woehrl01/pod-pacemaker/api/v1beta1"
+	versioned "woehrl01/pod-pacemaker/pkg/generated/clientset/versioned"
+	internalinterfaces "woehrl01/pod-pacemaker/pkg/generated/informers/externalversions/internalinterfaces"
+	apiv1beta1 "woehrl01/pod-pacemaker/pkg/generated/listers/api/v1beta1"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+	watch "k8s.io/apimachinery/pkg/watch"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// PacemakerConfigInformer provides access to a shared informer and lister for
+// PacemakerConfigs.
+type PacemakerConfigInformer interface {
+	Informer() cache.SharedIndexInformer
+	Lister() apiv1beta1.PacemakerConfigLister
+}
+
+type pacemakerConfigInformer struct {
+	factory          internalinterfaces.SharedInformerFactory
+	tweakListOptions internalinterfaces.TweakListOptionsFunc
+}
+
+// NewPacemakerConfigInformer constructs a new informer for PacemakerConfig type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewPacemakerConfigInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers) cache.SharedIndexInformer {
+	return NewFilteredPacemakerConfigInformer(client, resyncPeriod, indexers, nil)
+}
+
+// NewFilteredPacemakerConfigInformer constructs a new informer for PacemakerConfig type.
+// Always prefer using an informer factory to get a shared informer instead of getting an independent
+// one. This reduces memory footprint and number of connections to the server.
+func NewFilteredPacemakerConfigInformer(client versioned.Interface, resyncPeriod time.Duration, indexers cache.Indexers, tweakListOptions internalinterfaces.TweakListOptionsFunc) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.WoehrlV1beta1().PacemakerConfigs().List(context.Background(), options)
+			},
+			WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.WoehrlV1beta1().PacemakerConfigs().Watch(context.Background(), options)
+			},
+			ListWithContextFunc: func(ctx context.Context, options v1.ListOptions) (runtime.Object, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.WoehrlV1beta1().PacemakerConfigs().List(ctx, options)
+			},
+			WatchFuncWithContext: func(ctx context.Context, options v1.ListOptions) (watch.Interface, error) {
+				if tweakListOptions != nil {
+					tweakListOptions(&options)
+				}
+				return client.WoehrlV1beta1().PacemakerConfigs().Watch(ctx, options)
+			},
+		},
+		&podpacemakerapiv1beta1.PacemakerConfig{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+func (f *pacemakerConfigInformer) defaultInformer(client versioned.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	return NewFilteredPacemakerConfigInformer(client, resyncPeriod, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}, f.tweakListOptions)
+}
+
+func (f *pacemakerConfigInformer) Informer() cache.SharedIndexInformer {
+	return f.factory.InformerFor(&podpacemakerapiv1beta1.PacemakerConfig{}, f.defaultInformer)
+}
+
+func (f *pacemakerConfigInformer) Lister() apiv1beta1.PacemakerConfigLister {
+	return apiv1beta1.NewPacemakerConfigLister(f.Informer().GetIndexer())
+}
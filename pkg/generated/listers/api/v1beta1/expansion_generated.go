@@ -0,0 +1,7 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta1
+
+// PacemakerConfigListerExpansion allows custom methods to be added to
+// PacemakerConfigLister.
+type PacemakerConfigListerExpansion interface{}
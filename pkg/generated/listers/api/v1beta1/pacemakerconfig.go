@@ -0,0 +1,33 @@
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1beta1
+
+import (
+	apiv1beta1 "woehrl01/pod-pacemaker/api/v1beta1"
+
+	labels "k8s.io/apimachinery/pkg/labels"
+	listers "k8s.io/client-go/listers"
+	cache "k8s.io/client-go/tools/cache"
+)
+
+// PacemakerConfigLister helps list PacemakerConfigs.
+// All objects returned here must be treated as read-only.
+type PacemakerConfigLister interface {
+	// List lists all PacemakerConfigs in the indexer.
+	// Objects returned here must be treated as read-only.
+	List(selector labels.Selector) (ret []*apiv1beta1.PacemakerConfig, err error)
+	// Get retrieves the PacemakerConfig from the index for a given name.
+	// Objects returned here must be treated as read-only.
+	Get(name string) (*apiv1beta1.PacemakerConfig, error)
+	PacemakerConfigListerExpansion
+}
+
+// pacemakerConfigLister implements the PacemakerConfigLister interface.
+type pacemakerConfigLister struct {
+	listers.ResourceIndexer[*apiv1beta1.PacemakerConfig]
+}
+
+// NewPacemakerConfigLister returns a new PacemakerConfigLister.
+func NewPacemakerConfigLister(indexer cache.Indexer) PacemakerConfigLister {
+	return &pacemakerConfigLister{listers.New[*apiv1beta1.PacemakerConfig](indexer, apiv1beta1.Resource("pacemakerconfig"))}
+}
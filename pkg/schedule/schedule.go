@@ -0,0 +1,184 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // the timezones are also available in images without a zoneinfo database
+
+	"github.com/robfig/cron/v3"
+)
+
+// Window is a recurring period of time in which a schedule is active
+type Window interface {
+	Active(t time.Time) bool
+	// NextTransition returns a time after t at which Active may change, or the zero time if it never changes again
+	NextTransition(t time.Time) time.Time
+}
+
+// cronWindow is active for a duration after each time of a cron expression
+type cronWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// NewCronWindow returns a window which starts at the times of the cron expression, e.g. `0 22 * * *`, and lasts for duration
+func NewCronWindow(expression string, duration time.Duration, location *time.Location) (Window, error) {
+	schedule, err := cron.ParseStandard(expression)
+	if err != nil {
+		return nil, err
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("the duration must be positive")
+	}
+	return &cronWindow{schedule: schedule, duration: duration, location: location}, nil
+}
+
+func (w *cronWindow) Active(t time.Time) bool {
+	local := t.In(w.location)
+	return !w.schedule.Next(local.Add(-w.duration)).After(local)
+}
+
+func (w *cronWindow) NextTransition(t time.Time) time.Time {
+	local := t.In(w.location)
+	next := w.schedule.Next(local)
+	if start := w.schedule.Next(local.Add(-w.duration)); !start.After(local) {
+		// the end of the window which started last, a later start within the window extends it
+		for s := start; !s.After(local); s = w.schedule.Next(s) {
+			start = s
+		}
+		if end := start.Add(w.duration); end.Before(next) {
+			next = end
+		}
+	}
+	return next
+}
+
+// timeRangeWindow is active between two times of day on the selected weekdays, a range which ends
+// before it starts spans midnight and belongs to the weekday it starts on
+type timeRangeWindow struct {
+	weekdays map[time.Weekday]bool
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// NewTimeRangeWindow returns a window from start to end, e.g. `08:00` to `18:00`, on the weekdays or every day if there are none.
+// If start and end are equal the window lasts the whole day.
+func NewTimeRangeWindow(weekdays []time.Weekday, start string, end string, location *time.Location) (Window, error) {
+	startOfDay, err := parseTimeOfDay(start)
+	if err != nil {
+		return nil, err
+	}
+	endOfDay, err := parseTimeOfDay(end)
+	if err != nil {
+		return nil, err
+	}
+	w := &timeRangeWindow{weekdays: map[time.Weekday]bool{}, start: startOfDay, end: endOfDay, location: location}
+	for _, weekday := range weekdays {
+		w.weekdays[weekday] = true
+	}
+	return w, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w *timeRangeWindow) onWeekday(weekday time.Weekday) bool {
+	return len(w.weekdays) == 0 || w.weekdays[weekday]
+}
+
+func (w *timeRangeWindow) Active(t time.Time) bool {
+	local := t.In(w.location)
+	timeOfDay := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	if w.start < w.end {
+		return w.onWeekday(local.Weekday()) && timeOfDay >= w.start && timeOfDay < w.end
+	}
+	if timeOfDay >= w.start {
+		return w.onWeekday(local.Weekday())
+	}
+	// the range started the day before
+	return timeOfDay < w.end && w.onWeekday(local.AddDate(0, 0, -1).Weekday())
+}
+
+func (w *timeRangeWindow) NextTransition(t time.Time) time.Time {
+	local := t.In(w.location)
+	for days := 0; days <= 1; days++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+days, 0, 0, 0, 0, w.location)
+		next := time.Time{}
+		for _, offset := range []time.Duration{w.start, w.end, 24 * time.Hour} {
+			candidate := atTimeOfDay(day, offset)
+			if candidate.After(local) && (next.IsZero() || candidate.Before(next)) {
+				next = candidate
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// atTimeOfDay returns the wall clock time of the day, so the window follows daylight saving time
+func atTimeOfDay(day time.Time, offset time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, int(offset/time.Minute), 0, 0, day.Location())
+}
+
+// dateRange restricts a window to the days from the first to the last date
+type dateRange struct {
+	window Window
+	from   time.Time
+	to     time.Time
+}
+
+// WithinDates restricts the window to the days from the first to the last date, e.g. `2026-12-24` to `2026-12-26`, empty dates are open
+func WithinDates(window Window, first string, last string, location *time.Location) (Window, error) {
+	r := &dateRange{window: window}
+	if first != "" {
+		from, err := time.ParseInLocation(time.DateOnly, first, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", first)
+		}
+		r.from = from
+	}
+	if last != "" {
+		to, err := time.ParseInLocation(time.DateOnly, last, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", last)
+		}
+		r.to = to.AddDate(0, 0, 1)
+	}
+	if !r.from.IsZero() && !r.to.IsZero() && !r.from.Before(r.to) {
+		return nil, fmt.Errorf("the first date %s is after the last date %s", first, last)
+	}
+	return r, nil
+}
+
+func (r *dateRange) Active(t time.Time) bool {
+	if !r.from.IsZero() && t.Before(r.from) {
+		return false
+	}
+	if !r.to.IsZero() && !t.Before(r.to) {
+		return false
+	}
+	return r.window.Active(t)
+}
+
+func (r *dateRange) NextTransition(t time.Time) time.Time {
+	if !r.from.IsZero() && t.Before(r.from) {
+		return r.from
+	}
+	if !r.to.IsZero() && !t.Before(r.to) {
+		return time.Time{}
+	}
+	next := r.window.NextTransition(t)
+	if !r.to.IsZero() && (next.IsZero() || next.After(r.to)) {
+		return r.to
+	}
+	return next
+}
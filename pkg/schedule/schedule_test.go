@@ -0,0 +1,292 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+var berlin = mustLoadLocation("Europe/Berlin")
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
+
+// at parses a time with its offset, e.g. 2026-03-29T03:00:00+02:00
+func at(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// transition is an expectation of Active and NextTransition of a window at a time
+type transition struct {
+	at         string
+	wantActive bool
+	wantNext   string // empty if the window never changes again
+}
+
+func checkTransitions(t *testing.T, window Window, transitions []transition) {
+	t.Helper()
+	for _, tt := range transitions {
+		now := at(t, tt.at)
+		if got := window.Active(now); got != tt.wantActive {
+			t.Errorf("Active(%s) = %v, want %v", tt.at, got, tt.wantActive)
+		}
+		got := window.NextTransition(now)
+		if tt.wantNext == "" {
+			if !got.IsZero() {
+				t.Errorf("NextTransition(%s) = %s, want none", tt.at, got.Format(time.RFC3339))
+			}
+			continue
+		}
+		if want := at(t, tt.wantNext); !got.Equal(want) {
+			t.Errorf("NextTransition(%s) = %s, want %s", tt.at, got.Format(time.RFC3339), tt.wantNext)
+		}
+	}
+}
+
+func TestCronWindow(t *testing.T) {
+	tests := []struct {
+		name        string
+		expression  string
+		duration    time.Duration
+		location    *time.Location
+		transitions []transition
+	}{
+		{
+			name:       "window within the day",
+			expression: "0 22 * * *",
+			duration:   2 * time.Hour,
+			location:   time.UTC,
+			transitions: []transition{
+				{at: "2026-06-01T12:00:00Z", wantActive: false, wantNext: "2026-06-01T22:00:00Z"},
+				{at: "2026-06-01T22:00:00Z", wantActive: true, wantNext: "2026-06-02T00:00:00Z"},
+				{at: "2026-06-01T23:00:00Z", wantActive: true, wantNext: "2026-06-02T00:00:00Z"},
+				{at: "2026-06-02T00:00:00Z", wantActive: false, wantNext: "2026-06-02T22:00:00Z"},
+			},
+		},
+		{
+			// every start extends the window, the next start is reported as a transition before the end
+			name:       "duration exceeds the period",
+			expression: "*/5 * * * *",
+			duration:   time.Hour,
+			location:   time.UTC,
+			transitions: []transition{
+				{at: "2026-06-01T12:02:00Z", wantActive: true, wantNext: "2026-06-01T12:05:00Z"},
+			},
+		},
+		{
+			// the duration is elapsed time, the window lasts 3 hours although the clocks skip an hour
+			name:       "spring forward in Europe/Berlin",
+			expression: "0 1 * * *",
+			duration:   3 * time.Hour,
+			location:   berlin,
+			transitions: []transition{
+				{at: "2026-03-29T00:30:00+01:00", wantActive: false, wantNext: "2026-03-29T01:00:00+01:00"},
+				{at: "2026-03-29T01:30:00+01:00", wantActive: true, wantNext: "2026-03-29T05:00:00+02:00"},
+				{at: "2026-03-29T04:59:00+02:00", wantActive: true, wantNext: "2026-03-29T05:00:00+02:00"},
+				{at: "2026-03-29T05:00:00+02:00", wantActive: false, wantNext: "2026-03-30T01:00:00+02:00"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := NewCronWindow(tt.expression, tt.duration, tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTransitions(t, window, tt.transitions)
+		})
+	}
+}
+
+func TestTimeRangeWindow(t *testing.T) {
+	workdays := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
+	tests := []struct {
+		name        string
+		weekdays    []time.Weekday
+		start       string
+		end         string
+		location    *time.Location
+		transitions []transition
+	}{
+		{
+			name:     "working hours",
+			weekdays: workdays,
+			start:    "08:00",
+			end:      "18:00",
+			location: time.UTC,
+			transitions: []transition{
+				{at: "2026-06-01T07:59:00Z", wantActive: false, wantNext: "2026-06-01T08:00:00Z"},
+				{at: "2026-06-01T08:00:00Z", wantActive: true, wantNext: "2026-06-01T18:00:00Z"},
+				{at: "2026-06-01T18:00:00Z", wantActive: false, wantNext: "2026-06-02T00:00:00Z"},
+				// saturday, the boundaries of the range are reported although they don't change anything
+				{at: "2026-06-06T12:00:00Z", wantActive: false, wantNext: "2026-06-06T18:00:00Z"},
+			},
+		},
+		{
+			// the range belongs to the friday it starts on
+			name:     "spans midnight",
+			weekdays: []time.Weekday{time.Friday},
+			start:    "22:00",
+			end:      "06:00",
+			location: time.UTC,
+			transitions: []transition{
+				{at: "2026-06-05T03:00:00Z", wantActive: false, wantNext: "2026-06-05T06:00:00Z"},
+				{at: "2026-06-05T23:00:00Z", wantActive: true, wantNext: "2026-06-06T00:00:00Z"},
+				{at: "2026-06-06T03:00:00Z", wantActive: true, wantNext: "2026-06-06T06:00:00Z"},
+				{at: "2026-06-06T06:00:00Z", wantActive: false, wantNext: "2026-06-06T22:00:00Z"},
+				{at: "2026-06-06T23:00:00Z", wantActive: false, wantNext: "2026-06-07T00:00:00Z"},
+			},
+		},
+		{
+			name:     "equal start and end last the whole day",
+			weekdays: []time.Weekday{time.Monday},
+			start:    "00:00",
+			end:      "00:00",
+			location: time.UTC,
+			transitions: []transition{
+				{at: "2026-06-01T00:00:00Z", wantActive: true, wantNext: "2026-06-02T00:00:00Z"},
+				{at: "2026-06-01T23:59:00Z", wantActive: true, wantNext: "2026-06-02T00:00:00Z"},
+				{at: "2026-06-02T12:00:00Z", wantActive: false, wantNext: "2026-06-03T00:00:00Z"},
+			},
+		},
+		{
+			// the times are wall clock times, the range starts at 08:00 local time on both sides of the switch
+			name:     "spring forward in Europe/Berlin",
+			start:    "08:00",
+			end:      "18:00",
+			location: berlin,
+			transitions: []transition{
+				{at: "2026-03-28T07:30:00+01:00", wantActive: false, wantNext: "2026-03-28T08:00:00+01:00"},
+				{at: "2026-03-28T08:30:00+01:00", wantActive: true, wantNext: "2026-03-28T18:00:00+01:00"},
+				{at: "2026-03-29T00:00:00+01:00", wantActive: false, wantNext: "2026-03-29T08:00:00+02:00"},
+				{at: "2026-03-29T07:30:00+02:00", wantActive: false, wantNext: "2026-03-29T08:00:00+02:00"},
+				{at: "2026-03-29T08:30:00+02:00", wantActive: true, wantNext: "2026-03-29T18:00:00+02:00"},
+			},
+		},
+		{
+			// 02:00 doesn't exist on the day of the switch, the range starts once the clocks show 03:00
+			name:     "range in the skipped hour in Europe/Berlin",
+			start:    "02:00",
+			end:      "04:00",
+			location: berlin,
+			transitions: []transition{
+				{at: "2026-03-29T01:30:00+01:00", wantActive: false, wantNext: "2026-03-29T03:00:00+02:00"},
+				{at: "2026-03-29T03:30:00+02:00", wantActive: true, wantNext: "2026-03-29T04:00:00+02:00"},
+			},
+		},
+		{
+			name:     "fall back in Europe/Berlin",
+			start:    "22:00",
+			end:      "06:00",
+			location: berlin,
+			transitions: []transition{
+				{at: "2026-10-25T05:00:00+01:00", wantActive: true, wantNext: "2026-10-25T06:00:00+01:00"},
+				{at: "2026-10-25T06:00:00+01:00", wantActive: false, wantNext: "2026-10-25T22:00:00+01:00"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := NewTimeRangeWindow(tt.weekdays, tt.start, tt.end, tt.location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTransitions(t, window, tt.transitions)
+		})
+	}
+}
+
+func TestWithinDates(t *testing.T) {
+	allDay, err := NewTimeRangeWindow(nil, "00:00", "00:00", berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		first       string
+		last        string
+		transitions []transition
+	}{
+		{
+			name:  "closed range",
+			first: "2026-12-24",
+			last:  "2026-12-26",
+			transitions: []transition{
+				{at: "2026-12-20T12:00:00+01:00", wantActive: false, wantNext: "2026-12-24T00:00:00+01:00"},
+				{at: "2026-12-24T00:00:00+01:00", wantActive: true, wantNext: "2026-12-25T00:00:00+01:00"},
+				{at: "2026-12-26T23:59:00+01:00", wantActive: true, wantNext: "2026-12-27T00:00:00+01:00"},
+				{at: "2026-12-27T00:00:00+01:00", wantActive: false},
+			},
+		},
+		{
+			name:  "open end",
+			first: "2026-12-24",
+			transitions: []transition{
+				{at: "2026-12-23T23:00:00+01:00", wantActive: false, wantNext: "2026-12-24T00:00:00+01:00"},
+				{at: "2027-06-01T12:00:00+02:00", wantActive: true, wantNext: "2027-06-02T00:00:00+02:00"},
+			},
+		},
+		{
+			name: "open start",
+			last: "2026-12-26",
+			transitions: []transition{
+				{at: "2026-01-01T12:00:00+01:00", wantActive: true, wantNext: "2026-01-02T00:00:00+01:00"},
+				{at: "2026-12-27T00:00:00+01:00", wantActive: false},
+			},
+		},
+		{
+			// the last day ends at midnight local time, also when it is a switch of daylight saving time
+			name:  "range ends on a switch in Europe/Berlin",
+			first: "2026-03-28",
+			last:  "2026-03-29",
+			transitions: []transition{
+				{at: "2026-03-29T12:00:00+02:00", wantActive: true, wantNext: "2026-03-30T00:00:00+02:00"},
+				{at: "2026-03-30T00:00:00+02:00", wantActive: false},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, err := WithinDates(allDay, tt.first, tt.last, berlin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTransitions(t, window, tt.transitions)
+		})
+	}
+}
+
+func TestInvalidWindows(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() (Window, error)
+	}{
+		{"invalid cron expression", func() (Window, error) { return NewCronWindow("every day", time.Hour, time.UTC) }},
+		{"cron without duration", func() (Window, error) { return NewCronWindow("0 22 * * *", 0, time.UTC) }},
+		{"invalid time of day", func() (Window, error) { return NewTimeRangeWindow(nil, "8am", "18:00", time.UTC) }},
+		{"invalid date", func() (Window, error) { return WithinDates(nil, "24.12.2026", "", time.UTC) }},
+		{"first date after the last", func() (Window, error) { return WithinDates(nil, "2026-12-26", "2026-12-24", time.UTC) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.build(); err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}
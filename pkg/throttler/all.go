@@ -2,12 +2,22 @@ package throttler
 
 import (
 	"context"
+	"slices"
+	"sync"
+	"time"
 
+	"woehrl01/pod-pacemaker/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/time/rate"
 )
 
 type allThrottler struct {
-	dynamic DynamicThrottler
+	dynamic   DynamicThrottler
+	mu        sync.Mutex
+	blockedBy map[string]Throttler
 }
 
 type Options struct {
@@ -19,29 +29,105 @@ type Options struct {
 
 func NewAllThrottler(dynamic DynamicThrottler) Throttler {
 	return &allThrottler{
-		dynamic: dynamic,
+		dynamic:   dynamic,
+		blockedBy: make(map[string]Throttler),
 	}
 }
 
 var _ Throttler = &allThrottler{}
+var _ BlockingReporter = &allThrottler{}
+var _ SlotLister = &allThrottler{}
+var _ SlotLookup = &allThrottler{}
+var _ SlotRegistrar = &allThrottler{}
 
 func (t *allThrottler) String() string {
 	return "AllThrottler"
 }
 
+// AquireSlot waits in the chain of the group of the slot. If the throttlers are replaced while the slot waits,
+// e.g. at the boundary of a schedule, the slot continues in the new chain. The new throttlers adopted the slots
+// which were already acquired, so only the remaining throttlers are waited for.
 func (t *allThrottler) AquireSlot(ctx context.Context, slotId string, data Data) error {
-	list := t.dynamic.GetThrottlers()
+	defer t.setBlockedBy(slotId, nil)
+	for {
+		// taken before the chain, so a replacement in between isn't missed
+		replaced := t.dynamic.Replaced()
+		list := t.dynamic.GetChain(data.Group)
+		err := t.aquireChain(ctx, replaced, list, slotId, data)
+		select {
+		case <-replaced:
+			if err == nil {
+				// admitted right before the replacement, the new throttlers count it like an adopted slot
+				t.registerReplaced(list, slotId, data)
+				return nil
+			}
+			if ctx.Err() == nil {
+				continue
+			}
+		default:
+		}
+		return err
+	}
+}
+
+// registerReplaced counts the slot in the throttlers of the current chain which replaced the chain that admitted it
+func (t *allThrottler) registerReplaced(previous []Throttler, slotId string, data Data) {
+	replacements := slices.DeleteFunc(slices.Clone(t.dynamic.GetChain(data.Group)), func(throttle Throttler) bool {
+		return slices.Contains(previous, throttle)
+	})
+	registerSlot(replacements, slotId, data, time.Now())
+}
+
+// aquireChain stops waiting once the chain is replaced, the previous throttlers aren't released anymore
+func (t *allThrottler) aquireChain(ctx context.Context, replaced <-chan struct{}, list []Throttler, slotId string, data Data) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-replaced:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
 	for _, throttle := range list {
-		if err := throttle.AquireSlot(ctx, slotId, data); err != nil {
+		t.setBlockedBy(slotId, throttle)
+		throttleType := throttle.Describe().Type
+		stageCtx, span := tracing.Tracer().Start(ctx, "acquire "+throttleType, trace.WithAttributes(
+			attribute.String("throttler", throttle.String()),
+		))
+		start := time.Now()
+		err := throttle.AquireSlot(stageCtx, slotId, data)
+		data.Breakdown.add(throttleType, throttle.String(), time.Since(start))
+		if err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
 			return err
 		}
+		span.End()
 	}
 	return nil
 }
 
+func (t *allThrottler) setBlockedBy(slotId string, throttle Throttler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if throttle == nil {
+		delete(t.blockedBy, slotId)
+	} else {
+		t.blockedBy[slotId] = throttle
+	}
+}
+
+func (t *allThrottler) BlockedBy(slotId string) (Throttler, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	throttle, ok := t.blockedBy[slotId]
+	return throttle, ok
+}
+
 func (t *allThrottler) ReleaseSlot(ctx context.Context, slotId string) {
-	list := t.dynamic.GetThrottlers()
+	list := t.dynamic.GetAllThrottlers()
 
 	for i := len(list) - 1; i >= 0; i-- {
 		throttle := list[i]
@@ -49,8 +135,13 @@ func (t *allThrottler) ReleaseSlot(ctx context.Context, slotId string) {
 	}
 }
 
+// RegisterSlot counts the slot in the throttlers of the chain of its group
+func (t *allThrottler) RegisterSlot(slotId string, data Data, acquiredAt time.Time) {
+	registerSlot(t.dynamic.GetChain(data.Group), slotId, data, acquiredAt)
+}
+
 func (t *allThrottler) ActiveSlots() []string {
-	list := t.dynamic.GetThrottlers()
+	list := t.dynamic.GetAllThrottlers()
 
 	activeSlots := []string{}
 	for _, throttle := range list {
@@ -59,25 +150,211 @@ func (t *allThrottler) ActiveSlots() []string {
 	return activeSlots
 }
 
+func (t *allThrottler) Slots() []SlotInfo {
+	list := t.dynamic.GetAllThrottlers()
+
+	slots := []SlotInfo{}
+	for _, throttle := range list {
+		if lister, ok := throttle.(SlotLister); ok {
+			slots = append(slots, lister.Slots()...)
+		}
+	}
+	return slots
+}
+
+func (t *allThrottler) AcquiredAt(slotId string) (time.Time, bool) {
+	return earliestAcquiredAt(t.dynamic.GetAllThrottlers(), slotId)
+}
+
+func (t *allThrottler) Describe() Snapshot {
+	list := t.dynamic.GetThrottlers()
+	groups := t.dynamic.GetGroups()
+
+	t.mu.Lock()
+	snapshot := Snapshot{
+		Type:        TypeAll,
+		Description: t.String(),
+		Waiters:     len(t.blockedBy),
+		Children:    make([]Snapshot, 0, len(list)),
+	}
+	t.mu.Unlock()
+
+	for _, throttle := range list {
+		child := throttle.Describe()
+		snapshot.ActiveSlots = max(snapshot.ActiveSlots, child.ActiveSlots)
+		snapshot.Children = append(snapshot.Children, child)
+	}
+	// the inherited throttlers are already part of the default chain
+	for _, group := range groups {
+		for _, throttle := range group.Throttlers {
+			child := throttle.Describe()
+			child.Group = group.Name
+			snapshot.ActiveSlots = max(snapshot.ActiveSlots, child.ActiveSlots)
+			snapshot.Children = append(snapshot.Children, child)
+		}
+	}
+	if final := t.dynamic.GetFinal(); final != nil {
+		child := final.Describe()
+		snapshot.ActiveSlots = max(snapshot.ActiveSlots, child.ActiveSlots)
+		snapshot.Children = append(snapshot.Children, child)
+	}
+	return snapshot
+}
+
+// AdoptSlots passes the active slots of each previous throttler to the next throttler at the same position of the chain
+func AdoptSlots(previous []Throttler, next []Throttler) {
+	for i, throttle := range next {
+		if i >= len(previous) {
+			return
+		}
+		if adopter, ok := throttle.(SlotAdopter); ok {
+			adopter.AdoptSlots(previous[i])
+		}
+	}
+}
+
+// AdoptGroupSlots passes the active slots of the previous groups to the next groups with the same name
+func AdoptGroupSlots(previous []Group, next []Group) {
+	for _, group := range next {
+		i := slices.IndexFunc(previous, func(g Group) bool { return g.Name == group.Name })
+		if i >= 0 {
+			AdoptSlots(previous[i].Throttlers, group.Throttlers)
+		}
+	}
+}
+
+// Group is a throttler chain for the pods of a throttle group
+type Group struct {
+	Name       string
+	Throttlers []Throttler
+	// Inherit passes the pods also through the default chain after the throttlers of the group
+	Inherit bool
+}
+
 type dynamicThrottler struct {
+	mu               sync.RWMutex
 	activeThrottlers []Throttler
+	groups           []Group
+	final            Throttler
+	// replaced is closed once the throttlers are replaced
+	replaced chan struct{}
 }
 
 func NewDynamicThrottler() DynamicThrottler {
 	return &dynamicThrottler{
 		activeThrottlers: []Throttler{},
+		replaced:         make(chan struct{}),
 	}
 }
 
 func (t *dynamicThrottler) SetThrottlers(throttlers []Throttler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.activeThrottlers = throttlers
+	t.notifyReplaced()
+}
+
+// Replace sets the throttlers and the groups at once, so no slot sees a mix of the previous and the new ones
+func (t *dynamicThrottler) Replace(throttlers []Throttler, groups []Group) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.activeThrottlers = throttlers
+	t.groups = groups
+	t.notifyReplaced()
+}
+
+// Replaced returns a channel which is closed once the current throttlers are replaced
+func (t *dynamicThrottler) Replaced() <-chan struct{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.replaced
+}
+
+// notifyReplaced wakes up the slots which wait in the previous throttlers. This needs be called with the lock held.
+func (t *dynamicThrottler) notifyReplaced() {
+	close(t.replaced)
+	t.replaced = make(chan struct{})
 }
 
 func (t *dynamicThrottler) GetThrottlers() []Throttler {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.activeThrottlers
+}
+
+func (t *dynamicThrottler) SetGroups(groups []Group) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.groups = groups
+	t.notifyReplaced()
+}
+
+func (t *dynamicThrottler) GetGroups() []Group {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.groups
+}
+
+// SetFinal sets the throttler every chain ends with, it isn't replaced by the config, e.g. the lease of the coordinator
+func (t *dynamicThrottler) SetFinal(final Throttler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.final = final
+}
+
+func (t *dynamicThrottler) GetFinal() Throttler {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.final
+}
+
+// GetChain returns the throttlers a pod of the group passes, a pod of an unknown group passes the default chain
+func (t *dynamicThrottler) GetChain(group string) []Throttler {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.withFinal(t.chain(group))
+}
+
+func (t *dynamicThrottler) chain(group string) []Throttler {
+	for _, g := range t.groups {
+		if g.Name != group {
+			continue
+		}
+		if !g.Inherit {
+			return g.Throttlers
+		}
+		return append(slices.Clip(g.Throttlers), t.activeThrottlers...)
+	}
 	return t.activeThrottlers
 }
 
+func (t *dynamicThrottler) withFinal(throttlers []Throttler) []Throttler {
+	if t.final == nil {
+		return throttlers
+	}
+	return append(slices.Clip(throttlers), t.final)
+}
+
+// GetAllThrottlers returns the throttlers of the default chain, of all groups and the final throttler
+func (t *dynamicThrottler) GetAllThrottlers() []Throttler {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	all := slices.Clone(t.activeThrottlers)
+	for _, g := range t.groups {
+		all = append(all, g.Throttlers...)
+	}
+	return t.withFinal(all)
+}
+
 type DynamicThrottler interface {
 	SetThrottlers(throttlers []Throttler)
 	GetThrottlers() []Throttler
+	SetGroups(groups []Group)
+	GetGroups() []Group
+	Replace(throttlers []Throttler, groups []Group)
+	Replaced() <-chan struct{}
+	SetFinal(final Throttler)
+	GetFinal() Throttler
+	GetChain(group string) []Throttler
+	GetAllThrottlers() []Throttler
 }
@@ -0,0 +1,42 @@
+package throttler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StageWait is the time a slot spent in a single throttler of the chain
+type StageWait struct {
+	Type        string
+	Description string
+	Duration    time.Duration
+}
+
+// Breakdown collects the time spent in each throttler while acquiring a slot
+type Breakdown struct {
+	Stages []StageWait
+}
+
+func (b *Breakdown) add(throttleType string, description string, duration time.Duration) {
+	if b == nil {
+		return
+	}
+	b.Stages = append(b.Stages, StageWait{
+		Type:        throttleType,
+		Description: description,
+		Duration:    duration,
+	})
+}
+
+// String formats the breakdown for logs, e.g. "rateLimit=0s loadAvg=1m12s"
+func (b *Breakdown) String() string {
+	if b == nil || len(b.Stages) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(b.Stages))
+	for _, stage := range b.Stages {
+		parts = append(parts, fmt.Sprintf("%s=%s", stage.Type, stage.Duration.Round(time.Millisecond)))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,80 @@
+package throttler
+
+import (
+	"fmt"
+	"strconv"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Build creates the throttlers of the pipeline, in the order of the pipeline.
+// The load monitors of the throttlers stop when close is closed, this has to be done by the caller also if an error is returned.
+func (e Environment) Build(pipeline []v1beta1.Throttler, close chan struct{}) ([]Throttler, error) {
+	throttlers := make([]Throttler, 0, len(pipeline))
+	for i, config := range pipeline {
+		t, err := e.build(config, close)
+		if err != nil {
+			return nil, fmt.Errorf("throttler %d (%s): %w", i, config.Type(), err)
+		}
+		throttlers = append(throttlers, t)
+	}
+	return throttlers, nil
+}
+
+// BuildGroups creates the throttler chains of the throttle groups, in the order of the groups
+func (e Environment) BuildGroups(configs []v1beta1.ThrottleGroup, close chan struct{}) ([]Group, error) {
+	groups := make([]Group, 0, len(configs))
+	for _, config := range configs {
+		throttlers, err := e.Build(config.Throttlers, close)
+		if err != nil {
+			return nil, fmt.Errorf("throttle group %s: %w", config.Name, err)
+		}
+		groups = append(groups, Group{Name: config.Name, Throttlers: throttlers, Inherit: config.InheritThrottlers})
+	}
+	return groups, nil
+}
+
+func (e Environment) build(config v1beta1.Throttler, close chan struct{}) (Throttler, error) {
+	switch {
+	case config.RateLimit != nil:
+		return e.NewRateLimitThrottler(config.RateLimit.FillFactor.Duration.String(), config.RateLimit.Burst)
+	case config.MaxConcurrent != nil:
+		return e.NewDynamicConcurrencyThrottler(config.MaxConcurrent.Value, formatQuantity(config.MaxConcurrent.PerCore))
+	case config.LoadAvg != nil:
+		return e.NewConcurrencyControllerBasedOnLoadAvg(
+			formatQuantity(&config.LoadAvg.MaxLoad),
+			config.LoadAvg.PerCore,
+			formatQuantity(config.LoadAvg.IncrementBy),
+			close,
+		)
+	case config.Cpu != nil:
+		return e.NewConcurrencyControllerBasedOnCpu(formatQuantity(&config.Cpu.MaxLoad), formatQuantity(config.Cpu.IncrementBy), close)
+	case config.IO != nil:
+		return e.NewConcurrencyControllerBasedOnIOLoad(formatQuantity(&config.IO.MaxLoad), formatQuantity(config.IO.IncrementBy), close)
+	case config.Cel != nil:
+		return e.NewCelThrottler(config.Cel.Expression, close)
+	case config.AnyOf != nil:
+		children, err := e.Build(config.AnyOf, close)
+		if err != nil {
+			return nil, err
+		}
+		return NewAnyOfThrottler(children), nil
+	case config.AllOf != nil:
+		children, err := e.Build(config.AllOf, close)
+		if err != nil {
+			return nil, err
+		}
+		return NewAllOfThrottler(children), nil
+	}
+	return nil, fmt.Errorf("no throttler is set")
+}
+
+// formatQuantity returns the quantity as a decimal number, which is also used in the description of the throttlers
+func formatQuantity(q *resource.Quantity) string {
+	if q == nil {
+		return ""
+	}
+	return strconv.FormatFloat(q.AsApproximateFloat64(), 'f', -1, 64)
+}
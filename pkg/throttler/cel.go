@@ -0,0 +1,138 @@
+package throttler
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/expression"
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+
+	"github.com/sirupsen/logrus"
+)
+
+// celSampleInterval is the time between two samples of the loads, the expression is also evaluated again
+// after each sample, e.g. for expressions which depend on the time of day
+const celSampleInterval = 5 * time.Second
+
+// celState holds the last samples of the loads the expression uses
+type celState struct {
+	mu      sync.Mutex
+	cpu     float64
+	io      float64
+	loadAvg float64
+	psi     map[string]float64
+	closed  bool
+}
+
+// NewCelThrottler admits the slots while the CEL expression returns true, see expression.ConditionVariables for the variables.
+// Only the loads the expression uses are measured.
+func (e Environment) NewCelThrottler(source string, close chan struct{}) (*ConcurrencyController, error) {
+	condition, err := expression.CompileConditionExpression(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile the expression %q: %w", source, err)
+	}
+
+	state := &celState{psi: map[string]float64{}}
+	for _, resource := range pressureResources {
+		state.psi[resource] = 0
+	}
+	monitorHeartbeats.Store(state, heartbeat{name: "cel", at: time.Now()})
+
+	clock := e.Clock
+	if clock == nil {
+		clock = RealClock
+	}
+	c, updated := NewConcurrencyControllerWithDynamicCondition(&DynamicOptions{
+		Type:  TypeCel,
+		Clock: clock,
+		Condition: func(active int, data Data) (bool, error) {
+			state.mu.Lock()
+			variables := expression.ConditionVariables{
+				Active:  active,
+				Cpus:    e.NumCPU,
+				Cpu:     state.cpu,
+				IO:      state.io,
+				LoadAvg: state.loadAvg,
+				Psi:     maps.Clone(state.psi),
+				Now:     clock.Now(),
+				Pod:     data.Pod,
+			}
+			closed := state.closed
+			state.mu.Unlock()
+			if closed {
+				return false, fmt.Errorf("closing cel monitor")
+			}
+			admit, err := condition.Evaluate(variables)
+			if err != nil {
+				return false, fmt.Errorf("failed to evaluate %q: %w", source, err)
+			}
+			return admit, nil
+		},
+		// the pod variables differ per waiter, so a pod which doesn't fit doesn't block the pods behind it
+		PerPod:       condition.Uses("pod") || condition.Uses("requests") || condition.Uses("priority"),
+		OnAquire:     func() {},
+		ConditionStr: source,
+	})
+
+	go func() {
+		for {
+			select {
+			case <-close:
+				logrus.Infof("closing cel monitor")
+				monitorHeartbeats.Delete(state)
+				state.mu.Lock()
+				state.closed = true
+				state.mu.Unlock()
+				updated()
+				return
+			default:
+			}
+
+			// cpu and io block for the duration of their measurement
+			samples := map[string]any{}
+			if condition.Uses("cpu") && e.CpuLoad != nil {
+				cpu := e.CpuLoad()
+				state.mu.Lock()
+				state.cpu = cpu
+				state.mu.Unlock()
+				samples["cpu"] = cpu
+			}
+			if condition.Uses("io") && e.IoLoad != nil {
+				io := e.IoLoad()
+				state.mu.Lock()
+				state.io = io
+				state.mu.Unlock()
+				samples["io"] = io
+			}
+			if condition.Uses("loadAvg") && e.LoadAvg != nil {
+				loadAvg := e.LoadAvg(false)
+				state.mu.Lock()
+				state.loadAvg = loadAvg
+				state.mu.Unlock()
+				samples["loadAvg"] = loadAvg
+			}
+			if condition.Uses("psi") && e.Pressure != nil {
+				psi := e.Pressure()
+				state.mu.Lock()
+				maps.Copy(state.psi, psi)
+				state.mu.Unlock()
+				samples["psi"] = psi
+			}
+			updated()
+			monitorHeartbeats.Store(state, heartbeat{name: "cel", at: time.Now()})
+			if len(samples) > 0 {
+				flightrecorder.Record(flightrecorder.KindLoadSample, "", "cel", samples)
+			}
+
+			if !condition.Uses("cpu") && !condition.Uses("io") {
+				select {
+				case <-clock.After(celSampleInterval):
+				case <-close:
+				}
+			}
+		}
+	}()
+	return c, nil
+}
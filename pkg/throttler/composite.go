@@ -0,0 +1,197 @@
+package throttler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compositeThrottler combines throttlers, an allOf admits a slot once all of them admitted it
+// and an anyOf as soon as one of them admitted it
+type compositeThrottler struct {
+	throttlerType string
+	children      []Throttler
+	mu            sync.Mutex
+	waiters       map[string]struct{}
+}
+
+func NewAllOfThrottler(children []Throttler) Throttler {
+	return &compositeThrottler{throttlerType: TypeAllOf, children: children, waiters: map[string]struct{}{}}
+}
+
+func NewAnyOfThrottler(children []Throttler) Throttler {
+	return &compositeThrottler{throttlerType: TypeAnyOf, children: children, waiters: map[string]struct{}{}}
+}
+
+var _ Throttler = &compositeThrottler{}
+var _ SlotLister = &compositeThrottler{}
+var _ SlotAdopter = &compositeThrottler{}
+var _ SlotCanceller = &compositeThrottler{}
+var _ SlotRegistrar = &compositeThrottler{}
+
+func (t *compositeThrottler) String() string {
+	descriptions := make([]string, 0, len(t.children))
+	for _, child := range t.children {
+		descriptions = append(descriptions, child.String())
+	}
+	return fmt.Sprintf("%s(%s)", t.throttlerType, strings.Join(descriptions, ", "))
+}
+
+func (t *compositeThrottler) AquireSlot(ctx context.Context, slotId string, data Data) error {
+	t.mu.Lock()
+	t.waiters[slotId] = struct{}{}
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.waiters, slotId)
+	}()
+
+	if t.throttlerType == TypeAnyOf {
+		return t.aquireAny(ctx, slotId, data)
+	}
+	return t.aquireAll(ctx, slotId, data)
+}
+
+func (t *compositeThrottler) aquireAll(ctx context.Context, slotId string, data Data) error {
+	for i, child := range t.children {
+		if err := child.AquireSlot(ctx, slotId, data); err != nil {
+			// a failed branch of an anyOf must not keep the slots or tokens of its first throttlers
+			cancelAll(ctx, t.children[:i], slotId)
+			return err
+		}
+	}
+	return nil
+}
+
+// aquireAny waits in all children at the same time. The first child which admits the slot keeps it,
+// the others stop waiting and cancel the admission if they admitted it at the same time.
+func (t *compositeThrottler) aquireAny(ctx context.Context, slotId string, data Data) error {
+	anyCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		child int
+		err   error
+	}
+	results := make(chan result, len(t.children))
+	for i, child := range t.children {
+		go func() {
+			results <- result{child: i, err: child.AquireSlot(anyCtx, slotId, data)}
+		}()
+	}
+
+	winner := -1
+	errs := make([]error, 0, len(t.children))
+	for range t.children {
+		r := <-results
+		switch {
+		case r.err != nil:
+			errs = append(errs, r.err)
+		case winner < 0:
+			winner = r.child
+			cancel()
+		default:
+			cancelSlot(ctx, t.children[r.child], slotId)
+		}
+	}
+	if winner >= 0 {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
+
+func releaseAll(ctx context.Context, throttlers []Throttler, slotId string) {
+	for i := len(throttlers) - 1; i >= 0; i-- {
+		throttlers[i].ReleaseSlot(ctx, slotId)
+	}
+}
+
+func cancelAll(ctx context.Context, throttlers []Throttler, slotId string) {
+	for i := len(throttlers) - 1; i >= 0; i-- {
+		cancelSlot(ctx, throttlers[i], slotId)
+	}
+}
+
+func (t *compositeThrottler) ReleaseSlot(ctx context.Context, slotId string) {
+	releaseAll(ctx, t.children, slotId)
+}
+
+// CancelSlot undoes the admission of the slot in all children, e.g. if the composition is a losing branch of an anyOf
+func (t *compositeThrottler) CancelSlot(ctx context.Context, slotId string) {
+	cancelAll(ctx, t.children, slotId)
+}
+
+// AdoptSlots takes over the slots of a composition of the same type, child by child
+func (t *compositeThrottler) AdoptSlots(previous Throttler) {
+	other, ok := previous.(*compositeThrottler)
+	if !ok || other.throttlerType != t.throttlerType {
+		return
+	}
+	AdoptSlots(other.children, t.children)
+}
+
+// RegisterSlot counts the slot in all children of an allOf, and in the first child of an anyOf which counts slots,
+// like a slot which was admitted by that branch
+func (t *compositeThrottler) RegisterSlot(slotId string, data Data, acquiredAt time.Time) {
+	if t.throttlerType == TypeAllOf {
+		registerSlot(t.children, slotId, data, acquiredAt)
+		return
+	}
+	for _, child := range t.children {
+		if registrar, ok := child.(SlotRegistrar); ok {
+			registrar.RegisterSlot(slotId, data, acquiredAt)
+			return
+		}
+	}
+}
+
+func (t *compositeThrottler) ActiveSlots() []string {
+	activeSlots := []string{}
+	for _, child := range t.children {
+		for _, slot := range child.ActiveSlots() {
+			if !slices.Contains(activeSlots, slot) {
+				activeSlots = append(activeSlots, slot)
+			}
+		}
+	}
+	return activeSlots
+}
+
+func (t *compositeThrottler) Slots() []SlotInfo {
+	slots := []SlotInfo{}
+	for _, child := range t.children {
+		if lister, ok := child.(SlotLister); ok {
+			slots = append(slots, lister.Slots()...)
+		}
+	}
+	return slots
+}
+
+func (t *compositeThrottler) AcquiredAt(slotId string) (time.Time, bool) {
+	return earliestAcquiredAt(t.children, slotId)
+}
+
+func (t *compositeThrottler) Describe() Snapshot {
+	t.mu.Lock()
+	snapshot := Snapshot{
+		Type:        t.throttlerType,
+		Description: t.String(),
+		Waiters:     len(t.waiters),
+		Children:    make([]Snapshot, 0, len(t.children)),
+	}
+	t.mu.Unlock()
+
+	for _, child := range t.children {
+		snapshot.Children = append(snapshot.Children, child.Describe())
+	}
+	snapshot.ActiveSlots = len(t.ActiveSlots())
+	return snapshot
+}
@@ -0,0 +1,105 @@
+package throttler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// admittingThrottler admits every slot at once and counts how its admissions end
+type admittingThrottler struct {
+	mu        sync.Mutex
+	cancelled int
+	released  int
+}
+
+func (t *admittingThrottler) AquireSlot(ctx context.Context, slotId string, data Data) error {
+	return nil
+}
+func (t *admittingThrottler) ActiveSlots() []string { return nil }
+func (t *admittingThrottler) String() string        { return "admitting" }
+func (t *admittingThrottler) Describe() Snapshot    { return Snapshot{} }
+
+func (t *admittingThrottler) ReleaseSlot(ctx context.Context, slotId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.released++
+}
+
+func (t *admittingThrottler) CancelSlot(ctx context.Context, slotId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cancelled++
+}
+
+func TestAnyOfCancelsTheLosingBranches(t *testing.T) {
+	children := []*admittingThrottler{{}, {}, {}}
+	anyOf := NewAnyOfThrottler([]Throttler{children[0], children[1], children[2]})
+
+	if err := anyOf.AquireSlot(context.Background(), "slot", Data{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled := 0
+	for i, child := range children {
+		if child.released != 0 {
+			t.Errorf("child %d released the slot, want it to be cancelled", i)
+		}
+		cancelled += child.cancelled
+	}
+	if cancelled != len(children)-1 {
+		t.Errorf("%d children cancelled the slot, want all but the winner", cancelled)
+	}
+}
+
+func TestRateLimitCancelSlotReturnsTheToken(t *testing.T) {
+	rateLimit, err := NewRateLimitThrottler("1h", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquire := func(slotId string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return rateLimit.AquireSlot(ctx, slotId, Data{})
+	}
+
+	if err := acquire("a"); err != nil {
+		t.Fatalf("first slot wasn't admitted: %v", err)
+	}
+	rateLimit.CancelSlot(context.Background(), "a")
+	if err := acquire("b"); err != nil {
+		t.Fatalf("slot wasn't admitted with the returned token: %v", err)
+	}
+	if err := acquire("c"); err == nil {
+		t.Fatalf("slot was admitted without a token")
+	}
+}
+
+func TestAllOfCancelsTheAdmittedThrottlersOnFailure(t *testing.T) {
+	rateLimit, err := NewRateLimitThrottler("1h", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	concurrency, err := NewDynamicConcurrencyThrottler(1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := concurrency.AquireSlot(context.Background(), "running", Data{}); err != nil {
+		t.Fatal(err)
+	}
+	allOf := NewAllOfThrottler([]Throttler{rateLimit, concurrency})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := allOf.AquireSlot(ctx, "blocked", Data{}); err == nil {
+		t.Fatalf("slot was admitted beyond the concurrency")
+	}
+
+	concurrency.ReleaseSlot(context.Background(), "running")
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := allOf.AquireSlot(ctx, "next", Data{}); err != nil {
+		t.Errorf("the token of the failed slot wasn't returned: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package throttler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConstructorsReturnParseErrors(t *testing.T) {
+	closed := make(chan struct{})
+	close(closed)
+	loadOptions := func(maxLoad string, incrementBy string) loadMonitorOptions {
+		return loadMonitorOptions{
+			Type:        TypeCpu,
+			Name:        "cpu",
+			MaxLoad:     maxLoad,
+			IncrementBy: incrementBy,
+			Sample:      func() float64 { return 0 },
+			Interval:    time.Hour,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		build   func() (Throttler, error)
+		wantErr bool
+	}{
+		{"rate limit", func() (Throttler, error) { return NewRateLimitThrottler("1s", 1) }, false},
+		{"rate limit with an invalid duration", func() (Throttler, error) { return NewRateLimitThrottler("fast", 1) }, true},
+		{"max concurrent", func() (Throttler, error) { return NewDynamicConcurrencyThrottler(0, "1.5") }, false},
+		{"max concurrent with an invalid perCore", func() (Throttler, error) { return NewDynamicConcurrencyThrottler(0, "many") }, true},
+		{"load", func() (Throttler, error) { return newLoadBasedController(loadOptions("80", "5"), closed) }, false},
+		{"load with an invalid maxLoad", func() (Throttler, error) { return newLoadBasedController(loadOptions("high", ""), closed) }, true},
+		{"load with an invalid incrementBy", func() (Throttler, error) { return newLoadBasedController(loadOptions("80", "some"), closed) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttler, err := tt.build()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && throttler == nil {
+				t.Errorf("got no throttler and no error")
+			}
+		})
+	}
+}
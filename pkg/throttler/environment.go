@@ -0,0 +1,51 @@
+package throttler
+
+import (
+	"runtime"
+	"time"
+)
+
+// Clock abstracts the time, so the throttlers can run against a virtual clock, e.g. in the simulator
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// RealClock is the wall clock
+var RealClock Clock = realClock{}
+
+// Environment provides the clock, the number of cpus and the load samplers to the throttlers
+type Environment struct {
+	Clock  Clock
+	NumCPU int
+	// CpuLoad and IoLoad return the load in percent, they may block for the duration of the measurement
+	CpuLoad func() float64
+	IoLoad  func() float64
+	LoadAvg func(perCpu bool) float64
+	// Pressure returns the pressure stall information of cpu, memory and io in percent, it's optional
+	Pressure func() map[string]float64
+	// Scale returns the fraction of the limits which applies right now, e.g. during the warmup of the node, it's optional
+	Scale func() float64
+}
+
+func (e Environment) scale() float64 {
+	if e.Scale == nil {
+		return 1
+	}
+	return e.Scale()
+}
+
+// DefaultEnvironment measures the node the process is running on
+var DefaultEnvironment = Environment{
+	Clock:    RealClock,
+	NumCPU:   runtime.NumCPU(),
+	CpuLoad:  GetCpuLoad,
+	IoLoad:   GetIoWait,
+	LoadAvg:  GetLoadAvg,
+	Pressure: GetPressure,
+}
@@ -1,53 +1,25 @@
 package throttler
 
 import (
-	"fmt"
-	"strconv"
 	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/sirupsen/logrus"
 )
 
-func NewConcurrencyControllerBasedOnIOLoad(maxIOLoad string, incrementByStr string, close chan struct{}) *ConcurrencyController {
-	currentLoad := 0.0
-	var err error
-	err = nil
-
-	maxIOLoadf, err := strconv.ParseFloat(maxIOLoad, 64)
-	if err != nil {
-		logrus.Fatalf("failed to parse maxCpuLoad: %s", maxIOLoad)
-	}
-
-	incrementBy := 0.0
-	if incrementByStr != "" {
-		incrementBy, err = strconv.ParseFloat(incrementByStr, 64)
-		if err != nil {
-			logrus.Fatalf("failed to parse incrementBy: %s", incrementByStr)
-		}
-	}
-
-	c, updated := NewConcurrencyControllerWithDynamicCondition(&DynamicOptions{
-		Condition:    func(i int) (bool, error) { return currentLoad < maxIOLoadf, err },
-		OnAquire:     func() { currentLoad += incrementBy },
-		ConditionStr: fmt.Sprintf("current IO Load < %s", maxIOLoad),
-	})
+func NewConcurrencyControllerBasedOnIOLoad(maxIOLoad string, incrementByStr string, close chan struct{}) (*ConcurrencyController, error) {
+	return DefaultEnvironment.NewConcurrencyControllerBasedOnIOLoad(maxIOLoad, incrementByStr, close)
+}
 
-	go func() {
-		for {
-			select {
-			case <-close:
-				logrus.Info("closing IO load monitor")
-				err = fmt.Errorf("closing IO load monitor")
-				return
-			default:
-				currentLoad = GetIoWait()
-				updated()
-				logrus.Debugf("current IO load: %f", currentLoad)
-			}
-		}
-	}()
-	return c
+func (e Environment) NewConcurrencyControllerBasedOnIOLoad(maxIOLoad string, incrementByStr string, close chan struct{}) (*ConcurrencyController, error) {
+	return newLoadBasedController(loadMonitorOptions{
+		Type:        TypeIO,
+		Name:        "IO load",
+		MaxLoad:     maxIOLoad,
+		IncrementBy: incrementByStr,
+		Sample:      e.IoLoad, // measures over 5 seconds
+		Clock:       e.Clock,
+		Scale:       e.Scale,
+	}, close)
 }
 
 // GetIoWait returns the current IO wait, e.g. 0.0 for 0% and 100.0 for 100%
@@ -0,0 +1,141 @@
+package throttler
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"woehrl01/pod-pacemaker/pkg/flightrecorder"
+
+	"github.com/sirupsen/logrus"
+)
+
+type loadMonitorOptions struct {
+	Type        string
+	Name        string
+	MaxLoad     string
+	IncrementBy string
+	// Sample returns the current load, it may block for the duration of the measurement
+	Sample func() float64
+	// Interval is the time to sleep between two samples
+	Interval time.Duration
+	Clock    Clock
+	// Scale returns the fraction of maxLoad which applies right now, it's optional
+	Scale func() float64
+}
+
+// loadState tracks the measured load and the increments of the slots acquired since the last measurement
+type loadState struct {
+	mu          sync.Mutex
+	measured    float64
+	pending     float64
+	maxLoad     float64
+	incrementBy float64
+	closed      bool
+}
+
+// monitorHeartbeats tracks the last sample of every running load monitor
+var monitorHeartbeats sync.Map
+
+type heartbeat struct {
+	name string
+	at   time.Time
+}
+
+// StaleMonitors returns the names of the load monitors which didn't take a sample within maxAge
+func StaleMonitors(maxAge time.Duration) []string {
+	stale := []string{}
+	monitorHeartbeats.Range(func(_, value any) bool {
+		beat := value.(heartbeat)
+		if time.Since(beat.at) > maxAge {
+			stale = append(stale, beat.name)
+		}
+		return true
+	})
+	return stale
+}
+
+func newLoadBasedController(options loadMonitorOptions, close chan struct{}) (*ConcurrencyController, error) {
+	if options.Clock == nil {
+		options.Clock = RealClock
+	}
+	if options.Scale == nil {
+		options.Scale = func() float64 { return 1 }
+	}
+
+	maxLoad, err := strconv.ParseFloat(options.MaxLoad, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse maxLoad of %s: %q", options.Name, options.MaxLoad)
+	}
+
+	incrementBy := 0.0
+	if options.IncrementBy != "" {
+		incrementBy, err = strconv.ParseFloat(options.IncrementBy, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse incrementBy of %s: %q", options.Name, options.IncrementBy)
+		}
+	}
+
+	state := &loadState{maxLoad: maxLoad, incrementBy: incrementBy}
+	monitorHeartbeats.Store(state, heartbeat{name: options.Name, at: time.Now()})
+
+	c, updated := NewConcurrencyControllerWithDynamicCondition(&DynamicOptions{
+		Type:  options.Type,
+		Clock: options.Clock,
+		Condition: func(i int, _ Data) (bool, error) {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			if state.closed {
+				return false, fmt.Errorf("closing %s monitor", options.Name)
+			}
+			return state.measured+state.pending < state.maxLoad*options.Scale(), nil
+		},
+		OnAquire: func() {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			state.pending += state.incrementBy
+		},
+		ConditionStr: fmt.Sprintf("current %s < %s", options.Name, options.MaxLoad),
+		Describe: func(s *Snapshot) {
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			s.Limit = float(state.maxLoad * options.Scale())
+			s.Usage = float(state.measured + state.pending)
+			s.Load = float(state.measured)
+			s.PendingIncrement = float(state.pending)
+		},
+	})
+
+	go func() {
+		for {
+			select {
+			case <-close:
+				logrus.Infof("closing %s monitor", options.Name)
+				monitorHeartbeats.Delete(state)
+				state.mu.Lock()
+				state.closed = true
+				state.mu.Unlock()
+				updated()
+				return
+			default:
+				load := options.Sample()
+				state.mu.Lock()
+				state.measured = load
+				state.pending = 0 // the new measurement contains the load of the started pods
+				state.mu.Unlock()
+				updated()
+				monitorHeartbeats.Store(state, heartbeat{name: options.Name, at: time.Now()})
+				flightrecorder.Record(flightrecorder.KindLoadSample, "", options.Name, map[string]any{"load": load, "maxLoad": maxLoad})
+				logrus.Debugf("current %s: %f", options.Name, load)
+				if options.Interval > 0 {
+					select {
+					case <-options.Clock.After(options.Interval):
+					case <-close:
+					}
+				}
+			}
+		}
+	}()
+	return c, nil
+}
@@ -0,0 +1,48 @@
+package throttler
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// pressureResources are the resources the kernel reports the pressure stall information (PSI) of
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// GetPressure returns the share of time in percent in which some tasks stalled on cpu, memory or io in the last 10 seconds.
+// A resource is 0 if the kernel doesn't report PSI, e.g. because it was booted without psi=1.
+func GetPressure() map[string]float64 {
+	pressure := make(map[string]float64, len(pressureResources))
+	for _, resource := range pressureResources {
+		pressure[resource] = readPressure("/proc/pressure/" + resource)
+	}
+	return pressure
+}
+
+// readPressure returns avg10 of the "some" line, e.g. of "some avg10=1.23 avg60=0.50 avg300=0.10 total=12345"
+func readPressure(path string) float64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		value, ok := strings.CutPrefix(fields[1], "avg10=")
+		if !ok {
+			return 0
+		}
+		avg10, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0
+		}
+		return avg10
+	}
+	return 0
+}
@@ -3,35 +3,58 @@ package throttler
 import (
 	"context"
 	"fmt"
+	"maps"
 	"math"
-	"runtime"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type ConcurrencyController struct {
 	mu              sync.Mutex
+	clock           Clock
+	throttlerType   string
+	describe        func(*Snapshot)
 	waitOnCondition chan struct{}
-	condition       func(int) (bool, error)
+	condition       func(active int, data Data) (bool, error)
+	perPod          bool
 	conditionText   string
 	onAquire        func()
-	activeItems     map[string]bool
+	recheckInterval time.Duration
+	activeItems     map[string]time.Time
+	waitingItems    map[string]Data
 }
 
 type DynamicOptions struct {
-	Condition    func(int) (bool, error)
+	Type         string
+	Condition    func(active int, data Data) (bool, error)
 	OnAquire     func()
 	ConditionStr string
+	// Describe adds the type specific fields to the snapshot of the controller, it's optional
+	Describe func(*Snapshot)
+	// Clock is used for the acquire times of the slots, defaults to the wall clock
+	Clock Clock
+	// RecheckInterval evaluates the condition of the waiting slots periodically, e.g. for a limit which changes over time
+	RecheckInterval time.Duration
+	// PerPod marks a condition which depends on the pod, a waiter whose condition is false doesn't hold back the waiters behind it
+	PerPod bool
 }
 
-func NewDynamicConcurrencyThrottler(staticLimit int, perCpu string) *ConcurrencyController {
+func NewDynamicConcurrencyThrottler(staticLimit int, perCpu string) (*ConcurrencyController, error) {
+	return DefaultEnvironment.NewDynamicConcurrencyThrottler(staticLimit, perCpu)
+}
+
+func (e Environment) NewDynamicConcurrencyThrottler(staticLimit int, perCpu string) (*ConcurrencyController, error) {
 	limit := staticLimit
 	limitType := "static"
 	if staticLimit == 0 && perCpu != "" {
-		perCpuFloat, _ := strconv.ParseFloat(perCpu, 64)
-		limit = int(math.Ceil(perCpuFloat * float64(runtime.NumCPU())))
+		perCpuFloat, err := strconv.ParseFloat(perCpu, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse perCore of maxConcurrent: %q", perCpu)
+		}
+		limit = int(math.Ceil(perCpuFloat * float64(e.NumCPU)))
 		limitType = fmt.Sprintf("perCpu = %s", perCpu)
 	}
 
@@ -39,23 +62,45 @@ func NewDynamicConcurrencyThrottler(staticLimit int, perCpu string) *Concurrency
 		logrus.Warnf("Concurrency limit is too low, setting to 1")
 		limit = 1
 	}
+	// the scaled limit still admits one pod at a time, otherwise the node would never start pods
+	scaledLimit := func() int {
+		return max(1, int(math.Ceil(float64(limit)*e.scale())))
+	}
 
-	c, _ := NewConcurrencyControllerWithDynamicCondition(
-		&DynamicOptions{
-			Condition:    func(currentLength int) (bool, error) { return currentLength < limit, nil },
-			OnAquire:     func() {},
-			ConditionStr: fmt.Sprintf("maxConcurrent = %d, %s", limit, limitType),
+	options := &DynamicOptions{
+		Type:         TypeMaxConcurrent,
+		Clock:        e.Clock,
+		Condition:    func(currentLength int, _ Data) (bool, error) { return currentLength < scaledLimit(), nil },
+		OnAquire:     func() {},
+		ConditionStr: fmt.Sprintf("maxConcurrent = %d, %s", limit, limitType),
+		Describe: func(s *Snapshot) {
+			s.Limit = float(float64(scaledLimit()))
+			s.Usage = float(float64(s.ActiveSlots))
 		},
-	)
-	return c
+	}
+	if e.Scale != nil {
+		options.RecheckInterval = scaleRecheckInterval
+	}
+	c, _ := NewConcurrencyControllerWithDynamicCondition(options)
+	return c, nil
 }
 
 func NewConcurrencyControllerWithDynamicCondition(options *DynamicOptions) (*ConcurrencyController, func()) {
+	clock := options.Clock
+	if clock == nil {
+		clock = RealClock
+	}
 	cc := &ConcurrencyController{
+		clock:           clock,
+		throttlerType:   options.Type,
+		describe:        options.Describe,
 		condition:       options.Condition,
+		perPod:          options.PerPod,
 		conditionText:   options.ConditionStr,
 		onAquire:        options.OnAquire,
-		activeItems:     make(map[string]bool),
+		recheckInterval: options.RecheckInterval,
+		activeItems:     make(map[string]time.Time),
+		waitingItems:    make(map[string]Data),
 		waitOnCondition: make(chan struct{}),
 	}
 	return cc, func() {
@@ -66,6 +111,9 @@ func NewConcurrencyControllerWithDynamicCondition(options *DynamicOptions) (*Con
 }
 
 var _ Throttler = &ConcurrencyController{}
+var _ SlotLister = &ConcurrencyController{}
+var _ SlotAdopter = &ConcurrencyController{}
+var _ SlotRegistrar = &ConcurrencyController{}
 
 func (cc *ConcurrencyController) String() string {
 	return fmt.Sprintf("PriorityThrottler, condition: %s", cc.conditionText)
@@ -78,10 +126,24 @@ func (cc *ConcurrencyController) broadcastPossibleConditionChange() {
 }
 
 func (cc *ConcurrencyController) AquireSlot(ctx context.Context, slotId string, data Data) error {
+	cc.mu.Lock()
+	cc.waitingItems[slotId] = data
+	cc.mu.Unlock()
+
+	defer func() {
+		cc.mu.Lock()
+		defer cc.mu.Unlock()
+		delete(cc.waitingItems, slotId)
+		cc.broadcastPossibleConditionChange() // the next waiter in line might be able to proceed now
+	}()
+
 	for {
+		// taken with the lock held, so a change after the check below isn't missed
+		var changed <-chan struct{}
 		if done, err := func() (bool, error) {
 			cc.mu.Lock()
 			defer cc.mu.Unlock()
+			changed = cc.waitOnCondition
 			_, isActive := cc.activeItems[slotId]
 			if ctx.Err() != nil { // Context was cancelled.
 				if !isActive { // Remove the item if it wasn't activated.
@@ -91,13 +153,13 @@ func (cc *ConcurrencyController) AquireSlot(ctx context.Context, slotId string,
 			}
 			if isActive { // Item is already active.
 				return true, nil
-			} else { // Item is not active.
-				cond, err := cc.condition(len(cc.activeItems))
+			} else if cc.isNextInLine(slotId, data.Ticket) { // Item is not active, but it's its turn.
+				cond, err := cc.condition(len(cc.activeItems), data)
 				if err != nil {
 					return true, err
 				}
 				if cond { // Item can be activated.
-					cc.activeItems[slotId] = true
+					cc.activeItems[slotId] = cc.clock.Now()
 					cc.onAquire()
 					return true, nil
 				}
@@ -107,13 +169,38 @@ func (cc *ConcurrencyController) AquireSlot(ctx context.Context, slotId string,
 			return err
 		}
 
+		var recheck <-chan time.Time
+		if cc.recheckInterval > 0 {
+			recheck = cc.clock.After(cc.recheckInterval)
+		}
 		select {
-		case <-cc.waitOnCondition:
+		case <-changed:
 		case <-ctx.Done():
+		case <-recheck:
 		}
 	}
 }
 
+// isNextInLine checks if no other waiter holds an older ticket. If the condition depends on the pod, older waiters
+// which can't be admitted right now are skipped, so they don't block the others. This needs be called with the lock held.
+func (cc *ConcurrencyController) isNextInLine(slotId string, ticket uint64) bool {
+	for otherId, other := range cc.waitingItems {
+		if other.Ticket < ticket || (other.Ticket == ticket && otherId < slotId) {
+			if cc.perPod && !cc.isAdmissible(other) {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// isAdmissible evaluates the condition for a waiter, failed evaluations are reported to the waiter itself. This needs be called with the lock held.
+func (cc *ConcurrencyController) isAdmissible(data Data) bool {
+	cond, err := cc.condition(len(cc.activeItems), data)
+	return err == nil && cond
+}
+
 func (cc *ConcurrencyController) removeItem(slotId string) {
 	delete(cc.activeItems, slotId)
 	cc.broadcastPossibleConditionChange()
@@ -125,6 +212,35 @@ func (cc *ConcurrencyController) ReleaseSlot(ctx context.Context, slotId string)
 	cc.removeItem(slotId)
 }
 
+// AdoptSlots takes over the active slots of a controller of the same type, the slots keep their acquire time
+func (cc *ConcurrencyController) AdoptSlots(previous Throttler) {
+	other, ok := previous.(*ConcurrencyController)
+	if !ok || other == cc || other.throttlerType != cc.throttlerType {
+		return
+	}
+	other.mu.Lock()
+	adopted := maps.Clone(other.activeItems)
+	other.mu.Unlock()
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for slotId, acquiredAt := range adopted {
+		if _, ok := cc.activeItems[slotId]; !ok {
+			cc.activeItems[slotId] = acquiredAt
+		}
+	}
+	cc.broadcastPossibleConditionChange()
+}
+
+// RegisterSlot activates the slot without waiting for its turn or the condition
+func (cc *ConcurrencyController) RegisterSlot(slotId string, _ Data, acquiredAt time.Time) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if _, ok := cc.activeItems[slotId]; !ok {
+		cc.activeItems[slotId] = acquiredAt
+	}
+}
+
 func (cc *ConcurrencyController) ActiveSlots() []string {
 	cc.mu.Lock()
 	defer cc.mu.Unlock()
@@ -134,3 +250,36 @@ func (cc *ConcurrencyController) ActiveSlots() []string {
 	}
 	return slots
 }
+
+func (cc *ConcurrencyController) AcquiredAt(slotId string) (time.Time, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	acquiredAt, ok := cc.activeItems[slotId]
+	return acquiredAt, ok
+}
+
+func (cc *ConcurrencyController) Slots() []SlotInfo {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	slots := make([]SlotInfo, 0, len(cc.activeItems))
+	for slot, acquiredAt := range cc.activeItems {
+		slots = append(slots, SlotInfo{SlotId: slot, AcquiredAt: acquiredAt, Throttler: cc.String()})
+	}
+	return slots
+}
+
+func (cc *ConcurrencyController) Describe() Snapshot {
+	cc.mu.Lock()
+	snapshot := Snapshot{
+		Type:        cc.throttlerType,
+		Description: cc.String(),
+		ActiveSlots: len(cc.activeItems),
+		Waiters:     len(cc.waitingItems),
+	}
+	cc.mu.Unlock()
+
+	if cc.describe != nil {
+		cc.describe(&snapshot)
+	}
+	return snapshot
+}
@@ -0,0 +1,81 @@
+package throttler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func podRequestingCpu(cpu string) *v1.Pod {
+	return &v1.Pod{Spec: v1.PodSpec{Containers: []v1.Container{{
+		Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu)}},
+	}}}}
+}
+
+func TestConcurrencyControllerSkipsHeadsWhichCantBeAdmitted(t *testing.T) {
+	tests := []struct {
+		name      string
+		throttler func(stop chan struct{}) (*ConcurrencyController, error)
+		// wantAdmitted is set if the second pod overtakes the blocked head
+		wantAdmitted bool
+	}{
+		{
+			name: "cel expression on the requests of the pod",
+			throttler: func(stop chan struct{}) (*ConcurrencyController, error) {
+				return Environment{Clock: RealClock, NumCPU: 4}.NewCelThrottler(`requests["cpu"] <= 2.0`, stop)
+			},
+			wantAdmitted: true,
+		},
+		{
+			name: "condition which doesn't depend on the pod keeps the order",
+			throttler: func(chan struct{}) (*ConcurrencyController, error) {
+				c, _ := NewConcurrencyControllerWithDynamicCondition(&DynamicOptions{
+					Type: TypeMaxConcurrent,
+					Condition: func(_ int, data Data) (bool, error) {
+						return data.Pod.Spec.Containers[0].Resources.Requests.Cpu().Cmp(resource.MustParse("2")) <= 0, nil
+					},
+					OnAquire: func() {},
+				})
+				return c, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stop := make(chan struct{})
+			defer close(stop)
+			throttler, err := tt.throttler(stop)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			headCtx, headCancel := context.WithCancel(context.Background())
+			defer headCancel()
+			head := make(chan error, 1)
+			go func() {
+				head <- throttler.AquireSlot(headCtx, "default/large", Data{Ticket: 1, Pod: podRequestingCpu("4")})
+			}()
+			for deadline := time.Now().Add(time.Second); throttler.Describe().Waiters == 0; time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("the head never waited")
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err = throttler.AquireSlot(ctx, "default/small", Data{Ticket: 2, Pod: podRequestingCpu("1")})
+			if admitted := err == nil; admitted != tt.wantAdmitted {
+				t.Errorf("second pod admitted: %v (%v), want %v", admitted, err, tt.wantAdmitted)
+			}
+
+			headCancel()
+			if err := <-head; err == nil {
+				t.Errorf("the head was admitted although its condition is false")
+			}
+		})
+	}
+}
@@ -3,34 +3,183 @@ package throttler
 import (
 	"context"
 	"fmt"
+	"maps"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 )
 
 type RateLimitThrottler struct {
-	rate *rate.Limiter
+	rate    *rate.Limiter
+	clock   Clock
+	waiters atomic.Int32
+	// the configured rate and burst, which are scaled by scale
+	limit rate.Limit
+	burst int
+	scale func() float64
+
+	mu sync.Mutex
+	// changed is the last time the limiter was changed, the limiter can't go back before it
+	changed time.Time
+	// granted are the reservations of the admitted slots until they are released or cancelled
+	granted map[string]grant
+}
+
+// grant is the reservation which admitted a slot, a retry of the same ticket doesn't take another token
+type grant struct {
+	ticket      uint64
+	reservation *rate.Reservation
+	timeToAct   time.Time
 }
 
-func NewRateLimitThrottler(r string, burst int) *RateLimitThrottler {
+var _ SlotCanceller = &RateLimitThrottler{}
+var _ SlotAdopter = &RateLimitThrottler{}
+
+func NewRateLimitThrottler(r string, burst int) (*RateLimitThrottler, error) {
+	return DefaultEnvironment.NewRateLimitThrottler(r, burst)
+}
+
+func (e Environment) NewRateLimitThrottler(r string, burst int) (*RateLimitThrottler, error) {
 	dur, err := time.ParseDuration(r)
 	if err != nil {
-		logrus.Fatalf("failed to parse rate limit duration: %s", r)
+		return nil, fmt.Errorf("failed to parse rate limit duration: %q", r)
 	}
 	return &RateLimitThrottler{
-		rate: rate.NewLimiter(rate.Every(dur), burst),
+		rate:    rate.NewLimiter(rate.Every(dur), burst),
+		clock:   e.Clock,
+		limit:   rate.Every(dur),
+		burst:   burst,
+		scale:   e.Scale,
+		granted: map[string]grant{},
+	}, nil
+}
+
+// applyScale adjusts the rate and the burst to the current fraction of the limits
+func (t *RateLimitThrottler) applyScale(now time.Time) {
+	if t.scale == nil {
+		return
+	}
+	fraction := t.scale()
+	limit := t.limit * rate.Limit(fraction)
+	burst := max(1, int(math.Ceil(float64(t.burst)*fraction)))
+	if limit != t.rate.Limit() {
+		t.rate.SetLimitAt(now, limit)
+	}
+	if burst != t.rate.Burst() {
+		t.rate.SetBurstAt(now, burst)
 	}
 }
 
-func (t *RateLimitThrottler) AquireSlot(ctx context.Context, slotId string, _ Data) error {
-	if err := t.rate.Wait(ctx); err != nil {
-		return err
+func (t *RateLimitThrottler) AquireSlot(ctx context.Context, slotId string, data Data) error {
+	t.waiters.Add(1)
+	defer t.waiters.Add(-1)
+
+	if t.isGranted(slotId, data.Ticket) {
+		// a retry of the kubelet after a later stage failed, the token was already taken by the previous attempt
+		return nil
+	}
+
+	// like rate.Limiter.Wait, but on the clock of the throttler
+	reservation, now := t.reserve()
+	if !reservation.OK() {
+		return fmt.Errorf("rate limit burst is 0")
+	}
+	delay := reservation.DelayFrom(now)
+	if delay == 0 {
+		t.grant(slotId, data.Ticket, reservation, now)
+		return nil
 	}
-	return nil
+	select {
+	case <-t.clock.After(delay):
+		t.grant(slotId, data.Ticket, reservation, now.Add(delay))
+		return nil
+	case <-ctx.Done():
+		t.cancel(reservation, t.clock.Now()) // return the token, so the next waiter isn't delayed by us
+		return ctx.Err()
+	}
+}
+
+func (t *RateLimitThrottler) reserve() (*rate.Reservation, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	t.applyScale(now)
+	t.changed = now
+	return t.rate.ReserveN(now, 1), now
 }
 
 func (t *RateLimitThrottler) ReleaseSlot(ctx context.Context, slotId string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.granted, slotId)
+}
+
+// CancelSlot returns the token of an admission which isn't used, e.g. of a branch of an anyOf which admitted the slot
+// at the same time as the winner. The limiter only takes back tokens of reservations which didn't act yet, so the
+// reservation is cancelled at the time it acted. If the limiter was changed since then, the token stays taken.
+func (t *RateLimitThrottler) CancelSlot(ctx context.Context, slotId string) {
+	t.mu.Lock()
+	granted, ok := t.granted[slotId]
+	delete(t.granted, slotId)
+	t.mu.Unlock()
+	if ok {
+		t.cancel(granted.reservation, granted.timeToAct)
+	}
+}
+
+// cancel cancels the reservation at the given time, but never before the last change of the limiter
+func (t *RateLimitThrottler) cancel(reservation *rate.Reservation, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.Before(t.changed) {
+		at = t.changed
+	}
+	reservation.CancelAt(at)
+	t.changed = at
+}
+
+// AdoptSlots takes over the state of the previous rate limiter, so replacing the config doesn't refill the tokens.
+// A limiter with the same rate and burst is taken over as it is, otherwise the new one starts with the tokens which were left.
+func (t *RateLimitThrottler) AdoptSlots(previous Throttler) {
+	other, ok := previous.(*RateLimitThrottler)
+	if !ok || other == t {
+		return
+	}
+	other.mu.Lock()
+	limiter, changed, granted := other.rate, other.changed, other.granted
+	other.granted = map[string]grant{}
+	other.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	maps.Copy(t.granted, granted)
+	if other.limit == t.limit && other.burst == t.burst {
+		t.rate = limiter
+		t.changed = changed
+		return
+	}
+	now := t.clock.Now()
+	if used := min(t.rate.Burst()-int(limiter.TokensAt(now)), t.rate.Burst()); used > 0 {
+		t.rate.ReserveN(now, used)
+		t.changed = now
+	}
+}
+
+func (t *RateLimitThrottler) grant(slotId string, ticket uint64, reservation *rate.Reservation, timeToAct time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.granted[slotId] = grant{ticket: ticket, reservation: reservation, timeToAct: timeToAct}
+}
+
+// isGranted returns true if the ticket already took a token for the slot, requests without a ticket always take one
+func (t *RateLimitThrottler) isGranted(slotId string, ticket uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	granted, ok := t.granted[slotId]
+	return ok && ticket != 0 && granted.ticket == ticket
 }
 
 func (t *RateLimitThrottler) String() string {
@@ -40,3 +189,13 @@ func (t *RateLimitThrottler) String() string {
 func (t *RateLimitThrottler) ActiveSlots() []string {
 	return []string{}
 }
+
+func (t *RateLimitThrottler) Describe() Snapshot {
+	return Snapshot{
+		Type:            TypeRateLimit,
+		Description:     t.String(),
+		Limit:           float(float64(t.rate.Burst())),
+		TokensAvailable: float(t.rate.TokensAt(t.clock.Now())),
+		Waiters:         int(t.waiters.Load()),
+	}
+}
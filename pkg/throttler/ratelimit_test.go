@@ -0,0 +1,167 @@
+package throttler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock only moves when the test advances it, its timers never fire
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) After(d time.Duration) <-chan time.Time {
+	return make(chan time.Time)
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newManualRateLimit(t *testing.T, r string, burst int) (*RateLimitThrottler, *manualClock) {
+	t.Helper()
+	clock := &manualClock{now: time.Unix(1_000_000, 0)}
+	rateLimit, err := Environment{Clock: clock}.NewRateLimitThrottler(r, burst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rateLimit, clock
+}
+
+func tryAcquire(rateLimit *RateLimitThrottler, slotId string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	return rateLimit.AquireSlot(ctx, slotId, Data{})
+}
+
+func TestRateLimitHonoursTheTokenOfTheTicket(t *testing.T) {
+	rateLimit, err := NewRateLimitThrottler("1h", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquire := func(slotId string, ticket uint64) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		return rateLimit.AquireSlot(ctx, slotId, Data{Ticket: ticket})
+	}
+
+	if err := acquire("default/web", 1); err != nil {
+		t.Fatalf("first attempt wasn't admitted: %v", err)
+	}
+	// the kubelet retries the sandbox after a later stage failed
+	if err := acquire("default/web", 1); err != nil {
+		t.Errorf("retry of the ticket took another token: %v", err)
+	}
+	if err := acquire("default/other", 2); err == nil {
+		t.Errorf("another ticket was admitted without a token")
+	}
+
+	// the pod was replaced by one with a new ticket under the same name
+	rateLimit.ReleaseSlot(context.Background(), "default/web")
+	if err := acquire("default/web", 3); err == nil {
+		t.Errorf("a new ticket was admitted with the token of the released one")
+	}
+}
+
+func TestRateLimitCancelSlotDoesNotExceedTheBurst(t *testing.T) {
+	rateLimit, clock := newManualRateLimit(t, "1s", 2)
+
+	for _, slotId := range []string{"a", "b"} {
+		if err := tryAcquire(rateLimit, slotId); err != nil {
+			t.Fatalf("slot %s wasn't admitted: %v", slotId, err)
+		}
+	}
+	rateLimit.CancelSlot(context.Background(), "a")
+	rateLimit.CancelSlot(context.Background(), "b")
+	rateLimit.CancelSlot(context.Background(), "a")
+	// the limiter refills while the tokens of the cancelled slots are back
+	clock.Advance(10 * time.Second)
+
+	if tokens := *rateLimit.Describe().TokensAvailable; tokens != 2 {
+		t.Errorf("got %v tokens available, want the burst of 2", tokens)
+	}
+	for _, slotId := range []string{"c", "d"} {
+		if err := tryAcquire(rateLimit, slotId); err != nil {
+			t.Fatalf("slot %s wasn't admitted: %v", slotId, err)
+		}
+	}
+	if err := tryAcquire(rateLimit, "e"); err == nil {
+		t.Errorf("slot was admitted beyond the burst")
+	}
+}
+
+func TestRateLimitCancelSlotKeepsTheTokenOnceTheLimiterChanged(t *testing.T) {
+	rateLimit, clock := newManualRateLimit(t, "1h", 2)
+
+	if err := tryAcquire(rateLimit, "a"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Millisecond)
+	if err := tryAcquire(rateLimit, "b"); err != nil {
+		t.Fatal(err)
+	}
+	// the reservation of b was made without the token of a, returning it would admit more than the rate
+	rateLimit.CancelSlot(context.Background(), "a")
+
+	if tokens := *rateLimit.Describe().TokensAvailable; tokens >= 1 {
+		t.Errorf("got %v tokens available, want the token of a to stay taken", tokens)
+	}
+	if err := tryAcquire(rateLimit, "c"); err == nil {
+		t.Errorf("slot was admitted with the token of a")
+	}
+}
+
+func TestRateLimitAdoptSlotsKeepsTheTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  string
+		burst int
+		// wantTokens are the tokens of the new limiter after the previous one took 2 of 3
+		wantTokens float64
+	}{
+		{name: "same rate and burst", rate: "1h", burst: 3, wantTokens: 1},
+		{name: "larger burst", rate: "1h", burst: 5, wantTokens: 1},
+		{name: "smaller burst", rate: "2h", burst: 1, wantTokens: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous, clock := newManualRateLimit(t, "1h", 3)
+			for _, slotId := range []string{"a", "b"} {
+				if err := previous.AquireSlot(context.Background(), slotId, Data{Ticket: 1}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			next, err := Environment{Clock: clock}.NewRateLimitThrottler(tt.rate, tt.burst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next.AdoptSlots(previous)
+
+			if tokens := *next.Describe().TokensAvailable; tokens != tt.wantTokens {
+				t.Errorf("got %v tokens available, want %v", tokens, tt.wantTokens)
+			}
+			if err := tryAcquireTicket(next, "a", 1); err != nil {
+				t.Errorf("the retry of an adopted ticket took another token: %v", err)
+			}
+		})
+	}
+}
+
+func tryAcquireTicket(rateLimit *RateLimitThrottler, slotId string, ticket uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	return rateLimit.AquireSlot(ctx, slotId, Data{Ticket: ticket})
+}
@@ -0,0 +1,85 @@
+package throttler
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	TypeAll           = "all"
+	TypeRateLimit     = "rateLimit"
+	TypeMaxConcurrent = "maxConcurrent"
+	TypeLoadAvg       = "loadAvg"
+	TypeCpu           = "cpu"
+	TypeIO            = "io"
+	TypeCel           = "cel"
+	TypeAnyOf         = "anyOf"
+	TypeAllOf         = "allOf"
+	TypeCoordinator   = "coordinator"
+)
+
+// Snapshot is the state of a throttler at a point in time. Fields which don't apply to a throttler type are nil.
+type Snapshot struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	// the throttle group the throttler belongs to, empty for the default chain
+	Group string `json:"group,omitempty"`
+	// the limit which must not be exceeded, e.g. the maximum number of slots or the maximum load
+	Limit *float64 `json:"limit,omitempty"`
+	// the value which is compared against the limit, e.g. the active slots or the load including pending increments
+	Usage *float64 `json:"usage,omitempty"`
+	// the last measured load
+	Load *float64 `json:"load,omitempty"`
+	// the sum of incrementBy of all slots acquired since the last measurement
+	PendingIncrement *float64 `json:"pendingIncrement,omitempty"`
+	// the tokens of the rate limiter which are available right now
+	TokensAvailable *float64   `json:"tokensAvailable,omitempty"`
+	ActiveSlots     int        `json:"activeSlots"`
+	Waiters         int        `json:"waiters"`
+	Children        []Snapshot `json:"children,omitempty"`
+}
+
+// Explain describes in a short sentence why the throttler is blocking, e.g. "cpu load 92% >= 80%"
+func (s Snapshot) Explain() string {
+	switch s.Type {
+	case TypeCpu:
+		return fmt.Sprintf("cpu load %s%% >= %s%%", formatValue(s.Usage), formatValue(s.Limit))
+	case TypeIO:
+		return fmt.Sprintf("io load %s%% >= %s%%", formatValue(s.Usage), formatValue(s.Limit))
+	case TypeLoadAvg:
+		return fmt.Sprintf("load average %s >= %s", formatValue(s.Usage), formatValue(s.Limit))
+	case TypeMaxConcurrent:
+		return fmt.Sprintf("max concurrent %s/%s slots in use", formatValue(s.Usage), formatValue(s.Limit))
+	case TypeRateLimit:
+		return fmt.Sprintf("rate limit, %s tokens available", formatValue(s.TokensAvailable))
+	case TypeCel:
+		return "cel condition is false"
+	case TypeCoordinator:
+		return "cluster-wide budget of the coordinator is exhausted"
+	case TypeAll, TypeAllOf:
+		explanations := make([]string, 0, len(s.Children))
+		for _, child := range s.Children {
+			explanations = append(explanations, child.Explain())
+		}
+		return strings.Join(explanations, ", ")
+	case TypeAnyOf:
+		explanations := make([]string, 0, len(s.Children))
+		for _, child := range s.Children {
+			explanations = append(explanations, child.Explain())
+		}
+		return "(" + strings.Join(explanations, " and ") + ")"
+	default:
+		return s.Description
+	}
+}
+
+func formatValue(v *float64) string {
+	if v == nil {
+		return "?"
+	}
+	return fmt.Sprintf("%.4g", *v)
+}
+
+func float(v float64) *float64 {
+	return &v
+}
@@ -2,12 +2,19 @@ package throttler
 
 import (
 	"context"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 )
 
 type Data struct {
 	Pod *v1.Pod
+	// Ticket is the position of the request in the startup queue, lower tickets are admitted first
+	Ticket uint64
+	// Breakdown receives the time spent in each throttler, if set
+	Breakdown *Breakdown
+	// Group is the throttle group of the pod, empty for the default chain
+	Group string
 }
 
 type Throttler interface {
@@ -15,4 +22,78 @@ type Throttler interface {
 	ReleaseSlot(ctx context.Context, slotId string)
 	ActiveSlots() []string
 	String() string
+	Describe() Snapshot
+}
+
+// BlockingReporter is implemented by throttlers which know the throttler a waiting slot is currently blocked by
+type BlockingReporter interface {
+	BlockedBy(slotId string) (Throttler, bool)
+}
+
+type SlotInfo struct {
+	SlotId     string    `json:"slotId"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	Throttler  string    `json:"throttler"`
+}
+
+// SlotAdopter is implemented by throttlers which can take over the active slots of the throttler they replace,
+// so a new config doesn't admit more pods while the pods admitted by the previous one are still starting
+type SlotAdopter interface {
+	AdoptSlots(previous Throttler)
+}
+
+// SlotRegistrar is implemented by throttlers which count active slots. RegisterSlot counts a slot which was admitted
+// without the throttler, e.g. by the local fallback of the CNI plugin, at once and even beyond the limit.
+type SlotRegistrar interface {
+	RegisterSlot(slotId string, data Data, acquiredAt time.Time)
+}
+
+// registerSlot counts the slot in all throttlers which count active slots
+func registerSlot(throttlers []Throttler, slotId string, data Data, acquiredAt time.Time) {
+	for _, throttle := range throttlers {
+		if registrar, ok := throttle.(SlotRegistrar); ok {
+			registrar.RegisterSlot(slotId, data, acquiredAt)
+		}
+	}
+}
+
+// SlotLister is implemented by throttlers which know when a slot was acquired
+type SlotLister interface {
+	Slots() []SlotInfo
+}
+
+// SlotCanceller is implemented by throttlers whose admission has an effect beyond the slot, e.g. the token of a rate limit.
+// CancelSlot undoes the admission of a slot which isn't used, e.g. by a branch of an anyOf which lost, unlike ReleaseSlot
+// which is called once the pod started.
+type SlotCanceller interface {
+	CancelSlot(ctx context.Context, slotId string)
+}
+
+// cancelSlot undoes the admission of the slot, throttlers without an effect beyond the slot release it
+func cancelSlot(ctx context.Context, t Throttler, slotId string) {
+	if canceller, ok := t.(SlotCanceller); ok {
+		canceller.CancelSlot(ctx, slotId)
+		return
+	}
+	t.ReleaseSlot(ctx, slotId)
+}
+
+// SlotLookup is implemented by throttlers which can look up when a single slot was acquired, without listing all slots
+type SlotLookup interface {
+	AcquiredAt(slotId string) (time.Time, bool)
+}
+
+// earliestAcquiredAt returns the earliest time one of the throttlers acquired the slot
+func earliestAcquiredAt(throttlers []Throttler, slotId string) (time.Time, bool) {
+	var earliest time.Time
+	for _, throttle := range throttlers {
+		lookup, ok := throttle.(SlotLookup)
+		if !ok {
+			continue
+		}
+		if acquiredAt, ok := lookup.AcquiredAt(slotId); ok && (earliest.IsZero() || acquiredAt.Before(earliest)) {
+			earliest = acquiredAt
+		}
+	}
+	return earliest, !earliest.IsZero()
 }
@@ -0,0 +1,72 @@
+package throttler
+
+import (
+	"math"
+	"time"
+
+	"woehrl01/pod-pacemaker/api/v1beta1"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// scaleRecheckInterval is the interval in which waiting slots check a limit which is scaled, as it grows without a release
+const scaleRecheckInterval = time.Second
+
+// defaultWarmupSteps is the number of steps of a stepwise ramp without steps
+const defaultWarmupSteps = 4
+
+// Ramp is the fraction of the limits during the warmup of a node, it grows from the initial fraction to 1 over the duration
+type Ramp struct {
+	clock    Clock
+	duration time.Duration
+	initial  float64
+	// steps is the number of steps of a stepwise ramp, 0 for a linear one
+	steps int
+	since func() (time.Time, bool)
+}
+
+// NewWarmupRamp returns the ramp of the config, since returns when the ramp started or false if it didn't start yet
+func NewWarmupRamp(config *v1beta1.Warmup, clock Clock, since func() (time.Time, bool)) *Ramp {
+	r := &Ramp{
+		clock:    clock,
+		duration: config.Duration.Duration,
+		initial:  config.InitialFraction.AsApproximateFloat64(),
+		since:    since,
+	}
+	if config.Mode == v1beta1.WarmupStepwise {
+		r.steps = config.Steps
+		if r.steps == 0 {
+			r.steps = defaultWarmupSteps
+		}
+	}
+	return r
+}
+
+// Fraction returns the fraction of the limits which applies right now, the initial one until the ramp started
+func (r *Ramp) Fraction() float64 {
+	start, ok := r.since()
+	if !ok {
+		return r.initial
+	}
+	elapsed := r.clock.Now().Sub(start)
+	if elapsed >= r.duration {
+		return 1
+	}
+	if elapsed < 0 {
+		return r.initial
+	}
+	progress := float64(elapsed) / float64(r.duration)
+	if r.steps > 0 {
+		progress = math.Floor(progress*float64(r.steps)) / float64(r.steps)
+	}
+	return r.initial + (1-r.initial)*progress
+}
+
+// GetBootTime returns the time the node booted, derived from /proc/uptime
+func GetBootTime() (time.Time, bool) {
+	uptime, err := host.Uptime()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Now().Add(-time.Duration(uptime) * time.Second), true
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+
+	tracerName = "woehrl01/pod-pacemaker"
+)
+
+type Options struct {
+	// Exporter is either "none" (default) or "otlp"
+	Exporter string
+	// Endpoint is the host:port of the OTLP gRPC receiver, defaults to the OTEL_EXPORTER_OTLP_* environment variables
+	Endpoint string
+	Insecure bool
+	// SampleRatio is the fraction of new traces which are sampled, traces started by a caller follow its decision
+	SampleRatio float64
+	ServiceName string
+	// SpanExporter replaces the configured exporter, e.g. with an in-memory exporter of the sdk's tracetest package
+	SpanExporter sdktrace.SpanExporter
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator.
+// The returned func flushes the pending spans and must be called before the process exits.
+func Setup(ctx context.Context, o Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	exporter := o.SpanExporter
+	if exporter == nil {
+		switch o.Exporter {
+		case "", ExporterNone:
+			return func(context.Context) error { return nil }, nil
+		case ExporterOTLP:
+			options := []otlptracegrpc.Option{}
+			if o.Endpoint != "" {
+				options = append(options, otlptracegrpc.WithEndpoint(o.Endpoint))
+			}
+			if o.Insecure {
+				options = append(options, otlptracegrpc.WithInsecure())
+			}
+			var err error
+			exporter, err = otlptracegrpc.New(ctx, options...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create otlp exporter: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown tracing exporter %q", o.Exporter)
+		}
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", o.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of pod-pacemaker, which is a no-op unless Setup installed an exporter
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}